@@ -0,0 +1,9 @@
+package authenticators
+
+import "services/credentials/config"
+
+var cfg = &config.Config{}
+
+func Configure(c *config.Config) {
+	cfg = c
+}
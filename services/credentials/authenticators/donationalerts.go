@@ -5,22 +5,23 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
+	"services/shared/logging"
 	"strings"
 )
 
 func DonationAlerts(w http.ResponseWriter, r *http.Request) {
 	var DONATIONALERTS_CLIENTID, DONATIONALERTS_CLIENTSECRET, REDIRECTURI string
-	DONATIONALERTS_CLIENTID = os.Getenv("DONATIONALERTS_CLIENTID")
-	DONATIONALERTS_CLIENTSECRET = os.Getenv("DONATIONALERTS_CLIENTSECRET")
-	REDIRECTURI = os.Getenv("REDIRECTURI")
+	DONATIONALERTS_CLIENTID = cfg.DonationAlerts.ClientID
+	DONATIONALERTS_CLIENTSECRET = cfg.DonationAlerts.ClientSecret
+	REDIRECTURI = cfg.RedirectURI
 
 	if DONATIONALERTS_CLIENTID == "" || DONATIONALERTS_CLIENTSECRET == "" || REDIRECTURI == "" {
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprint(w, "DonationAlerts service was not properly configured.")
-		log.Println("Missing DonationAlerts ClientID=" + DONATIONALERTS_CLIENTID + ", ClientSecret=" + DONATIONALERTS_CLIENTSECRET + " or RedirectUri=" + REDIRECTURI)
+		logging.FromContext(r.Context()).Error("Missing DonationAlerts configuration", slog.String("client_id", DONATIONALERTS_CLIENTID), slog.String("redirect_uri", REDIRECTURI))
 		return
 	}
 
@@ -70,14 +71,14 @@ type refreshRequestBody struct {
 
 func DonationAlertsRefresh(w http.ResponseWriter, r *http.Request) {
 	var DONATIONALERTS_CLIENTID, DONATIONALERTS_CLIENTSECRET, REDIRECTURI string
-	DONATIONALERTS_CLIENTID = os.Getenv("DONATIONALERTS_CLIENTID")
-	DONATIONALERTS_CLIENTSECRET = os.Getenv("DONATIONALERTS_CLIENTSECRET")
-	REDIRECTURI = os.Getenv("REDIRECTURI")
+	DONATIONALERTS_CLIENTID = cfg.DonationAlerts.ClientID
+	DONATIONALERTS_CLIENTSECRET = cfg.DonationAlerts.ClientSecret
+	REDIRECTURI = cfg.RedirectURI
 
 	if DONATIONALERTS_CLIENTID == "" || DONATIONALERTS_CLIENTSECRET == "" || REDIRECTURI == "" {
 		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprint(w, "Twitch service was not properly configured.")
-		log.Println("Missing Twitch ClientID, ClientSecret or RedirectUri")
+		fmt.Fprint(w, "DonationAlerts service was not properly configured.")
+		logging.FromContext(r.Context()).Error("Missing DonationAlerts configuration")
 		return
 	}
 
@@ -6,20 +6,20 @@ import (
 	"log"
 	"net/http"
 	"net/url"
-	"os"
+	"services/shared/logging"
 	"strings"
 )
 
 func Google(w http.ResponseWriter, r *http.Request) {
 	var GOOGLE_CLIENTID, GOOGLE_CLIENTSECRET, REDIRECTURI string
-	GOOGLE_CLIENTID = os.Getenv("GOOGLE_CLIENTID")
-	GOOGLE_CLIENTSECRET = os.Getenv("GOOGLE_CLIENTSECRET")
-	REDIRECTURI = os.Getenv("REDIRECTURI")
+	GOOGLE_CLIENTID = cfg.Google.ClientID
+	GOOGLE_CLIENTSECRET = cfg.Google.ClientSecret
+	REDIRECTURI = cfg.RedirectURI
 
 	if GOOGLE_CLIENTID == "" || GOOGLE_CLIENTSECRET == "" || REDIRECTURI == "" {
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprint(w, "Google service was not properly configured.")
-		log.Println("Missing Google ClientID, ClientSecret or RedirectUri")
+		logging.FromContext(r.Context()).Error("Missing Google configuration")
 		return
 	}
 
@@ -74,14 +74,14 @@ func Google(w http.ResponseWriter, r *http.Request) {
 
 func GoogleRefresh(w http.ResponseWriter, r *http.Request) {
 	var GOOGLE_CLIENTID, GOOGLE_CLIENTSECRET, REDIRECTURI string
-	GOOGLE_CLIENTID = os.Getenv("GOOGLE_CLIENTID")
-	GOOGLE_CLIENTSECRET = os.Getenv("GOOGLE_CLIENTSECRET")
-	REDIRECTURI = os.Getenv("REDIRECTURI")
+	GOOGLE_CLIENTID = cfg.Google.ClientID
+	GOOGLE_CLIENTSECRET = cfg.Google.ClientSecret
+	REDIRECTURI = cfg.RedirectURI
 
 	if GOOGLE_CLIENTID == "" || GOOGLE_CLIENTSECRET == "" || REDIRECTURI == "" {
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprint(w, "Google service was not properly configured.")
-		log.Println("Missing Google ClientID, ClientSecret or RedirectUri")
+		logging.FromContext(r.Context()).Error("Missing Google configuration")
 		return
 	}
 
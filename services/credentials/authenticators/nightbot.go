@@ -4,22 +4,23 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
+	"services/shared/logging"
 	"strings"
 )
 
 func Nightbot(w http.ResponseWriter, r *http.Request) {
 	var NIGHTBOT_CLIENTID, NIGHTBOT_CLIENTSECRET, REDIRECTURI string
-	NIGHTBOT_CLIENTID = os.Getenv("NIGHTBOT_CLIENTID")
-	NIGHTBOT_CLIENTSECRET = os.Getenv("NIGHTBOT_CLIENTSECRET")
-	REDIRECTURI = os.Getenv("REDIRECTURI")
+	NIGHTBOT_CLIENTID = cfg.Nightbot.ClientID
+	NIGHTBOT_CLIENTSECRET = cfg.Nightbot.ClientSecret
+	REDIRECTURI = cfg.RedirectURI
 
 	if NIGHTBOT_CLIENTID == "" || NIGHTBOT_CLIENTSECRET == "" || REDIRECTURI == "" {
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprint(w, "Nightbot service was not properly configured.")
-		log.Println("Missing Nightbot ClientID=" + NIGHTBOT_CLIENTID + ", ClientSecret=" + NIGHTBOT_CLIENTSECRET + " or RedirectUri=" + REDIRECTURI)
+		logging.FromContext(r.Context()).Error("Missing Nightbot configuration", slog.String("client_id", NIGHTBOT_CLIENTID), slog.String("redirect_uri", REDIRECTURI))
 		return
 	}
 
@@ -4,22 +4,23 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
+	"services/shared/logging"
 	"strings"
 )
 
 func Streamlabs(w http.ResponseWriter, r *http.Request) {
 	var STREAMLABS_CLIENTID, STREAMLABS_CLIENTSECRET, REDIRECTURI string
-	STREAMLABS_CLIENTID = os.Getenv("STREAMLABS_CLIENTID")
-	STREAMLABS_CLIENTSECRET = os.Getenv("STREAMLABS_CLIENTSECRET")
-	REDIRECTURI = os.Getenv("REDIRECTURI")
+	STREAMLABS_CLIENTID = cfg.Streamlabs.ClientID
+	STREAMLABS_CLIENTSECRET = cfg.Streamlabs.ClientSecret
+	REDIRECTURI = cfg.RedirectURI
 
 	if STREAMLABS_CLIENTID == "" || STREAMLABS_CLIENTSECRET == "" || REDIRECTURI == "" {
 		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprint(w, "DonationAlerts service was not properly configured.")
-		log.Println("Missing DonationAlerts ClientID=" + STREAMLABS_CLIENTID + ", ClientSecret=" + STREAMLABS_CLIENTSECRET + " or RedirectUri=" + REDIRECTURI)
+		fmt.Fprint(w, "Streamlabs service was not properly configured.")
+		logging.FromContext(r.Context()).Error("Missing Streamlabs configuration", slog.String("client_id", STREAMLABS_CLIENTID), slog.String("redirect_uri", REDIRECTURI))
 		return
 	}
 
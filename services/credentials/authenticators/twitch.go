@@ -4,9 +4,10 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
+	"services/shared/logging"
 	"slices"
 	"strings"
 
@@ -15,14 +16,14 @@ import (
 
 func Twitch(w http.ResponseWriter, r *http.Request) {
 	var TWITCH_CLIENTID, TWITCH_CLIENTSECRET, REDIRECTURI string
-	TWITCH_CLIENTID = os.Getenv("TWITCH_CLIENTID")
-	TWITCH_CLIENTSECRET = os.Getenv("TWITCH_CLIENTSECRET")
-	REDIRECTURI = os.Getenv("REDIRECTURI")
+	TWITCH_CLIENTID = cfg.Twitch.ClientID
+	TWITCH_CLIENTSECRET = cfg.Twitch.ClientSecret
+	REDIRECTURI = cfg.RedirectURI
 
 	if TWITCH_CLIENTID == "" || TWITCH_CLIENTSECRET == "" || REDIRECTURI == "" {
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprint(w, "Twitch service was not properly configured.")
-		log.Println("Missing Twitch ClientID=" + TWITCH_CLIENTID + ", ClientSecret=" + TWITCH_CLIENTSECRET + " or RedirectUri=" + REDIRECTURI)
+		logging.FromContext(r.Context()).Error("Missing Twitch configuration", slog.String("client_id", TWITCH_CLIENTID), slog.String("redirect_uri", REDIRECTURI))
 		return
 	}
 
@@ -62,6 +63,18 @@ func Twitch(w http.ResponseWriter, r *http.Request) {
 			"channel:read:goals",
 			"moderator:read:shield_mode",
 			"moderator:read:shoutouts",
+			"user:read:chat",
+			"user:bot",
+			"channel:bot",
+			"moderator:read:blocked_terms",
+			"moderator:read:chat_settings",
+			"moderator:read:unban_requests",
+			"moderator:read:chat_messages",
+			"moderator:read:warnings",
+			"moderator:read:moderators",
+			"moderator:read:vips",
+			"moderator:read:suspicious_users",
+			"moderator:manage:automod",
 		}
 		// bot scopes
 		if strings.HasPrefix(state, "bot") {
@@ -83,6 +96,15 @@ func Twitch(w http.ResponseWriter, r *http.Request) {
 				"bits:read",
 				"moderator:manage:chat_settings",
 				"moderator:manage:shoutouts",
+				"user:read:chat",
+				"user:bot",
+				"moderator:read:blocked_terms",
+				"moderator:read:unban_requests",
+				"moderator:read:warnings",
+				"moderator:read:moderators",
+				"moderator:read:vips",
+				"moderator:read:suspicious_users",
+				"moderator:manage:automod",
 			}
 		}
 
@@ -116,7 +138,7 @@ func Twitch(w http.ResponseWriter, r *http.Request) {
 
 		if resp.StatusCode == http.StatusBadRequest {
 			//  logging bad request error
-			log.Println("ERROR: " + string(body))
+			logging.FromContext(r.Context()).Error("Twitch token request failed", slog.String("body", string(body)))
 		}
 
 		w.WriteHeader(resp.StatusCode)
@@ -137,19 +159,19 @@ func TwitchRefresh(w http.ResponseWriter, r *http.Request) {
 	if slices.Contains(bannedTokens, refreshToken) {
 		w.WriteHeader(http.StatusForbidden)
 		fmt.Fprint(w, "Banned refresh token used.")
-		log.Println("User used banned token: " + refreshToken)
+		logging.FromContext(r.Context()).Warn("User used banned token", slog.String("refresh_token", refreshToken))
 		return
 	}
 
 	var TWITCH_CLIENTID, TWITCH_CLIENTSECRET, REDIRECTURI string
-	TWITCH_CLIENTID = os.Getenv("TWITCH_CLIENTID")
-	TWITCH_CLIENTSECRET = os.Getenv("TWITCH_CLIENTSECRET")
-	REDIRECTURI = os.Getenv("REDIRECTURI")
+	TWITCH_CLIENTID = cfg.Twitch.ClientID
+	TWITCH_CLIENTSECRET = cfg.Twitch.ClientSecret
+	REDIRECTURI = cfg.RedirectURI
 
 	if TWITCH_CLIENTID == "" || TWITCH_CLIENTSECRET == "" || REDIRECTURI == "" {
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprint(w, "Twitch service was not properly configured.")
-		log.Println("Missing Twitch ClientID, ClientSecret or RedirectUri")
+		logging.FromContext(r.Context()).Error("Missing Twitch configuration")
 		return
 	}
 	params := url.Values{}
@@ -171,7 +193,7 @@ func TwitchRefresh(w http.ResponseWriter, r *http.Request) {
 
 	if resp.StatusCode == http.StatusBadRequest {
 		//  logging bad request error
-		log.Println("ERROR|" + refreshToken + ": " + string(body))
+		logging.FromContext(r.Context()).Error("Twitch token refresh failed", slog.String("refresh_token", refreshToken), slog.String("body", string(body)))
 	}
 
 	w.WriteHeader(resp.StatusCode)
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"services/shared/config"
+	"services/shared/logging"
+)
+
+type Config struct {
+	ListenAddr  string `env:"LISTEN_ADDR" flag:"listen" default:":3000" usage:"address of credentials endpoint"`
+	RedirectURI string `env:"REDIRECTURI" flag:"redirect-uri" required:"true" usage:"base URL providers redirect back to"`
+
+	Twitch         Provider `env:"TWITCH_"`
+	Google         Provider `env:"GOOGLE_"`
+	Nightbot       Provider `env:"NIGHTBOT_"`
+	DonationAlerts Provider `env:"DONATIONALERTS_"`
+	Streamlabs     Provider `env:"STREAMLABS_"`
+
+	Log logging.Config
+}
+
+// Provider holds OAuth application of one provider, provider is disabled if not set
+type Provider struct {
+	ClientID     string `env:"CLIENTID"`
+	ClientSecret string `env:"CLIENTSECRET" secret:"true"`
+}
+
+func (p Provider) Configured() bool {
+	return p.ClientID != "" && p.ClientSecret != ""
+}
+
+func (c *Config) Validate() error {
+	errs := config.Errors{}
+	providers := map[string]Provider{
+		"TWITCH":         c.Twitch,
+		"GOOGLE":         c.Google,
+		"NIGHTBOT":       c.Nightbot,
+		"DONATIONALERTS": c.DonationAlerts,
+		"STREAMLABS":     c.Streamlabs,
+	}
+	configured := 0
+	for name, provider := range providers {
+		if provider.ClientID != "" && provider.ClientSecret == "" {
+			errs = append(errs, fmt.Errorf("%s_CLIENTSECRET is required when %s_CLIENTID is set", name, name))
+		}
+		if provider.ClientID == "" && provider.ClientSecret != "" {
+			errs = append(errs, fmt.Errorf("%s_CLIENTID is required when %s_CLIENTSECRET is set", name, name))
+		}
+		if provider.Configured() {
+			configured++
+		}
+	}
+	if configured == 0 && len(errs) == 0 {
+		errs = append(errs, fmt.Errorf("at least one provider must be configured"))
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Load reads configuration from environment, optional config file and args
+func Load(args []string) (*Config, error) {
+	cfg := &Config{}
+	if _, err := config.Load(cfg, args); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
@@ -1,2 +1,2 @@
 # /bin/bash
-docker build . -t sogebot/credentials:latest && docker push sogebot/credentials:latest
\ No newline at end of file
+docker build .. -f Dockerfile -t sogebot/credentials:latest && docker push sogebot/credentials:latest
\ No newline at end of file
@@ -7,6 +7,9 @@ require (
 	github.com/gorilla/mux v1.8.0
 	github.com/joho/godotenv v1.5.1
 	github.com/rs/cors v1.9.0
+	services/shared v0.0.0
 )
 
 require github.com/cespare/xxhash/v2 v2.1.2 // indirect
+
+replace services/shared => ../shared
@@ -2,12 +2,15 @@ package main
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
 	"time"
 
 	"services/credentials/authenticators"
-	"services/credentials/commons"
+	"services/credentials/config"
+	sharedconfig "services/shared/config"
+	"services/shared/logging"
 
 	"github.com/go-chi/httprate"
 	"github.com/gorilla/mux"
@@ -16,8 +19,20 @@ import (
 )
 
 func main() {
+	logging.Setup("credentials")
+
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		slog.Error("Error loading configuration", logging.Error(err))
+		os.Exit(1)
+	}
+	logging.SetupWithConfig("credentials", cfg.Log)
+	slog.Debug("Loaded configuration", slog.Any("config", sharedconfig.Redacted(cfg)))
+	authenticators.Configure(cfg)
+
 	router := mux.NewRouter().StrictSlash(true)
-	router.Use(commons.Logger)
+	router.Use(logging.Middleware)
+	router.Use(sogebotAttrs)
 	router.Use(httprate.Limit(
 		10,          // requests
 		time.Minute, // per duration
@@ -63,6 +78,21 @@ func main() {
 		AllowedHeaders:   []string{"Authorization", "content-type"},
 	})
 	handler := c.Handler(router)
-	log.Println("Credentials service started")
-	log.Fatal(http.ListenAndServe(":3000", handler))
+	slog.Info("Credentials service started")
+	if err := http.ListenAndServe(cfg.ListenAddr, handler); err != nil {
+		slog.Error("Error serving credentials endpoint", logging.Error(err))
+		os.Exit(1)
+	}
+}
+
+// sogebotAttrs adds bot channel and owners to request log
+func sogebotAttrs(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		channel := r.Header.Get("SogeBot-Channel")
+		owners := r.Header.Get("SogeBot-Owners")
+		if channel != "" && owners != "" {
+			logging.AddAttrs(r.Context(), slog.String("channel", channel), slog.String("owners", owners))
+		}
+		handler.ServeHTTP(w, r)
+	})
 }
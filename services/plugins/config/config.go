@@ -0,0 +1,30 @@
+package config
+
+import (
+	"services/shared/config"
+	"services/shared/logging"
+)
+
+type Config struct {
+	ListenAddr string `env:"LISTEN_ADDR" flag:"listen" default:":3000" usage:"address of plugins endpoint"`
+
+	Database Database
+	Log      logging.Config
+}
+
+type Database struct {
+	Host     string `env:"PG_HOST" flag:"pg-host" default:"localhost"`
+	Port     string `env:"PG_PORT" flag:"pg-port" default:"5432"`
+	Username string `env:"PG_USERNAME" flag:"pg-username" required:"true"`
+	Password string `env:"PG_PASSWORD" secret:"true" required:"true"`
+	Name     string `env:"PG_DB" flag:"pg-db" default:"sogebot"`
+}
+
+// Load reads configuration from environment, optional config file and args
+func Load(args []string) (*Config, error) {
+	cfg := &Config{}
+	if _, err := config.Load(cfg, args); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
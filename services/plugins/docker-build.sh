@@ -1,2 +1,2 @@
 # /bin/bash
-docker build . -t sogebot/plugins:latest && docker push sogebot/plugins:latest
\ No newline at end of file
+docker build .. -f Dockerfile -t sogebot/plugins:latest && docker push sogebot/plugins:latest
\ No newline at end of file
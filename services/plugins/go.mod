@@ -1,6 +1,6 @@
 module services/plugins
 
-go 1.20
+go 1.21
 
 require (
 	github.com/go-playground/validator/v10 v10.11.2
@@ -8,6 +8,7 @@ require (
 	github.com/joho/godotenv v1.4.0
 	github.com/lib/pq v1.10.7
 	github.com/rs/cors v1.8.2
+	services/shared v0.0.0
 )
 
 require (
@@ -19,3 +20,5 @@ require (
 	golang.org/x/sys v0.4.0 // indirect
 	golang.org/x/text v0.6.0 // indirect
 )
+
+replace services/shared => ../shared
@@ -2,13 +2,16 @@ package main
 
 import (
 	"database/sql"
-	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"reflect"
+	"services/plugins/config"
 	"services/plugins/internal/middleware/auth"
 	"services/plugins/routes"
+	sharedconfig "services/shared/config"
+	"services/shared/logging"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
@@ -21,29 +24,23 @@ import (
 var validate *validator.Validate
 
 func main() {
-	var PG_HOST, PG_PORT, PG_USERNAME, PG_PASSWORD, PG_DB string
-	PG_HOST = os.Getenv("PG_HOST")
-	if PG_HOST == "" {
-		PG_HOST = "localhost"
-	}
-	PG_PORT = os.Getenv("PG_PORT")
-	if PG_PORT == "" {
-		PG_PORT = "5432"
-	}
-	PG_USERNAME = os.Getenv("PG_USERNAME")
-	if PG_USERNAME == "" {
-		PG_USERNAME = "postgres"
-	}
-	PG_PASSWORD = os.Getenv("PG_PASSWORD")
-	if PG_PASSWORD == "" {
-		PG_PASSWORD = "postgres"
-	}
-	PG_DB = os.Getenv("PG_DB")
-	if PG_DB == "" {
-		PG_DB = "sogebot"
+	logging.Setup("plugins")
+
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		slog.Error("Error loading configuration", logging.Error(err))
+		os.Exit(1)
 	}
+	logging.SetupWithConfig("plugins", cfg.Log)
+	slog.Debug("Loaded configuration", slog.Any("config", sharedconfig.Redacted(cfg)))
 
-	connStr := fmt.Sprintf("postgresql://%s:%s@%s:%s/%s?sslmode=disable", PG_USERNAME, PG_PASSWORD, PG_HOST, PG_PORT, PG_DB)
+	connStr := (&url.URL{
+		Scheme:   "postgresql",
+		User:     url.UserPassword(cfg.Database.Username, cfg.Database.Password),
+		Host:     cfg.Database.Host + ":" + cfg.Database.Port,
+		Path:     cfg.Database.Name,
+		RawQuery: "sslmode=disable",
+	}).String()
 	// Connect to database
 	db, err := sql.Open("postgres", connStr)
 
@@ -52,12 +49,14 @@ func main() {
 
 	status := db.Ping()
 	if err != nil {
-		log.Fatal(err)
+		slog.Error("Error opening database", logging.Error(err))
+		os.Exit(1)
 	}
 	if status != nil {
-		log.Fatal(status)
+		slog.Error("Error connecting to database", logging.Error(status))
+		os.Exit(1)
 	}
-	fmt.Println("Connected to database")
+	slog.Info("Connected to database")
 
 	validate = validator.New()
 	validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
@@ -69,7 +68,8 @@ func main() {
 	})
 
 	router := mux.NewRouter().StrictSlash(true)
-	router.Use(auth.AuthMiddlewareWithLogger)
+	router.Use(logging.Middleware)
+	router.Use(auth.AuthMiddleware)
 	router.HandleFunc("/plugins", func(w http.ResponseWriter, r *http.Request) {
 		routes.GetPlugins(w, r, db)
 	}).Methods(http.MethodGet)
@@ -126,5 +126,8 @@ func main() {
 		AllowedHeaders:   []string{"Authorization", "content-type", "Access-Control-Allow-Origin"},
 	})
 	handler := c.Handler(router)
-	log.Fatal(http.ListenAndServe(":3000", handler))
+	if err := http.ListenAndServe(cfg.ListenAddr, handler); err != nil {
+		slog.Error("Error serving plugins endpoint", logging.Error(err))
+		os.Exit(1)
+	}
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"services/shared/logging"
 
 	"github.com/gorilla/mux"
 )
@@ -22,7 +23,7 @@ func DeletePlugin(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 	if err != nil {
 		tx.Rollback()
 		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Print(err)
+		logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 		fmt.Fprint(w, "500 - Internal server error")
 		return
 	}
@@ -31,7 +32,7 @@ func DeletePlugin(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 	res, err := tx.Exec(`DELETE FROM "plugin" WHERE "id"=$1 AND "publisherId"=$2`, vars["id"], r.Header.Get("userId"))
 	if err != nil {
 		tx.Rollback()
-		fmt.Print(err)
+		logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprint(w, "500 - Internal server error")
 		return
@@ -39,7 +40,7 @@ func DeletePlugin(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 	count, err := res.RowsAffected()
 	if err != nil {
 		tx.Rollback()
-		fmt.Print(err)
+		logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprint(w, "500 - Internal server error")
 		return
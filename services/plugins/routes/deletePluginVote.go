@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"services/shared/logging"
 
 	"github.com/gorilla/mux"
 )
@@ -22,7 +23,7 @@ func DeletePluginVote(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 	if err != nil {
 		tx.Rollback()
 		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Print(err)
+		logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 		fmt.Fprint(w, "500 - Internal server error")
 		return
 	}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"services/shared/logging"
 
 	"github.com/gorilla/mux"
 )
@@ -67,7 +68,7 @@ func GetOverlay(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 
 		if f, err := json.Marshal(overlay); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
-			fmt.Print(err)
+			logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 			fmt.Fprint(w, "500 - Internal server error")
 		} else {
 			w.Header().Add("content-type", "application/json")
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"services/shared/logging"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -56,7 +57,7 @@ func PostOverlay(w http.ResponseWriter, r *http.Request, db *sql.DB, validate *v
 
 		if f, err := json.Marshal(errors); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
-			fmt.Print(err)
+			logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 			fmt.Fprint(w, "500 - Internal server error")
 		} else {
 			w.Header().Add("content-type", "application/json")
@@ -74,7 +75,7 @@ func PostOverlay(w http.ResponseWriter, r *http.Request, db *sql.DB, validate *v
 	).Scan(&overlay.Id)
 
 	if err != nil || err == sql.ErrNoRows {
-		fmt.Print(err)
+		logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprint(w, "500 - Internal server error")
 		return
@@ -82,7 +83,7 @@ func PostOverlay(w http.ResponseWriter, r *http.Request, db *sql.DB, validate *v
 
 	if f, err := json.Marshal(overlay); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Print(err)
+		logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 		fmt.Fprint(w, "500 - Internal server error")
 	} else {
 		w.Header().Add("content-type", "application/json")
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"services/shared/logging"
 	"strconv"
 
 	"github.com/go-playground/validator/v10"
@@ -25,7 +26,7 @@ func PostOverlayVote(w http.ResponseWriter, r *http.Request, db *sql.DB, validat
 	voteInteger, err := strconv.Atoi(r.FormValue("vote"))
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Print(err)
+		logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 		fmt.Fprint(w, "500 - Internal server error")
 		return
 	}
@@ -48,7 +49,7 @@ func PostOverlayVote(w http.ResponseWriter, r *http.Request, db *sql.DB, validat
 
 		if f, err := json.Marshal(errors); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
-			fmt.Print(err)
+			logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 			fmt.Fprint(w, "500 - Internal server error")
 		} else {
 			w.Header().Add("content-type", "application/json")
@@ -65,7 +66,7 @@ func PostOverlayVote(w http.ResponseWriter, r *http.Request, db *sql.DB, validat
 
 	_, err = tx.Exec(`DELETE FROM "overlay_vote" WHERE "userId"=$1 AND "overlayId"=$2`, overlayVote.UserId, vars["id"])
 	if err != nil {
-		fmt.Print(err)
+		logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprint(w, "500 - Internal server error")
 		return
@@ -81,7 +82,7 @@ func PostOverlayVote(w http.ResponseWriter, r *http.Request, db *sql.DB, validat
 	tx.Commit()
 
 	if err != nil || err == sql.ErrNoRows {
-		fmt.Print(err)
+		logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprint(w, "500 - Internal server error")
 		return
@@ -89,7 +90,7 @@ func PostOverlayVote(w http.ResponseWriter, r *http.Request, db *sql.DB, validat
 
 	if f, err := json.Marshal(overlayVote); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Print(err)
+		logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 		fmt.Fprint(w, "500 - Internal server error")
 	} else {
 		w.Header().Add("content-type", "application/json")
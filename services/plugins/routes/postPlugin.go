@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"services/shared/logging"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -49,7 +50,7 @@ func PostPlugin(w http.ResponseWriter, r *http.Request, db *sql.DB, validate *va
 
 		if f, err := json.Marshal(errors); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
-			fmt.Print(err)
+			logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 			fmt.Fprint(w, "500 - Internal server error")
 		} else {
 			w.Header().Add("content-type", "application/json")
@@ -67,7 +68,7 @@ func PostPlugin(w http.ResponseWriter, r *http.Request, db *sql.DB, validate *va
 	).Scan(&plugin.Id)
 
 	if err != nil || err == sql.ErrNoRows {
-		fmt.Print(err)
+		logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprint(w, "500 - Internal server error")
 		return
@@ -75,7 +76,7 @@ func PostPlugin(w http.ResponseWriter, r *http.Request, db *sql.DB, validate *va
 
 	if f, err := json.Marshal(plugin); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Print(err)
+		logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 		fmt.Fprint(w, "500 - Internal server error")
 	} else {
 		w.Header().Add("content-type", "application/json")
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"services/shared/logging"
 	"strconv"
 
 	"github.com/go-playground/validator/v10"
@@ -25,7 +26,7 @@ func PostPluginVote(w http.ResponseWriter, r *http.Request, db *sql.DB, validate
 	voteInteger, err := strconv.Atoi(r.FormValue("vote"))
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Print(err)
+		logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 		fmt.Fprint(w, "500 - Internal server error")
 		return
 	}
@@ -48,7 +49,7 @@ func PostPluginVote(w http.ResponseWriter, r *http.Request, db *sql.DB, validate
 
 		if f, err := json.Marshal(errors); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
-			fmt.Print(err)
+			logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 			fmt.Fprint(w, "500 - Internal server error")
 		} else {
 			w.Header().Add("content-type", "application/json")
@@ -65,7 +66,7 @@ func PostPluginVote(w http.ResponseWriter, r *http.Request, db *sql.DB, validate
 
 	_, err = tx.Exec(`DELETE FROM "plugin_vote" WHERE "userId"=$1 AND "pluginId"=$2`, pluginVote.UserId, vars["id"])
 	if err != nil {
-		fmt.Print(err)
+		logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprint(w, "500 - Internal server error")
 		return
@@ -81,7 +82,7 @@ func PostPluginVote(w http.ResponseWriter, r *http.Request, db *sql.DB, validate
 	tx.Commit()
 
 	if err != nil || err == sql.ErrNoRows {
-		fmt.Print(err)
+		logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprint(w, "500 - Internal server error")
 		return
@@ -89,7 +90,7 @@ func PostPluginVote(w http.ResponseWriter, r *http.Request, db *sql.DB, validate
 
 	if f, err := json.Marshal(pluginVote); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Print(err)
+		logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 		fmt.Fprint(w, "500 - Internal server error")
 	} else {
 		w.Header().Add("content-type", "application/json")
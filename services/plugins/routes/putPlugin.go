@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"services/shared/logging"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -30,7 +31,7 @@ func PutPlugin(w http.ResponseWriter, r *http.Request, db *sql.DB, validate *val
 		SELECT "id", "name", "version", "publisherId" FROM "plugin" WHERE "id"=$1
 	`, vars["id"]).Scan(&id, &name, &version, &publisherId)
 	if err != nil || err == sql.ErrNoRows {
-		fmt.Println(err)
+		logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 		w.WriteHeader(http.StatusNotFound)
 		fmt.Fprint(w, "404 - Plugin not found")
 		return
@@ -68,7 +69,7 @@ func PutPlugin(w http.ResponseWriter, r *http.Request, db *sql.DB, validate *val
 
 		if f, err := json.Marshal(errors); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
-			fmt.Print(err)
+			logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 			fmt.Fprint(w, "500 - Internal server error")
 		} else {
 			w.Header().Add("content-type", "application/json")
@@ -86,7 +87,7 @@ func PutPlugin(w http.ResponseWriter, r *http.Request, db *sql.DB, validate *val
 	)
 
 	if err != nil {
-		fmt.Print(err)
+		logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprint(w, "500 - Internal server error")
 		return
@@ -94,7 +95,7 @@ func PutPlugin(w http.ResponseWriter, r *http.Request, db *sql.DB, validate *val
 
 	if f, err := json.Marshal(plugin); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Print(err)
+		logging.FromContext(r.Context()).Error("Error handling request", logging.Error(err))
 		fmt.Fprint(w, "500 - Internal server error")
 	} else {
 		w.Header().Add("content-type", "application/json")
@@ -0,0 +1,359 @@
+// Package config loads typed service configuration from defaults, an optional
+// JSON file, environment variables and command line flags (in this order, later
+// sources win).
+//
+// Configuration is described by struct tags:
+//
+//	env:"PG_HOST"        environment variable (and key in config file)
+//	flag:"pg-host"       command line flag
+//	default:"localhost"  default value
+//	required:"true"      value must not be empty
+//	secret:"true"        value is redacted when printed
+//	oneof:"json text"    value must be one of listed values
+//	usage:"..."          description shown in flag usage
+//
+// Tags env and flag of nested struct fields are used as prefix for its fields.
+// Structs implementing Validator are validated after all fields are loaded.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const redacted = "[REDACTED]"
+
+// Validator is implemented by config structs with cross field rules
+type Validator interface {
+	Validate() error
+}
+
+// Errors holds all problems found while loading configuration
+type Errors []error
+
+func (e Errors) Error() string {
+	messages := []string{}
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return "invalid configuration: " + strings.Join(messages, "; ")
+}
+
+type field struct {
+	value    reflect.Value
+	env      string
+	flag     string
+	def      string
+	usage    string
+	required bool
+	secret   bool
+	oneof    []string
+}
+
+// Load fills cfg (pointer to struct) and validates it. Command line flags are
+// read from args, remaining positional arguments are returned.
+func Load(cfg any, args []string) ([]string, error) {
+	fields, err := collect(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	errs := Errors{}
+	set := func(f field, value string, source string) {
+		if err := setValue(f.value, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s from %s: %w", f.name(), source, err))
+		}
+	}
+
+	for _, f := range fields {
+		if f.def != "" {
+			set(f, f.def, "default")
+		}
+	}
+
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to JSON configuration file")
+	flagValues := map[string]*string{}
+	for _, f := range fields {
+		if f.flag != "" {
+			flagValues[f.flag] = fs.String(f.flag, f.def, f.usage)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		values, err := readFile(*configFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fields {
+			if value, ok := values[f.env]; ok && f.env != "" {
+				set(f, value, *configFile)
+			}
+		}
+	}
+
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		if value, ok := os.LookupEnv(f.env); ok && value != "" {
+			set(f, value, "environment")
+		}
+	}
+
+	fs.Visit(func(fl *flag.Flag) {
+		for _, f := range fields {
+			if f.flag == fl.Name {
+				set(f, *flagValues[f.flag], "flag")
+			}
+		}
+	})
+
+	errs = append(errs, validate(cfg, fields)...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return fs.Args(), nil
+}
+
+// Validate checks required and oneof rules and Validator implementations,
+// useful for configs constructed directly in code
+func Validate(cfg any) error {
+	fields, err := collect(cfg)
+	if err != nil {
+		return err
+	}
+	if errs := validate(cfg, fields); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Redacted returns configuration values keyed by env name, secrets are masked
+func Redacted(cfg any) map[string]string {
+	out := map[string]string{}
+	fields, err := collect(cfg)
+	if err != nil {
+		return out
+	}
+	for _, f := range fields {
+		value := fmt.Sprint(f.value.Interface())
+		if f.value.Kind() == reflect.Slice {
+			value = strings.Join(f.value.Interface().([]string), ",")
+		}
+		if f.secret && value != "" {
+			value = redacted
+		}
+		out[f.name()] = value
+	}
+	return out
+}
+
+// String returns configuration as sorted KEY=value lines with secrets masked
+func String(cfg any) string {
+	values := Redacted(cfg)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lines := []string{}
+	for _, key := range keys {
+		lines = append(lines, key+"="+values[key])
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (f field) name() string {
+	if f.env != "" {
+		return f.env
+	}
+	return "-" + f.flag
+}
+
+func validate(cfg any, fields []field) Errors {
+	errs := Errors{}
+	for _, f := range fields {
+		if f.required && f.value.IsZero() {
+			errs = append(errs, fmt.Errorf("%s is required", f.name()))
+			continue
+		}
+		if len(f.oneof) > 0 && !f.value.IsZero() {
+			value := fmt.Sprint(f.value.Interface())
+			found := false
+			for _, allowed := range f.oneof {
+				if strings.EqualFold(value, allowed) {
+					found = true
+				}
+			}
+			if !found {
+				errs = append(errs, fmt.Errorf("%s must be one of %s, got %q", f.name(), strings.Join(f.oneof, ", "), value))
+			}
+		}
+	}
+	errs = append(errs, validators(reflect.ValueOf(cfg))...)
+	return errs
+}
+
+// validators runs Validate of cfg and all nested structs
+func validators(v reflect.Value) Errors {
+	errs := Errors{}
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return errs
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).IsExported() && v.Field(i).Kind() == reflect.Struct {
+			errs = append(errs, validators(v.Field(i).Addr())...)
+		}
+	}
+	if v.CanAddr() {
+		if validator, ok := v.Addr().Interface().(Validator); ok {
+			var nested Errors
+			if err := validator.Validate(); errors.As(err, &nested) {
+				errs = append(errs, nested...)
+			} else if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errs
+}
+
+func collect(cfg any) ([]field, error) {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("config must be pointer to struct")
+	}
+	return collectStruct(v.Elem(), "", ""), nil
+}
+
+func collectStruct(v reflect.Value, envPrefix string, flagPrefix string) []field {
+	fields := []field{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		env := sf.Tag.Get("env")
+		fl := sf.Tag.Get("flag")
+
+		if fv.Kind() == reflect.Struct && fv.Type() != reflect.TypeOf(time.Time{}) {
+			fields = append(fields, collectStruct(fv, envPrefix+env, flagPrefix+fl)...)
+			continue
+		}
+		if env == "" && fl == "" {
+			continue
+		}
+
+		f := field{
+			value:    fv,
+			def:      sf.Tag.Get("default"),
+			usage:    sf.Tag.Get("usage"),
+			required: sf.Tag.Get("required") == "true",
+			secret:   sf.Tag.Get("secret") == "true",
+		}
+		if env != "" {
+			f.env = envPrefix + env
+		}
+		if fl != "" {
+			f.flag = flagPrefix + fl
+		}
+		if oneof := sf.Tag.Get("oneof"); oneof != "" {
+			f.oneof = strings.Fields(oneof)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func setValue(v reflect.Value, value string) error {
+	switch v.Interface().(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case []string:
+		items := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// readFile reads JSON object keyed by env names, values are converted to strings
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	raw := map[string]any{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	for key, value := range raw {
+		switch value := value.(type) {
+		case []any:
+			items := []string{}
+			for _, item := range value {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+		default:
+			values[key] = fmt.Sprint(value)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,3 @@
+module services/shared
+
+go 1.21
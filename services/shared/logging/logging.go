@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Common structured field keys, use these so logs of all services can be
+// queried the same way
+const (
+	KeyRequestID      = "request_id"
+	KeyUserID         = "user_id"
+	KeyEventType      = "event_type"
+	KeySubscriptionID = "subscription_id"
+	KeyError          = "error"
+)
+
+func UserID(id string) slog.Attr         { return slog.String(KeyUserID, id) }
+func EventType(event string) slog.Attr   { return slog.String(KeyEventType, event) }
+func SubscriptionID(id string) slog.Attr { return slog.String(KeySubscriptionID, id) }
+
+func Error(err error) slog.Attr {
+	if err == nil {
+		return slog.String(KeyError, "")
+	}
+	return slog.String(KeyError, err.Error())
+}
+
+// Config of logging, can be embedded into service configuration
+type Config struct {
+	Level  string `env:"LOG_LEVEL" default:"info" oneof:"debug info warn warning error" usage:"log level"`
+	Format string `env:"LOG_FORMAT" default:"text" oneof:"text json" usage:"log output format"`
+}
+
+// Setup configures default logger from environment and returns it
+//
+//	LOG_LEVEL  - debug, info (default), warn or error
+//	LOG_FORMAT - text (default) or json
+func Setup(service string) *slog.Logger {
+	return SetupWithConfig(service, Config{Level: os.Getenv("LOG_LEVEL"), Format: os.Getenv("LOG_FORMAT")})
+}
+
+func SetupWithConfig(service string, cfg Config) *slog.Logger {
+	return SetupWithWriter(service, os.Stdout, cfg.Level, cfg.Format)
+}
+
+func SetupWithWriter(service string, w io.Writer, level string, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       ParseLevel(level),
+		ReplaceAttr: redactAttr,
+	}
+
+	var handler slog.Handler
+	if strings.EqualFold(format, "json") {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+
+	logger := slog.New(handler)
+	if service != "" {
+		logger = logger.With(slog.String("service", service))
+	}
+	slog.SetDefault(logger)
+	return logger
+}
+
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+type contextKey struct{}
+
+// WithLogger returns context carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns logger stored in context (with request id), or default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
@@ -0,0 +1,120 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const RequestIDHeader = "X-Request-Id"
+
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func wrapResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (rw *responseWriter) Status() int {
+	return rw.status
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	if rw.wroteHeader {
+		return
+	}
+	rw.status = code
+	rw.ResponseWriter.WriteHeader(code)
+	rw.wroteHeader = true
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	return rw.ResponseWriter.Write(b)
+}
+
+// Flush is needed for long-polling handlers behind the logger
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// requestAttrs collects attributes added by handlers, they are logged with the request
+type requestAttrs struct {
+	mutex sync.Mutex
+	attrs []any
+}
+
+type attrsKey struct{}
+
+// AddAttrs adds attributes (user id, ...) to request access log
+func AddAttrs(ctx context.Context, attrs ...any) {
+	if holder, ok := ctx.Value(attrsKey{}).(*requestAttrs); ok {
+		holder.mutex.Lock()
+		holder.attrs = append(holder.attrs, attrs...)
+		holder.mutex.Unlock()
+	}
+}
+
+func RequestID(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	return ""
+}
+
+type requestIDKey struct{}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// Middleware assigns request id (reusing incoming X-Request-Id), stores request
+// scoped logger into context and writes access log line after request is done
+func Middleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t := time.Now()
+
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" || len(requestID) > 64 {
+			requestID = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		holder := &requestAttrs{}
+		logger := slog.Default().With(slog.String(KeyRequestID, requestID))
+		ctx := context.WithValue(r.Context(), requestIDKey{}, requestID)
+		ctx = context.WithValue(ctx, attrsKey{}, holder)
+		ctx = WithLogger(ctx, logger)
+
+		interceptWriter := wrapResponseWriter(w)
+		handler.ServeHTTP(interceptWriter, r.WithContext(ctx))
+
+		attrs := []any{
+			slog.String("remote", r.RemoteAddr),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("proto", r.Proto),
+			slog.Int("status", interceptWriter.status),
+			slog.String("user_agent", r.UserAgent()),
+			slog.Duration("duration", time.Since(t)),
+		}
+		holder.mutex.Lock()
+		attrs = append(attrs, holder.attrs...)
+		holder.mutex.Unlock()
+
+		logger.Info("request", attrs...)
+	})
+}
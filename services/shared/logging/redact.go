@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"log/slog"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are parts of attribute keys which values are never logged
+var sensitiveKeys = []string{
+	"secret",
+	"token",
+	"password",
+	"authorization",
+	"apikey",
+	"api_key",
+}
+
+// Secret is string which is always redacted when logged
+type Secret string
+
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(Redact(string(s)))
+}
+
+// Redact masks value, keeping only few characters to be able to tell values apart
+func Redact(value string) string {
+	if len(value) <= 8 {
+		return redacted
+	}
+	return value[:4] + "..." + redacted
+}
+
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if IsSensitive(a.Key) && a.Value.Kind() != slog.KindGroup {
+		return slog.String(a.Key, redacted)
+	}
+	if a.Value.Kind() == slog.KindString {
+		value := a.Value.String()
+		// bearer tokens can leak in through logged headers or messages
+		if i := strings.Index(strings.ToLower(value), "bearer "); i >= 0 {
+			return slog.String(a.Key, value[:i+len("bearer ")]+redacted)
+		}
+		if strings.HasPrefix(strings.ToLower(value), "oauth:") {
+			return slog.String(a.Key, redacted)
+		}
+	}
+	return a
+}
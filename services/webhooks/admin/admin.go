@@ -0,0 +1,101 @@
+// Package admin serves authenticated HTTP API for operating the relay
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"services/shared/logging"
+	"services/webhooks/config"
+	"services/webhooks/database"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Handler returns admin API router, nil if admin API is disabled
+func Handler(cfg config.Admin) http.Handler {
+	if cfg.Token == "" {
+		return nil
+	}
+
+	router := mux.NewRouter().PathPrefix("/admin").Subrouter()
+	router.Use(authMiddleware(cfg.Token))
+
+	router.HandleFunc("/users", getUsers).Methods(http.MethodGet)
+	router.HandleFunc("/users/{id}", getUser).Methods(http.MethodGet)
+	router.HandleFunc("/users/{id}", deleteUser).Methods(http.MethodDelete)
+	router.HandleFunc("/users/{id}/resync", postResync).Methods(http.MethodPost)
+	router.HandleFunc("/users/{id}/queue", deleteQueue).Methods(http.MethodDelete)
+	router.HandleFunc("/reconcile", postReconcile).Methods(http.MethodPost)
+	router.HandleFunc("/audit", getAudit).Methods(http.MethodGet)
+	return router
+}
+
+func authMiddleware(token string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="webhooks-admin"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// actor identifies operator in audit log, token is shared so we rely on header
+func actor(r *http.Request) string {
+	if actor := r.Header.Get("X-Admin-Actor"); actor != "" {
+		return actor
+	}
+	return "admin"
+}
+
+// audit records admin action, failing to write audit log is logged but does not fail the action
+func audit(r *http.Request, action string, target string, details map[string]any) {
+	if details == nil {
+		details = map[string]any{}
+	}
+	details["remote_addr"] = r.RemoteAddr
+	data, _ := json.Marshal(details)
+
+	entry := database.AuditEntry{
+		Actor:   actor(r),
+		Action:  action,
+		Target:  target,
+		Details: string(data),
+	}
+	logger := logging.FromContext(r.Context())
+	logger.Info("Admin action", slog.String("actor", entry.Actor), slog.String("action", action), slog.String("target", target))
+	if err := database.DB.InsertAudit(r.Context(), entry); err != nil {
+		logger.Error("Error writing audit log", slog.String("action", action), logging.Error(err))
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	logging.FromContext(r.Context()).Error("Error handling admin request", logging.Error(err))
+	writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
+
+// limit reads limit query parameter, capped to max
+func limit(r *http.Request, def int, max int) int {
+	value, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || value <= 0 {
+		return def
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
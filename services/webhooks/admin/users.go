@@ -0,0 +1,198 @@
+package admin
+
+import (
+	"net/http"
+	"services/shared/logging"
+	"services/webhooks/database"
+	"services/webhooks/reconciler"
+	"services/webhooks/subscriptions"
+	"services/webhooks/token"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type user struct {
+	ID         string     `json:"id"`
+	Scopes     string     `json:"scopes"`
+	Updated    bool       `json:"updated"`
+	LastSeen   *time.Time `json:"last_seen"`
+	QueueDepth int        `json:"queue_depth"`
+}
+
+type userDetail struct {
+	user
+	// Moderates are broadcasters the user is linked to as bot
+	Moderates     []string             `json:"moderates"`
+	Bots          []string             `json:"bots"`
+	Subscriptions []subscriptions.Data `json:"subscriptions"`
+	// Migrations track replacing subscriptions of superseded versions
+	Migrations []database.VersionMigration `json:"migrations"`
+}
+
+func getUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	users, err := database.DB.SearchUsers(r.Context(), query, limit(r, 100, 1000))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	depths, err := database.DB.QueueDepths(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	audit(r, "users.list", "", map[string]any{"q": query, "count": len(users)})
+
+	response := []user{}
+	for _, u := range users {
+		response = append(response, user{
+			ID:         u.ID,
+			Scopes:     u.Scopes,
+			Updated:    u.Updated,
+			LastSeen:   u.LastSeen,
+			QueueDepth: depths[u.ID],
+		})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func getUser(w http.ResponseWriter, r *http.Request) {
+	userId := mux.Vars(r)["id"]
+	u, found, err := database.DB.GetUser(r.Context(), userId)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !found {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "user not found"})
+		return
+	}
+	depth, err := database.DB.QueueDepth(r.Context(), userId)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	moderates, err := database.DB.BotLinks(r.Context(), userId)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	bots, err := database.DB.LinkedBots(r.Context(), userId)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	migrations, err := database.DB.ListVersionMigrations(r.Context(), userId)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	list, err := subscriptions.ListForUser(userId)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	audit(r, "users.get", userId, nil)
+
+	botIds := []string{}
+	for _, bot := range bots {
+		botIds = append(botIds, bot.ID)
+	}
+
+	writeJSON(w, http.StatusOK, userDetail{
+		user: user{
+			ID:         u.ID,
+			Scopes:     u.Scopes,
+			Updated:    u.Updated,
+			LastSeen:   u.LastSeen,
+			QueueDepth: depth,
+		},
+		Moderates:     moderates,
+		Bots:          botIds,
+		Subscriptions: list,
+		Migrations:    migrations,
+	})
+}
+
+// deleteUser removes user, all their subscriptions at Twitch and queued events
+func deleteUser(w http.ResponseWriter, r *http.Request) {
+	userId := mux.Vars(r)["id"]
+	list, err := subscriptions.ListForUser(userId)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	accessToken, err := token.Access()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	for _, subscription := range list {
+		logging.FromContext(r.Context()).Info("Deleting subscription", logging.UserID(userId), logging.SubscriptionID(subscription.ID), logging.EventType(subscription.Type))
+		if err := subscriptions.DeleteSubscription(subscription.ID, accessToken); err != nil {
+			logging.FromContext(r.Context()).Warn("Error deleting subscription", logging.UserID(userId), logging.SubscriptionID(subscription.ID), logging.Error(err))
+		}
+	}
+
+	if err := database.DB.DeleteUser(r.Context(), userId); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	purged, err := database.DB.PurgeEvents(r.Context(), userId)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	audit(r, "users.delete", userId, map[string]any{"subscriptions": len(list), "events": purged})
+
+	writeJSON(w, http.StatusOK, map[string]any{"subscriptions": len(list), "events": purged})
+}
+
+// postResync marks user as updated and starts reconciliation of updated users
+func postResync(w http.ResponseWriter, r *http.Request) {
+	userId := mux.Vars(r)["id"]
+	if _, found, err := database.DB.GetUser(r.Context(), userId); err != nil {
+		writeError(w, r, err)
+		return
+	} else if !found {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "user not found"})
+		return
+	}
+	if err := database.DB.MarkUpdated(r.Context(), userId); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	audit(r, "users.resync", userId, nil)
+
+	go reconciler.Run(true)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func deleteQueue(w http.ResponseWriter, r *http.Request) {
+	userId := mux.Vars(r)["id"]
+	purged, err := database.DB.PurgeEvents(r.Context(), userId)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	audit(r, "users.queue.purge", userId, map[string]any{"events": purged})
+
+	writeJSON(w, http.StatusOK, map[string]any{"events": purged})
+}
+
+func postReconcile(w http.ResponseWriter, r *http.Request) {
+	audit(r, "reconcile", "", nil)
+
+	go reconciler.Run(false)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func getAudit(w http.ResponseWriter, r *http.Request) {
+	entries, err := database.DB.ListAudit(r.Context(), limit(r, 100, 1000))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
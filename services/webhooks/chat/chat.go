@@ -0,0 +1,246 @@
+// Package chat delivers EventSub chat events to bots without queueing them in
+// eventsub_events, chat is high volume and only recent messages matter.
+// Events are published to all replicas and kept in memory for a short time.
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"services/shared/logging"
+	"services/webhooks/config"
+	"services/webhooks/database"
+	"services/webhooks/eventsub"
+	"slices"
+	"sync"
+	"time"
+)
+
+// channel is database notification channel chat events are published to
+const channel = "eventsub_chat"
+
+// maxPayload is largest payload published directly, NOTIFY refuses payloads
+// over 8000 bytes, larger events are stored and only their reference is published
+const maxPayload = 7900
+
+var (
+	bufferSize = 500
+	retention  = 5 * time.Minute
+)
+
+func Configure(cfg config.Chat) {
+	bufferSize = cfg.Buffer
+	retention = cfg.Retention
+}
+
+var types = []string{
+	"channel.chat.message",
+	"channel.chat.notification",
+	"channel.chat.clear",
+	"channel.chat.message_delete",
+}
+
+// Tracks tells if events of type are delivered through chat instead of user queue
+func Tracks(eventType string) bool {
+	return slices.Contains(types, eventType)
+}
+
+// Event is published chat event, Cursor orders events of user and is unix time
+// in microseconds, so it stays exact in JavaScript numbers. Cursor is assigned
+// when replica receives event, publishers can finish out of order and bot which
+// already got later cursor would skip the event.
+type Event struct {
+	Cursor        int64           `json:"cursor"`
+	MessageID     string          `json:"message_id"`
+	UserID        string          `json:"user_id"`
+	Type          string          `json:"type"`
+	Version       string          `json:"version"`
+	BroadcasterID string          `json:"broadcaster_id"`
+	Timestamp     time.Time       `json:"timestamp"`
+	Data          json.RawMessage `json:"data"`
+}
+
+type buffer struct {
+	events []Event
+	// missed is cursor of newest removed event
+	missed  int64
+	waiters int
+	// wake is closed and replaced when event is added
+	wake chan struct{}
+}
+
+var (
+	mutex   sync.Mutex
+	buffers = map[string]*buffer{}
+	last    int64
+)
+
+// Cursor returns cursor of now, events published later are after it
+func Cursor() int64 {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return next()
+}
+
+// next returns increasing cursors, so events received in a row keep order
+func next() int64 {
+	cursor := time.Now().UnixMicro()
+	if cursor <= last {
+		cursor = last + 1
+	}
+	last = cursor
+	return cursor
+}
+
+// Publish sends chat event to bots of all replicas, body is raw Twitch message
+func Publish(ctx context.Context, envelope *eventsub.Envelope, body []byte) error {
+	payload, err := json.Marshal(Event{
+		MessageID:     envelope.ID,
+		UserID:        envelope.BroadcasterID,
+		Type:          envelope.Type,
+		Version:       envelope.Version,
+		BroadcasterID: envelope.BroadcasterID,
+		Timestamp:     envelope.OccurredAt,
+		Data:          body,
+	})
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxPayload {
+		if err := database.DB.StoreChatPayload(ctx, envelope.ID, string(payload), time.Now()); err != nil {
+			return err
+		}
+		payload, err = json.Marshal(reference{Ref: envelope.ID})
+		if err != nil {
+			return err
+		}
+	}
+	return database.DB.Publish(ctx, channel, string(payload))
+}
+
+// reference is published instead of event stored by StoreChatPayload
+type reference struct {
+	Ref string `json:"ref"`
+}
+
+func get(userId string) *buffer {
+	b, ok := buffers[userId]
+	if !ok {
+		b = &buffer{wake: make(chan struct{})}
+		buffers[userId] = b
+	}
+	return b
+}
+
+// trim removes expired events and events over buffer size
+func (b *buffer) trim(now time.Time) {
+	expired := now.Add(-retention).UnixMicro()
+	for len(b.events) > 0 && (len(b.events) > bufferSize || b.events[0].Cursor < expired) {
+		b.missed = b.events[0].Cursor
+		b.events = b.events[1:]
+	}
+}
+
+func receive(payload string) {
+	var ref reference
+	if err := json.Unmarshal([]byte(payload), &ref); err == nil && ref.Ref != "" {
+		stored, found, err := database.DB.ChatPayload(context.Background(), ref.Ref)
+		if err != nil || !found {
+			slog.Warn("Error loading stored chat event", slog.String("message_id", ref.Ref), slog.Bool("found", found), logging.Error(err))
+			return
+		}
+		payload = stored
+	}
+
+	var event Event
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		slog.Warn("Invalid chat event", logging.Error(err))
+		return
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	b := get(event.UserID)
+	for _, buffered := range b.events {
+		if buffered.MessageID == event.MessageID {
+			// Twitch resends messages it did not get response for in time
+			return
+		}
+	}
+	// every replica receives events in the same order, so they stay in it
+	event.Cursor = next()
+	b.events = append(b.events, event)
+	b.trim(time.Now())
+
+	close(b.wake)
+	b.wake = make(chan struct{})
+}
+
+// Wait returns up to limit events of user after cursor, waiting until there are
+// some or ctx is done. Missed is true when events after cursor were dropped
+// because bot did not ask for them in time.
+func Wait(ctx context.Context, userId string, cursor int64, limit int) (events []Event, missed bool, err error) {
+	mutex.Lock()
+	b := get(userId)
+	b.waiters++
+	defer func() {
+		mutex.Lock()
+		b.waiters--
+		mutex.Unlock()
+	}()
+
+	for {
+		b.trim(time.Now())
+		events = []Event{}
+		for _, event := range b.events {
+			if event.Cursor > cursor {
+				events = append(events, event)
+			}
+			if len(events) == limit {
+				break
+			}
+		}
+		missed = cursor < b.missed
+		wake := b.wake
+		mutex.Unlock()
+
+		if len(events) > 0 {
+			return events, missed, nil
+		}
+		select {
+		case <-ctx.Done():
+			return events, missed, ctx.Err()
+		case <-wake:
+		}
+		mutex.Lock()
+	}
+}
+
+// Loop listens for chat events published by all replicas and drops expired ones
+func Loop() {
+	for {
+		err := database.DB.Listen(context.Background(), channel, receive)
+		if err == nil {
+			break
+		}
+		slog.Error("Error listening for chat events", logging.Error(err))
+		time.Sleep(5 * time.Second)
+	}
+
+	for {
+		time.Sleep(time.Minute)
+
+		if err := database.DB.CleanChatPayloads(context.Background(), time.Now().Add(-retention)); err != nil {
+			slog.Error("Error cleaning stored chat events", logging.Error(err))
+		}
+
+		mutex.Lock()
+		for userId, b := range buffers {
+			b.trim(time.Now())
+			if len(b.events) == 0 && b.waiters == 0 {
+				delete(buffers, userId)
+			}
+		}
+		mutex.Unlock()
+	}
+}
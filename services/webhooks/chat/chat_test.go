@@ -0,0 +1,36 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestReceiveOrder(t *testing.T) {
+	cursor := Cursor()
+	for _, id := range []string{"first", "second", "first"} {
+		// publishers do not assign cursors, stale one must not move event back
+		payload, _ := json.Marshal(Event{Cursor: 1, MessageID: id, UserID: "order"})
+		receive(string(payload))
+	}
+
+	events, missed, err := Wait(context.Background(), "order", cursor, 10)
+	if err != nil || missed {
+		t.Fatalf("Wait() = %v, missed %v", err, missed)
+	}
+	if len(events) != 2 || events[0].MessageID != "first" || events[1].MessageID != "second" {
+		t.Fatalf("events = %+v, want first and second once", events)
+	}
+	if events[0].Cursor <= cursor || events[1].Cursor <= events[0].Cursor {
+		t.Errorf("cursors %d and %d do not follow %d in order of arrival", events[0].Cursor, events[1].Cursor, cursor)
+	}
+
+	// bot which got the second event does not get it again
+	after := events[1].Cursor
+	payload, _ := json.Marshal(Event{MessageID: "third", UserID: "order"})
+	receive(string(payload))
+	events, _, _ = Wait(context.Background(), "order", after, 10)
+	if len(events) != 1 || events[0].MessageID != "third" {
+		t.Errorf("events after %d = %+v, want third", after, events)
+	}
+}
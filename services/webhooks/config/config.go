@@ -0,0 +1,257 @@
+package config
+
+import (
+	"errors"
+	"net"
+	"services/shared/config"
+	"services/shared/logging"
+	"time"
+)
+
+type Config struct {
+	Env         string `env:"ENV" flag:"env" default:"production" oneof:"production development" usage:"environment, development is configured by DEV_* options"`
+	ListenAddr  string `env:"LISTEN_ADDR" flag:"listen" default:":8080" usage:"address of webhooks endpoint"`
+	CallbackURL string `env:"EVENTSUB_URL" flag:"callback-url" default:"https://eventsub.sogebot.xyz" usage:"public URL Twitch sends callbacks to"`
+
+	Debug     Debug
+	Callbacks Callbacks
+	Conduit   Conduit
+	Socket    Socket
+	Admin     Admin
+	Queue     Queue
+	GiftBomb  GiftBomb
+	Chat      Chat
+	Custom    Custom
+	Forward   Forward
+	Sinks     Sinks
+	Database  Database
+	Twitch    Twitch
+	Ngrok     Ngrok
+	Dev       Dev
+	Log       logging.Config
+}
+
+type Debug struct {
+	ListenAddr string `env:"DEBUG_LISTEN_ADDR" flag:"debug-listen" default:"127.0.0.1:8081" usage:"address of pprof and metrics endpoint"`
+	Username   string `env:"DEBUG_USERNAME" usage:"basic auth username of debug endpoint"`
+	Password   string `env:"DEBUG_PASSWORD" secret:"true" usage:"basic auth password of debug endpoint"`
+}
+
+type Admin struct {
+	Token string `env:"ADMIN_TOKEN" secret:"true" usage:"bearer token of admin API, admin API is disabled when empty"`
+}
+
+type Queue struct {
+	MaxPerUser     int    `env:"QUEUE_MAX_PER_USER" flag:"queue-max-per-user" default:"1000" usage:"maximum of queued events per user, 0 disables limit"`
+	OverflowPolicy string `env:"QUEUE_OVERFLOW_POLICY" flag:"queue-overflow-policy" default:"drop_oldest" oneof:"drop_oldest drop_duplicates collapse_progress" usage:"which events are dropped when queue is full"`
+}
+
+type GiftBomb struct {
+	Window time.Duration `env:"GIFT_BOMB_WINDOW" flag:"gift-bomb-window" default:"1m" usage:"how long gifted subscriptions are collected after channel.subscription.gift"`
+}
+
+type Chat struct {
+	Buffer    int           `env:"CHAT_BUFFER" flag:"chat-buffer" default:"500" usage:"chat events kept in memory per user for bots catching up"`
+	Retention time.Duration `env:"CHAT_RETENTION" flag:"chat-retention" default:"5m" usage:"how long chat events are kept in memory"`
+}
+
+type Custom struct {
+	Subscriptions []string `env:"CUSTOM_SUBSCRIPTIONS" flag:"custom-subscriptions" usage:"comma separated type@version:scopes bots can subscribe to, scopes are joined by + and alternatives by |"`
+	MaxPerUser    int      `env:"CUSTOM_SUBSCRIPTIONS_MAX_PER_USER" flag:"custom-subscriptions-max-per-user" default:"25" usage:"maximum of custom subscriptions requested by one user"`
+}
+
+type Callbacks struct {
+	Previous       []string `env:"EVENTSUB_PREVIOUS_URLS" flag:"previous-callback-urls" usage:"comma separated callback URLs used before EVENTSUB_URL, their subscriptions are moved to current transport"`
+	Batch          int      `env:"CALLBACK_MIGRATION_BATCH" flag:"callback-migration-batch" default:"50" usage:"subscriptions moved from previous callback URLs in one reconciliation pass"`
+	CleanupUnknown bool     `env:"CLEANUP_UNKNOWN_CALLBACKS" flag:"cleanup-unknown-callbacks" usage:"delete webhook subscriptions with unknown callback URL when webhook transport is used, they are only logged otherwise"`
+}
+
+type Forward struct {
+	Attempts     int           `env:"FORWARD_ATTEMPTS" flag:"forward-attempts" default:"8" usage:"delivery attempts of one event to user endpoint before it is dead-lettered"`
+	Backoff      time.Duration `env:"FORWARD_BACKOFF" flag:"forward-backoff" default:"5s" usage:"wait after first failed delivery, doubled after each next failure"`
+	MaxBackoff   time.Duration `env:"FORWARD_MAX_BACKOFF" flag:"forward-max-backoff" default:"10m" usage:"longest wait between delivery attempts"`
+	DisableAfter int           `env:"FORWARD_DISABLE_AFTER" flag:"forward-disable-after" default:"50" usage:"consecutive failed deliveries after which user endpoint is disabled"`
+	Timeout      time.Duration `env:"FORWARD_TIMEOUT" flag:"forward-timeout" default:"10s" usage:"timeout of one delivery"`
+	DeadLetters  int           `env:"FORWARD_DEAD_LETTERS" flag:"forward-dead-letters" default:"100" usage:"dead-lettered events kept per user"`
+	AllowPrivate bool          `env:"FORWARD_ALLOW_PRIVATE" flag:"forward-allow-private" usage:"allow user endpoints on loopback and private addresses, e.g. for self-hosted relays"`
+}
+
+type Sinks struct {
+	Sinks       []string      `env:"SINKS" flag:"sinks" usage:"comma separated sinks events are copied to: stdout, file:path or http:url, optionally followed by ;types=a|b.* and ;broadcasters=1|2 filters, logs go to stderr when stdout sink is used"`
+	Buffer      int           `env:"SINK_BUFFER" flag:"sink-buffer" default:"1000" usage:"events waiting for one sink, events are dropped when sink is behind"`
+	FileMaxSize int64         `env:"SINK_FILE_MAX_SIZE" flag:"sink-file-max-size" default:"104857600" usage:"size in bytes after which file sink is rotated"`
+	FileKeep    int           `env:"SINK_FILE_KEEP" flag:"sink-file-keep" default:"5" usage:"rotated files kept by file sink"`
+	HTTPTimeout time.Duration `env:"SINK_HTTP_TIMEOUT" flag:"sink-http-timeout" default:"5s" usage:"timeout of one request of http sink"`
+}
+
+type Conduit struct {
+	Transport  string `env:"EVENTSUB_TRANSPORT" flag:"transport" default:"webhook" oneof:"webhook conduit websocket" usage:"transport of created subscriptions, conduit spreads shards across replicas, websocket needs no public URL and uses tokens of bots"`
+	ID         string `env:"CONDUIT_ID" flag:"conduit-id" usage:"conduit to use, required when client already has conduit, new one is created and stored when empty"`
+	Shards     int    `env:"CONDUIT_SHARDS" flag:"conduit-shards" default:"4" usage:"number of conduit shards"`
+	InstanceID string `env:"INSTANCE_ID" flag:"instance-id" usage:"unique id of this replica, defaults to hostname"`
+	ShardURL   string `env:"CONDUIT_SHARD_URL" flag:"conduit-shard-url" usage:"public URL of this replica shards are sent to, defaults to EVENTSUB_URL"`
+}
+
+type Socket struct {
+	URL      string `env:"EVENTSUB_WEBSOCKET_URL" flag:"websocket-url" default:"wss://eventsub.wss.twitch.tv/ws" usage:"EventSub WebSocket server used by websocket transport"`
+	TokenKey string `env:"TOKEN_ENCRYPTION_KEY" secret:"true" usage:"base64 encoded 32 byte key stored user tokens are encrypted with, required by websocket transport"`
+}
+
+type Database struct {
+	Driver     string `env:"DATABASE_DRIVER" flag:"db-driver" default:"postgres" oneof:"postgres sqlite" usage:"storage backend"`
+	SQLitePath string `env:"SQLITE_PATH" flag:"sqlite-path" default:"webhooks.db" usage:"path to SQLite database, :memory: for in-memory"`
+
+	Host     string `env:"PG_HOST" flag:"pg-host"`
+	Port     string `env:"PG_PORT" flag:"pg-port" default:"5432"`
+	Username string `env:"PG_USERNAME" flag:"pg-username"`
+	Password string `env:"PG_PASSWORD" secret:"true"`
+	Name     string `env:"PG_DB" flag:"pg-db"`
+}
+
+type Twitch struct {
+	ClientID     string `env:"TWITCH_EVENTSUB_CLIENTID" required:"true"`
+	ClientSecret string `env:"TWITCH_EVENTSUB_CLIENTSECRET" secret:"true" required:"true"`
+	// Secret is used to sign EventSub callbacks
+	Secret string `env:"TWITCH_EVENTSUB_SECRET" secret:"true" required:"true"`
+
+	HelixURL string `env:"TWITCH_HELIX_URL" flag:"helix-url" default:"https://api.twitch.tv/helix" usage:"base URL of Twitch API"`
+	AuthURL  string `env:"TWITCH_AUTH_URL" flag:"auth-url" default:"https://id.twitch.tv/oauth2" usage:"base URL of Twitch OAuth"`
+}
+
+type Ngrok struct {
+	Authtoken string `env:"NGROK_AUTHTOKEN" secret:"true" usage:"ngrok authtoken, required by ngrok tunnel"`
+}
+
+// Dev is used only in development
+type Dev struct {
+	UserIDs   []string `env:"DEV_USER_IDS" flag:"dev-user-ids" usage:"comma separated users handled in development, all users when empty"`
+	Tunnel    string   `env:"DEV_TUNNEL" flag:"dev-tunnel" default:"ngrok" oneof:"none ngrok manual" usage:"how Twitch reaches callbacks, manual uses DEV_PUBLIC_URL of tunnel started by you, none listens only locally and needs DEV_MOCK_TWITCH"`
+	PublicURL string   `env:"DEV_PUBLIC_URL" flag:"dev-public-url" usage:"public URL of manual tunnel"`
+	// MockTwitch serves Twitch API and OAuth from fixtures on local address
+	MockTwitch bool   `env:"DEV_MOCK_TWITCH" flag:"dev-mock-twitch" usage:"answer Twitch API requests from recorded fixtures instead of calling Twitch"`
+	Fixtures   string `env:"DEV_FIXTURES" flag:"dev-fixtures" usage:"directory with fixtures used instead of the built-in ones with the same name"`
+}
+
+func (c *Callbacks) Validate() error {
+	if c.Batch < 1 {
+		return errors.New("CALLBACK_MIGRATION_BATCH must be at least 1")
+	}
+	return nil
+}
+
+func (g *GiftBomb) Validate() error {
+	if g.Window <= 0 {
+		return errors.New("GIFT_BOMB_WINDOW must be positive")
+	}
+	return nil
+}
+
+func (f *Forward) Validate() error {
+	if f.Attempts < 1 || f.DisableAfter < 1 || f.DeadLetters < 1 {
+		return errors.New("FORWARD_ATTEMPTS, FORWARD_DISABLE_AFTER and FORWARD_DEAD_LETTERS must be at least 1")
+	}
+	return nil
+}
+
+func (s *Sinks) Validate() error {
+	if s.Buffer < 1 || s.FileMaxSize < 1 || s.FileKeep < 0 {
+		return errors.New("SINK_BUFFER and SINK_FILE_MAX_SIZE must be at least 1, SINK_FILE_KEEP must not be negative")
+	}
+	return nil
+}
+
+func (c *Conduit) Validate() error {
+	if c.Transport == "conduit" && (c.Shards < 1 || c.Shards > 20000) {
+		return errors.New("CONDUIT_SHARDS must be between 1 and 20000")
+	}
+	return nil
+}
+
+// Validate requires connection settings of selected driver only
+func (d *Database) Validate() error {
+	if d.Driver == "sqlite" {
+		if d.SQLitePath == "" {
+			return errors.New("SQLITE_PATH is required for sqlite driver")
+		}
+		return nil
+	}
+
+	errs := config.Errors{}
+	for _, required := range []struct{ name, value string }{
+		{"PG_HOST", d.Host},
+		{"PG_USERNAME", d.Username},
+		{"PG_PASSWORD", d.Password},
+		{"PG_DB", d.Name},
+	} {
+		if required.value == "" {
+			errs = append(errs, errors.New(required.name+" is required for postgres driver"))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (c *Config) Development() bool {
+	return c.Env == "development"
+}
+
+// UsesWebSocket tells if events are received over WebSocket sessions instead of
+// callbacks, no tunnel or public URL is needed then
+func (c *Config) UsesWebSocket() bool {
+	return c.Conduit.Transport == "websocket"
+}
+
+// Tunnel returns tunnel used in development, WebSocket transport needs none
+func (c *Config) Tunnel() string {
+	if !c.Development() || c.UsesWebSocket() {
+		return "none"
+	}
+	return c.Dev.Tunnel
+}
+
+// LocalURL returns URL of webhooks endpoint on this host
+func (c *Config) LocalURL() string {
+	host, port, err := net.SplitHostPort(c.ListenAddr)
+	if err != nil {
+		return "http://" + c.ListenAddr
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+func (c *Config) Validate() error {
+	if c.Development() && c.Tunnel() == "ngrok" && c.Ngrok.Authtoken == "" {
+		return errors.New("NGROK_AUTHTOKEN is required by ngrok tunnel")
+	}
+	if c.Development() && c.Tunnel() == "manual" && c.Dev.PublicURL == "" {
+		return errors.New("DEV_PUBLIC_URL is required by manual tunnel")
+	}
+	if c.Development() && c.Tunnel() == "none" && !c.UsesWebSocket() && !c.Dev.MockTwitch {
+		// callbacks would point at EVENTSUB_URL, which is production by default
+		return errors.New("DEV_TUNNEL=none requires DEV_MOCK_TWITCH or websocket transport, Twitch can not reach local callbacks")
+	}
+	if !c.Development() && c.Dev.MockTwitch {
+		return errors.New("DEV_MOCK_TWITCH is allowed only in development")
+	}
+	if c.UsesWebSocket() && c.Socket.TokenKey == "" {
+		return errors.New("TOKEN_ENCRYPTION_KEY is required by websocket transport")
+	}
+	if (c.Debug.Username == "") != (c.Debug.Password == "") {
+		return errors.New("DEBUG_USERNAME and DEBUG_PASSWORD must be set together")
+	}
+	return nil
+}
+
+// Load reads configuration from environment, optional config file and args
+func Load(args []string) (*Config, []string, error) {
+	cfg := &Config{}
+	rest, err := config.Load(cfg, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, rest, nil
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		// wantErr is part of expected error, empty for valid configuration
+		wantErr string
+	}{
+		{
+			name: "production defaults",
+		},
+		{
+			name:    "ngrok without authtoken",
+			env:     map[string]string{"ENV": "development", "DEV_TUNNEL": "ngrok"},
+			wantErr: "NGROK_AUTHTOKEN",
+		},
+		{
+			name: "ngrok with authtoken",
+			env:  map[string]string{"ENV": "development", "DEV_TUNNEL": "ngrok", "NGROK_AUTHTOKEN": "token"},
+		},
+		{
+			name: "ngrok is not used in production",
+			env:  map[string]string{"DEV_TUNNEL": "ngrok"},
+		},
+		{
+			name:    "manual tunnel without public url",
+			env:     map[string]string{"ENV": "development", "DEV_TUNNEL": "manual"},
+			wantErr: "DEV_PUBLIC_URL",
+		},
+		{
+			name: "manual tunnel",
+			env:  map[string]string{"ENV": "development", "DEV_TUNNEL": "manual", "DEV_PUBLIC_URL": "https://dev.example.com"},
+		},
+		{
+			name:    "no tunnel with webhooks at Twitch",
+			env:     map[string]string{"ENV": "development", "DEV_TUNNEL": "none"},
+			wantErr: "DEV_TUNNEL=none",
+		},
+		{
+			name:    "no tunnel with conduit at Twitch",
+			env:     map[string]string{"ENV": "development", "DEV_TUNNEL": "none", "EVENTSUB_TRANSPORT": "conduit"},
+			wantErr: "DEV_TUNNEL=none",
+		},
+		{
+			name: "no tunnel with mocked Twitch",
+			env:  map[string]string{"ENV": "development", "DEV_TUNNEL": "none", "DEV_MOCK_TWITCH": "true"},
+		},
+		{
+			name: "websocket needs no tunnel",
+			env:  map[string]string{"ENV": "development", "EVENTSUB_TRANSPORT": "websocket", "TOKEN_ENCRYPTION_KEY": "key"},
+		},
+		{
+			name:    "mocked Twitch in production",
+			env:     map[string]string{"DEV_MOCK_TWITCH": "true"},
+			wantErr: "DEV_MOCK_TWITCH",
+		},
+		{
+			name:    "websocket without token encryption key",
+			env:     map[string]string{"EVENTSUB_TRANSPORT": "websocket"},
+			wantErr: "TOKEN_ENCRYPTION_KEY",
+		},
+		{
+			name:    "debug username without password",
+			env:     map[string]string{"DEBUG_USERNAME": "admin"},
+			wantErr: "DEBUG_USERNAME and DEBUG_PASSWORD",
+		},
+		{
+			name:    "debug password without username",
+			env:     map[string]string{"DEBUG_PASSWORD": "secret"},
+			wantErr: "DEBUG_USERNAME and DEBUG_PASSWORD",
+		},
+		{
+			name:    "callback migration batch",
+			env:     map[string]string{"CALLBACK_MIGRATION_BATCH": "0"},
+			wantErr: "CALLBACK_MIGRATION_BATCH",
+		},
+		{
+			name:    "gift bomb window",
+			env:     map[string]string{"GIFT_BOMB_WINDOW": "0s"},
+			wantErr: "GIFT_BOMB_WINDOW",
+		},
+		{
+			name:    "forward attempts",
+			env:     map[string]string{"FORWARD_ATTEMPTS": "0"},
+			wantErr: "FORWARD_ATTEMPTS",
+		},
+		{
+			name:    "sink buffer",
+			env:     map[string]string{"SINK_BUFFER": "0"},
+			wantErr: "SINK_BUFFER",
+		},
+		{
+			name:    "conduit shards",
+			env:     map[string]string{"EVENTSUB_TRANSPORT": "conduit", "CONDUIT_SHARDS": "20001"},
+			wantErr: "CONDUIT_SHARDS",
+		},
+		{
+			name: "conduit shards are ignored by webhooks",
+			env:  map[string]string{"CONDUIT_SHARDS": "0"},
+		},
+		{
+			name:    "postgres without connection",
+			env:     map[string]string{"DATABASE_DRIVER": "postgres"},
+			wantErr: "PG_HOST is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TWITCH_EVENTSUB_CLIENTID", "client")
+			t.Setenv("TWITCH_EVENTSUB_CLIENTSECRET", "client-secret")
+			t.Setenv("TWITCH_EVENTSUB_SECRET", "secret")
+			t.Setenv("DATABASE_DRIVER", "sqlite")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			_, _, err := Load(nil)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Load() = %v, want valid configuration", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() = %v, want error with %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLocalURL(t *testing.T) {
+	tests := []struct {
+		listen string
+		want   string
+	}{
+		{listen: ":8080", want: "http://127.0.0.1:8080"},
+		{listen: "0.0.0.0:8080", want: "http://127.0.0.1:8080"},
+		{listen: "[::]:8080", want: "http://127.0.0.1:8080"},
+		{listen: "127.0.0.1:9000", want: "http://127.0.0.1:9000"},
+		{listen: "localhost:8080", want: "http://localhost:8080"},
+		{listen: "[::1]:8080", want: "http://[::1]:8080"},
+	}
+	for _, tt := range tests {
+		if got := (&Config{ListenAddr: tt.listen}).LocalURL(); got != tt.want {
+			t.Errorf("LocalURL() of %q = %q, want %q", tt.listen, got, tt.want)
+		}
+	}
+}
@@ -1,94 +1,110 @@
 package database
 
 import (
-	"database/sql"
-	"fmt"
-	"log"
+	"context"
+	"log/slog"
 	"os"
-	"services/webhooks/commons"
+	"services/shared/logging"
+	"services/webhooks/config"
+	"services/webhooks/metrics"
 	"time"
 )
 
-var DB *sql.DB // Package-level variable to hold the db connection
+var DB Store // Package-level variable to hold the storage backend
 
-var noOfconnections int = 0
+func Init(cfg config.Database) {
+	Open(cfg)
 
-func Test() {
-	for {
-		if DB.Stats().OpenConnections != noOfconnections {
-			if DB.Stats().OpenConnections > noOfconnections {
-				fmt.Println("Connections Change: " + fmt.Sprint(DB.Stats().OpenConnections) + "(+" + fmt.Sprint(DB.Stats().OpenConnections-noOfconnections) + ")")
-			} else {
-				fmt.Println("Connections Change: " + fmt.Sprint(DB.Stats().OpenConnections) + "(" + fmt.Sprint(DB.Stats().OpenConnections-noOfconnections) + ")")
-			}
-			noOfconnections = DB.Stats().OpenConnections
-		}
-		time.Sleep(time.Second / 2)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	if err := DB.Migrate(ctx); err != nil {
+		slog.Error("Error migrating database", logging.Error(err))
+		os.Exit(1)
 	}
-}
 
-func Init() {
-	var PG_PASSWORD string = os.Getenv("PG_PASSWORD")
-	var PG_USERNAME string = os.Getenv("PG_USERNAME")
-	var PG_DB string = os.Getenv("PG_DB")
-	var PG_PORT string = os.Getenv("PG_PORT")
-	var PG_HOST string = os.Getenv("PG_HOST")
+	// clean events
+	go clean()
+	go queueDepth()
+	go reconnect()
+}
 
-	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		PG_USERNAME, PG_PASSWORD, PG_HOST, PG_PORT, PG_DB,
-	)
-	// note to myself:
-	// := cannot be used here as it creates new local variable, we need to use =
+// Open connects to the database without starting any background jobs
+func Open(cfg config.Database) {
 	var err error
-	DB, err = sql.Open("postgres", connStr)
-
-	DB.SetMaxOpenConns(10)
-	DB.SetMaxIdleConns(2)
-
-	status := DB.Ping()
-	if err != nil {
-		log.Fatal(err)
+	switch cfg.Driver {
+	case "sqlite":
+		DB, err = NewSQLite(cfg.SQLitePath)
+	default:
+		DB, err = NewPostgres(cfg)
 	}
-	if status != nil {
-		log.Fatal(status)
+	if err != nil {
+		slog.Error("Error opening database", slog.String("driver", cfg.Driver), logging.Error(err))
+		os.Exit(1)
 	}
 
-	// clean events
-	go clean()
-	go reconnect(connStr)
-	// go Test()
+	if err := DB.Ping(context.Background()); err != nil {
+		slog.Error("Error connecting to database", slog.String("driver", cfg.Driver), logging.Error(err))
+		os.Exit(1)
+	}
 }
 
-func reconnect(connStr string) {
-	maxRetries := 10
+// reconnect keeps checking the connection for the whole lifetime of the service,
+// database/sql redials by itself, so we only need to wait until it is back
+func reconnect() {
 	retryInterval := 5 * time.Second
-	for i := 0; i < maxRetries; i++ {
-		err := DB.Ping()
+	lost := false
+	for {
+		err := DB.Ping(context.Background())
 		if err != nil {
-			commons.Log(fmt.Sprintf("Lost connection to the database. Retrying in %v...\n", retryInterval))
+			if !lost {
+				slog.Warn("Lost connection to the database", logging.Error(err))
+			}
+			lost = true
+			slog.Info("Retrying database connection", slog.Duration("retry_in", retryInterval))
 			time.Sleep(retryInterval)
-
-			// Re-establish the connection
-			DB, err = sql.Open("postgres", connStr)
-			if err != nil {
-				fmt.Println("Error reconnecting to the database:", err)
-				continue
+			if retryInterval < time.Minute {
+				retryInterval *= 2
 			}
+			continue
 		}
 
+		if lost {
+			slog.Info("Connection to the database re-established")
+			lost = false
+			retryInterval = 5 * time.Second
+		}
 		time.Sleep(time.Second)
 	}
 }
 
+func Close() error {
+	return DB.Close()
+}
+
 func clean() {
 	for {
 		// clean events
-		commons.Log("Cleaning 1 hour old events.")
-		_, err := DB.Exec("DELETE FROM eventsub_events WHERE timestamp < NOW() - INTERVAL '1 hour'")
-		if err != nil {
-			commons.Log("Error cleaning events:" + err.Error())
+		slog.Info("Cleaning 1 hour old events.")
+		if err := DB.CleanEvents(context.Background(), time.Now().Add(-time.Hour)); err != nil {
+			slog.Error("Error cleaning events", logging.Error(err))
+		}
+		if err := DB.CleanGiftBombs(context.Background(), time.Now().Add(-24*time.Hour)); err != nil {
+			slog.Error("Error cleaning gift bombs", logging.Error(err))
 		}
 
 		time.Sleep(time.Hour)
 	}
 }
+
+func queueDepth() {
+	for {
+		depths, err := DB.QueueDepths(context.Background())
+		if err != nil {
+			slog.Error("Error counting events", logging.Error(err))
+		} else {
+			metrics.SetQueueDepths(depths)
+		}
+
+		time.Sleep(15 * time.Second)
+	}
+}
@@ -0,0 +1,23 @@
+package database
+
+import (
+	"context"
+	"slices"
+)
+
+// UnlinkBot removes link of bot to broadcaster after Twitch revoked or refused
+// its subscription, broadcaster is marked updated, so another account can subscribe
+func UnlinkBot(ctx context.Context, botId string, broadcasterId string) error {
+	moderates, err := DB.BotLinks(ctx, botId)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(moderates, broadcasterId) {
+		return nil
+	}
+	moderates = slices.DeleteFunc(moderates, func(id string) bool { return id == broadcasterId })
+	if err := DB.SetBotLinks(ctx, botId, moderates); err != nil {
+		return err
+	}
+	return DB.MarkUpdated(ctx, broadcasterId)
+}
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS eventsub_events (
+    userid VARCHAR(255) NOT NULL,
+    event VARCHAR(255) NOT NULL,
+    data TEXT NOT NULL,
+    "timestamp" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS eventsub_events_userid_timestamp ON eventsub_events (userid, "timestamp");
@@ -0,0 +1,3 @@
+ALTER TABLE eventsub_events ADD COLUMN IF NOT EXISTS id BIGSERIAL PRIMARY KEY;
+
+ALTER TABLE eventsub_users ADD COLUMN IF NOT EXISTS dropped INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,6 @@
+ALTER TABLE eventsub_events ADD COLUMN IF NOT EXISTS message_id VARCHAR(255);
+ALTER TABLE eventsub_events ADD COLUMN IF NOT EXISTS version VARCHAR(32) NOT NULL DEFAULT '';
+ALTER TABLE eventsub_events ADD COLUMN IF NOT EXISTS broadcaster_id VARCHAR(255) NOT NULL DEFAULT '';
+
+-- Twitch resends messages, message id lets us ignore duplicates
+CREATE UNIQUE INDEX IF NOT EXISTS eventsub_events_message_id ON eventsub_events (message_id);
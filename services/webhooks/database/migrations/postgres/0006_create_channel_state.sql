@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS channel_state (
+    broadcaster_id VARCHAR(255) NOT NULL PRIMARY KEY,
+    data TEXT NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS stream_sessions (
+    id SERIAL PRIMARY KEY,
+    broadcaster_id VARCHAR(255) NOT NULL,
+    stream_id VARCHAR(255) NOT NULL,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ended_at TIMESTAMP WITH TIME ZONE,
+    title TEXT NOT NULL DEFAULT '',
+    category_id VARCHAR(255) NOT NULL DEFAULT '',
+    category_name TEXT NOT NULL DEFAULT ''
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS stream_sessions_stream_id ON stream_sessions (stream_id);
+CREATE INDEX IF NOT EXISTS stream_sessions_broadcaster_started ON stream_sessions (broadcaster_id, started_at);
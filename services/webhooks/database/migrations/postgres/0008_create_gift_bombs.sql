@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS gift_bombs (
+    id SERIAL PRIMARY KEY,
+    broadcaster_id VARCHAR(255) NOT NULL,
+    gifter_id VARCHAR(255) NOT NULL DEFAULT '',
+    gifter_login VARCHAR(255) NOT NULL DEFAULT '',
+    gifter_name VARCHAR(255) NOT NULL DEFAULT '',
+    is_anonymous BOOLEAN NOT NULL DEFAULT false,
+    tier VARCHAR(32) NOT NULL,
+    total INTEGER NOT NULL,
+    cumulative_total INTEGER,
+    received INTEGER NOT NULL DEFAULT 0,
+    recipients TEXT NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    finished BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE INDEX IF NOT EXISTS gift_bombs_open ON gift_bombs (broadcaster_id, finished, created_at);
+
+ALTER TABLE eventsub_events ADD COLUMN IF NOT EXISTS correlation_id VARCHAR(255) NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS pending_gift_recipients (
+    id SERIAL PRIMARY KEY,
+    broadcaster_id VARCHAR(255) NOT NULL,
+    tier VARCHAR(32) NOT NULL,
+    message_id VARCHAR(255) NOT NULL DEFAULT '',
+    user_id VARCHAR(255) NOT NULL,
+    user_login VARCHAR(255) NOT NULL DEFAULT '',
+    user_name VARCHAR(255) NOT NULL DEFAULT '',
+    received_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS pending_gift_recipients_lookup ON pending_gift_recipients (broadcaster_id, tier, received_at);
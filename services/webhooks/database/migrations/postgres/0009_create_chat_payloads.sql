@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS chat_payloads (
+    message_id VARCHAR(255) NOT NULL PRIMARY KEY,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS chat_payloads_created_at ON chat_payloads (created_at);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS bot_links (
+    bot_id VARCHAR(255) NOT NULL,
+    broadcaster_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (bot_id, broadcaster_id)
+);
+
+CREATE INDEX IF NOT EXISTS bot_links_broadcaster ON bot_links (broadcaster_id);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS subscription_migrations (
+    id SERIAL PRIMARY KEY,
+    broadcaster_id VARCHAR(255) NOT NULL,
+    type VARCHAR(255) NOT NULL,
+    from_version VARCHAR(32) NOT NULL,
+    to_version VARCHAR(32) NOT NULL,
+    old_subscription_id VARCHAR(255) NOT NULL UNIQUE,
+    status VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS subscription_migrations_broadcaster ON subscription_migrations (broadcaster_id, type, to_version);
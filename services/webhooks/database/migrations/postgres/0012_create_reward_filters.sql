@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS reward_filters (
+    broadcaster_id VARCHAR(255) NOT NULL,
+    reward_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (broadcaster_id, reward_id)
+);
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS custom_subscriptions (
+    id SERIAL PRIMARY KEY,
+    requester_id VARCHAR(255) NOT NULL,
+    broadcaster_id VARCHAR(255) NOT NULL,
+    type VARCHAR(255) NOT NULL,
+    version VARCHAR(32) NOT NULL,
+    condition TEXT NOT NULL,
+    deleted BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS custom_subscriptions_unique ON custom_subscriptions (type, version, condition);
+CREATE INDEX IF NOT EXISTS custom_subscriptions_broadcaster ON custom_subscriptions (broadcaster_id);
+CREATE INDEX IF NOT EXISTS custom_subscriptions_requester ON custom_subscriptions (requester_id);
+
+CREATE TABLE IF NOT EXISTS custom_subscription_requesters (
+    subscription_id INTEGER NOT NULL,
+    requester_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (subscription_id, requester_id)
+);
+
+CREATE INDEX IF NOT EXISTS custom_subscription_requesters_requester ON custom_subscription_requesters (requester_id);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS conduit_instances (
+    instance_id VARCHAR(255) NOT NULL PRIMARY KEY,
+    callback VARCHAR(1024) NOT NULL,
+    heartbeat_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS conduits (
+    conduit_id VARCHAR(255) NOT NULL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS eventsub_users (
+    "userId" TEXT NOT NULL PRIMARY KEY,
+    scopes TEXT NOT NULL DEFAULT '',
+    updated BOOLEAN NOT NULL DEFAULT false
+);
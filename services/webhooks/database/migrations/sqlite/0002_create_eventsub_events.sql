@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS eventsub_events (
+    userid TEXT NOT NULL,
+    event TEXT NOT NULL,
+    data TEXT NOT NULL,
+    "timestamp" TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS eventsub_events_userid_timestamp ON eventsub_events (userid, "timestamp");
@@ -0,0 +1,19 @@
+-- sqlite cannot add primary key to existing table
+CREATE TABLE eventsub_events_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    userid TEXT NOT NULL,
+    event TEXT NOT NULL,
+    data TEXT NOT NULL,
+    "timestamp" TIMESTAMP NOT NULL
+);
+
+INSERT INTO eventsub_events_new (userid, event, data, "timestamp")
+    SELECT userid, event, data, "timestamp" FROM eventsub_events ORDER BY "timestamp";
+
+DROP TABLE eventsub_events;
+
+ALTER TABLE eventsub_events_new RENAME TO eventsub_events;
+
+CREATE INDEX IF NOT EXISTS eventsub_events_userid_timestamp ON eventsub_events (userid, "timestamp");
+
+ALTER TABLE eventsub_users ADD COLUMN dropped INTEGER NOT NULL DEFAULT 0;
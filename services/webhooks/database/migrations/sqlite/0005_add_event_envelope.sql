@@ -0,0 +1,6 @@
+ALTER TABLE eventsub_events ADD COLUMN message_id TEXT;
+ALTER TABLE eventsub_events ADD COLUMN version TEXT NOT NULL DEFAULT '';
+ALTER TABLE eventsub_events ADD COLUMN broadcaster_id TEXT NOT NULL DEFAULT '';
+
+-- Twitch resends messages, message id lets us ignore duplicates
+CREATE UNIQUE INDEX IF NOT EXISTS eventsub_events_message_id ON eventsub_events (message_id);
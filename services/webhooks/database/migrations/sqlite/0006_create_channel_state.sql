@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS channel_state (
+    broadcaster_id TEXT NOT NULL PRIMARY KEY,
+    data TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS stream_sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    broadcaster_id TEXT NOT NULL,
+    stream_id TEXT NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP,
+    title TEXT NOT NULL DEFAULT '',
+    category_id TEXT NOT NULL DEFAULT '',
+    category_name TEXT NOT NULL DEFAULT ''
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS stream_sessions_stream_id ON stream_sessions (stream_id);
+CREATE INDEX IF NOT EXISTS stream_sessions_broadcaster_started ON stream_sessions (broadcaster_id, started_at);
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS gift_bombs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    broadcaster_id TEXT NOT NULL,
+    gifter_id TEXT NOT NULL DEFAULT '',
+    gifter_login TEXT NOT NULL DEFAULT '',
+    gifter_name TEXT NOT NULL DEFAULT '',
+    is_anonymous BOOLEAN NOT NULL DEFAULT false,
+    tier TEXT NOT NULL,
+    total INTEGER NOT NULL,
+    cumulative_total INTEGER,
+    received INTEGER NOT NULL DEFAULT 0,
+    recipients TEXT NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP NOT NULL,
+    finished BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE INDEX IF NOT EXISTS gift_bombs_open ON gift_bombs (broadcaster_id, finished, created_at);
+
+ALTER TABLE eventsub_events ADD COLUMN correlation_id TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS pending_gift_recipients (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    broadcaster_id TEXT NOT NULL,
+    tier TEXT NOT NULL,
+    message_id TEXT NOT NULL DEFAULT '',
+    user_id TEXT NOT NULL,
+    user_login TEXT NOT NULL DEFAULT '',
+    user_name TEXT NOT NULL DEFAULT '',
+    received_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS pending_gift_recipients_lookup ON pending_gift_recipients (broadcaster_id, tier, received_at);
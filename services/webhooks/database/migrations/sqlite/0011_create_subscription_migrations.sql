@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS subscription_migrations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    broadcaster_id TEXT NOT NULL,
+    type TEXT NOT NULL,
+    from_version TEXT NOT NULL,
+    to_version TEXT NOT NULL,
+    old_subscription_id TEXT NOT NULL UNIQUE,
+    status TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS subscription_migrations_broadcaster ON subscription_migrations (broadcaster_id, type, to_version);
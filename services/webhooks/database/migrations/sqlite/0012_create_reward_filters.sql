@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS reward_filters (
+    broadcaster_id TEXT NOT NULL,
+    reward_id TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (broadcaster_id, reward_id)
+);
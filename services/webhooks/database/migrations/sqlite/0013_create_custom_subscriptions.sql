@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS custom_subscriptions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    requester_id TEXT NOT NULL,
+    broadcaster_id TEXT NOT NULL,
+    type TEXT NOT NULL,
+    version TEXT NOT NULL,
+    condition TEXT NOT NULL,
+    deleted BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS custom_subscriptions_unique ON custom_subscriptions (type, version, condition);
+CREATE INDEX IF NOT EXISTS custom_subscriptions_broadcaster ON custom_subscriptions (broadcaster_id);
+CREATE INDEX IF NOT EXISTS custom_subscriptions_requester ON custom_subscriptions (requester_id);
+
+CREATE TABLE IF NOT EXISTS custom_subscription_requesters (
+    subscription_id INTEGER NOT NULL,
+    requester_id TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (subscription_id, requester_id)
+);
+
+CREATE INDEX IF NOT EXISTS custom_subscription_requesters_requester ON custom_subscription_requesters (requester_id);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS conduit_instances (
+    instance_id TEXT NOT NULL PRIMARY KEY,
+    callback TEXT NOT NULL,
+    heartbeat_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS conduits (
+    conduit_id TEXT NOT NULL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL
+);
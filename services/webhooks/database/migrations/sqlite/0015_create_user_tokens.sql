@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS user_tokens (
+    user_id TEXT NOT NULL PRIMARY KEY,
+    access_token TEXT NOT NULL,
+    refresh_token TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL
+);
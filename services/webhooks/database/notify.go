@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// notifier delivers published payloads to listeners, Postgres uses
+// LISTEN/NOTIFY so all replicas get them, SQLite stays in process
+type notifier interface {
+	publish(ctx context.Context, channel string, payload string) error
+	listen(ctx context.Context, channel string, handler func(payload string)) error
+}
+
+func (s *sqlStore) Publish(ctx context.Context, channel string, payload string) error {
+	return s.notifier.publish(ctx, channel, payload)
+}
+
+func (s *sqlStore) Listen(ctx context.Context, channel string, handler func(payload string)) error {
+	return s.notifier.listen(ctx, channel, handler)
+}
+
+func (s *sqlStore) StoreChatPayload(ctx context.Context, messageId string, payload string, createdAt time.Time) error {
+	return s.exec(ctx, `INSERT INTO chat_payloads (message_id, payload, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (message_id) DO NOTHING`, messageId, payload, createdAt.UTC())
+}
+
+func (s *sqlStore) ChatPayload(ctx context.Context, messageId string) (string, bool, error) {
+	var payload string
+	err := s.db.QueryRowContext(ctx, `SELECT payload FROM chat_payloads WHERE message_id=$1`, messageId).Scan(&payload)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	return payload, err == nil, err
+}
+
+func (s *sqlStore) CleanChatPayloads(ctx context.Context, before time.Time) error {
+	return s.exec(ctx, `DELETE FROM chat_payloads WHERE created_at < $1`, before.UTC())
+}
+
+// localNotifier calls handlers of the same process synchronously
+type localNotifier struct {
+	mutex    sync.RWMutex
+	next     int
+	handlers map[string]map[int]func(payload string)
+}
+
+func newLocalNotifier() *localNotifier {
+	return &localNotifier{handlers: map[string]map[int]func(payload string){}}
+}
+
+func (n *localNotifier) publish(ctx context.Context, channel string, payload string) error {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	for _, handler := range n.handlers[channel] {
+		handler(payload)
+	}
+	return nil
+}
+
+func (n *localNotifier) listen(ctx context.Context, channel string, handler func(payload string)) error {
+	n.mutex.Lock()
+	id := n.next
+	n.next++
+	if n.handlers[channel] == nil {
+		n.handlers[channel] = map[int]func(payload string){}
+	}
+	n.handlers[channel][id] = handler
+	n.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		n.mutex.Lock()
+		delete(n.handlers[channel], id)
+		n.mutex.Unlock()
+	}()
+	return nil
+}
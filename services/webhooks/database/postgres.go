@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/url"
+	"services/shared/logging"
+	"services/webhooks/config"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// migrationLock is key of advisory lock held while migrating, so only one
+// replica applies migrations at a time
+const migrationLock = 7345626
+
+var postgres = dialect{
+	name: "postgres",
+	lock: func(ctx context.Context, conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLock)
+		return err
+	},
+	unlock: func(ctx context.Context, conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLock)
+		return err
+	},
+	migrationsTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	)`,
+	time: func(t time.Time) any {
+		return t
+	},
+	forUpdate: " FOR UPDATE",
+}
+
+func NewPostgres(cfg config.Database) (Store, error) {
+	connStr := (&url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.Username, cfg.Password),
+		Host:     cfg.Host + ":" + cfg.Port,
+		Path:     cfg.Name,
+		RawQuery: "sslmode=disable",
+	}).String()
+
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(10)
+	db.SetMaxIdleConns(2)
+
+	return &sqlStore{db: db, dialect: postgres, notifier: &pgNotifier{db: db, connStr: connStr}}, nil
+}
+
+// pgNotifier uses NOTIFY, every listen holds its own connection outside of pool
+type pgNotifier struct {
+	db      *sql.DB
+	connStr string
+}
+
+// publish fails for payloads over 8000 bytes, the limit of NOTIFY
+func (n *pgNotifier) publish(ctx context.Context, channel string, payload string) error {
+	_, err := n.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, channel, payload)
+	return err
+}
+
+func (n *pgNotifier) listen(ctx context.Context, channel string, handler func(payload string)) error {
+	listener := pq.NewListener(n.connStr, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Warn("Database listener error", slog.String("channel", channel), logging.Error(err))
+		}
+	})
+	if err := listener.Listen(channel); err != nil {
+		listener.Close()
+		return err
+	}
+
+	go func() {
+		defer listener.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case notification := <-listener.Notify:
+				// nil is sent after reconnect, notifications sent meanwhile are lost
+				if notification != nil {
+					handler(notification.Extra)
+				}
+			case <-time.After(90 * time.Second):
+				go listener.Ping()
+			}
+		}
+	}()
+	return nil
+}
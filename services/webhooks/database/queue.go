@@ -0,0 +1,27 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"services/shared/logging"
+	"services/webhooks/config"
+	"services/webhooks/metrics"
+)
+
+var queue config.Queue
+
+func ConfigureQueue(cfg config.Queue) {
+	queue = cfg
+}
+
+// Queue stores event in user queue and applies queue limit, every queued event
+// goes through it. Returns false when event with the same message id is already queued.
+func Queue(ctx context.Context, event Event) (bool, error) {
+	policy := QueuePolicy(queue.OverflowPolicy)
+	inserted, dropped, err := DB.QueueEvent(ctx, event, queue.MaxPerUser, policy)
+	if dropped > 0 {
+		logging.FromContext(ctx).Warn("User queue is full, events dropped", logging.UserID(event.UserID), slog.Int64("dropped", dropped), slog.String("policy", string(policy)))
+		metrics.EventsDropped.WithLabelValues(string(policy)).Add(float64(dropped))
+	}
+	return inserted, err
+}
@@ -0,0 +1,19 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"services/shared/logging"
+)
+
+// PruneRewardFilter removes filter of reward which was deleted or which Twitch
+// refuses, broadcaster is marked updated, so reconciler can finish switch of
+// its redemption subscriptions without waiting for the reward
+func PruneRewardFilter(ctx context.Context, broadcasterId string, rewardId string) error {
+	removed, err := DB.RemoveRewardFilter(ctx, broadcasterId, rewardId)
+	if err != nil || !removed {
+		return err
+	}
+	logging.FromContext(ctx).Info("Reward filter removed", logging.UserID(broadcasterId), slog.String("reward_id", rewardId))
+	return DB.MarkUpdated(ctx, broadcasterId)
+}
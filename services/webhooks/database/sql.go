@@ -0,0 +1,1135 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// dialect holds differences between SQL databases, queries itself are shared
+type dialect struct {
+	name string
+	// lock and unlock are called on single connection around migrations
+	lock   func(ctx context.Context, conn *sql.Conn) error
+	unlock func(ctx context.Context, conn *sql.Conn) error
+	// migrationsTable is DDL of table with applied migrations
+	migrationsTable string
+	// time converts time argument to value stored by database
+	time func(t time.Time) any
+	// forUpdate is appended to selects of rows updated in the same transaction
+	forUpdate string
+}
+
+// sqlStore implements Store on top of database/sql, queries use $N placeholders
+// which are supported by both Postgres and SQLite drivers
+type sqlStore struct {
+	db       *sql.DB
+	dialect  dialect
+	notifier notifier
+}
+
+func (s *sqlStore) args(args ...any) []any {
+	for i, arg := range args {
+		if t, ok := arg.(time.Time); ok {
+			args[i] = s.dialect.time(t)
+		}
+	}
+	return args
+}
+
+func (s *sqlStore) exec(ctx context.Context, query string, args ...any) error {
+	_, err := s.db.ExecContext(ctx, query, s.args(args...)...)
+	return err
+}
+
+func (s *sqlStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+const userColumns = `"userId", scopes, updated, last_seen`
+
+func scanUser(row interface{ Scan(...any) error }) (User, error) {
+	user := User{}
+	var lastSeen sql.NullTime
+	if err := row.Scan(&user.ID, &user.Scopes, &user.Updated, &lastSeen); err != nil {
+		return user, err
+	}
+	if lastSeen.Valid {
+		user.LastSeen = &lastSeen.Time
+	}
+	return user, nil
+}
+
+func scanUsers(rows *sql.Rows) ([]User, error) {
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (s *sqlStore) GetUser(ctx context.Context, userId string) (User, bool, error) {
+	user, err := scanUser(s.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM eventsub_users WHERE "userId"=$1`, userId))
+	if err == sql.ErrNoRows {
+		return user, false, nil
+	}
+	if err != nil {
+		return user, false, err
+	}
+	return user, true, nil
+}
+
+func (s *sqlStore) CreateUser(ctx context.Context, userId string, scopes string) error {
+	return s.exec(ctx, `INSERT INTO eventsub_users ("userId", scopes) VALUES ($1, $2)`, userId, scopes)
+}
+
+func (s *sqlStore) UpdateUserScopes(ctx context.Context, userId string, scopes string) error {
+	return s.exec(ctx, `UPDATE eventsub_users SET scopes=$1, updated=$2 WHERE "userId"=$3`, scopes, true, userId)
+}
+
+func (s *sqlStore) DeleteUser(ctx context.Context, userId string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`DELETE FROM bot_links WHERE bot_id=$1 OR broadcaster_id=$1`,
+		`DELETE FROM reward_filters WHERE broadcaster_id=$1`,
+		`DELETE FROM user_tokens WHERE user_id=$1`,
+		`DELETE FROM forward_endpoints WHERE user_id=$1`,
+		`DELETE FROM forward_dead_letters WHERE user_id=$1`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, userId); err != nil {
+			return err
+		}
+	}
+
+	// subscriptions nobody else requested are deleted at Twitch by reconciler
+	rows, err := tx.QueryContext(ctx, `SELECT subscription_id FROM custom_subscription_requesters WHERE requester_id=$1 ORDER BY subscription_id`, userId)
+	if err != nil {
+		return err
+	}
+	requested := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		requested = append(requested, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, id := range requested {
+		if _, err := s.removeCustomRequester(ctx, tx, id, userId); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM eventsub_users WHERE "userId"=$1`, userId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) ListUsers(ctx context.Context, updatedOnly bool) ([]User, error) {
+	var rows *sql.Rows
+	var err error
+	if updatedOnly {
+		rows, err = s.db.QueryContext(ctx, `SELECT `+userColumns+` FROM eventsub_users WHERE updated=$1`, true)
+	} else {
+		rows, err = s.db.QueryContext(ctx, `SELECT `+userColumns+` FROM eventsub_users`)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return scanUsers(rows)
+}
+
+func (s *sqlStore) SearchUsers(ctx context.Context, query string, limit int) ([]User, error) {
+	// escape LIKE wildcards, user ids are numeric anyway
+	query = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(query)
+	rows, err := s.db.QueryContext(ctx, `SELECT `+userColumns+` FROM eventsub_users WHERE "userId" LIKE $1 ESCAPE '\' ORDER BY "userId" LIMIT $2`, query+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanUsers(rows)
+}
+
+func (s *sqlStore) ResetUpdated(ctx context.Context) error {
+	return s.exec(ctx, `UPDATE eventsub_users SET updated=$1`, false)
+}
+
+func (s *sqlStore) MarkUpdated(ctx context.Context, userId string) error {
+	return s.exec(ctx, `UPDATE eventsub_users SET updated=$1 WHERE "userId"=$2`, true, userId)
+}
+
+func (s *sqlStore) TouchUser(ctx context.Context, userId string, seen time.Time) error {
+	return s.exec(ctx, `UPDATE eventsub_users SET last_seen=$1 WHERE "userId"=$2`, seen.UTC(), userId)
+}
+
+func (s *sqlStore) BotLinks(ctx context.Context, botId string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT broadcaster_id FROM bot_links WHERE bot_id=$1 ORDER BY broadcaster_id`, botId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	broadcasterIds := []string{}
+	for rows.Next() {
+		var broadcasterId string
+		if err := rows.Scan(&broadcasterId); err != nil {
+			return nil, err
+		}
+		broadcasterIds = append(broadcasterIds, broadcasterId)
+	}
+	return broadcasterIds, rows.Err()
+}
+
+func (s *sqlStore) SetBotLinks(ctx context.Context, botId string, broadcasterIds []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM bot_links WHERE bot_id=$1`, botId); err != nil {
+		return err
+	}
+	for _, broadcasterId := range broadcasterIds {
+		_, err := tx.ExecContext(ctx, `INSERT INTO bot_links (bot_id, broadcaster_id, created_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+			s.args(botId, broadcasterId, time.Now().UTC())...,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) LinkedBots(ctx context.Context, broadcasterId string) ([]User, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT u."userId", u.scopes, u.updated, u.last_seen FROM eventsub_users u
+		JOIN bot_links l ON l.bot_id=u."userId" WHERE l.broadcaster_id=$1 ORDER BY l.created_at, u."userId"`, broadcasterId)
+	if err != nil {
+		return nil, err
+	}
+	return scanUsers(rows)
+}
+
+func (s *sqlStore) RewardFilters(ctx context.Context, broadcasterId string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT reward_id FROM reward_filters WHERE broadcaster_id=$1 ORDER BY reward_id`, broadcasterId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rewardIds := []string{}
+	for rows.Next() {
+		var rewardId string
+		if err := rows.Scan(&rewardId); err != nil {
+			return nil, err
+		}
+		rewardIds = append(rewardIds, rewardId)
+	}
+	return rewardIds, rows.Err()
+}
+
+func (s *sqlStore) SetRewardFilters(ctx context.Context, broadcasterId string, rewardIds []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM reward_filters WHERE broadcaster_id=$1`, broadcasterId); err != nil {
+		return err
+	}
+	for _, rewardId := range rewardIds {
+		_, err := tx.ExecContext(ctx, `INSERT INTO reward_filters (broadcaster_id, reward_id, created_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+			s.args(broadcasterId, rewardId, time.Now().UTC())...,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) RemoveRewardFilter(ctx context.Context, broadcasterId string, rewardId string) (bool, error) {
+	removed, err := s.affected(ctx, `DELETE FROM reward_filters WHERE broadcaster_id=$1 AND reward_id=$2`, broadcasterId, rewardId)
+	return removed > 0, err
+}
+
+// querier is *sql.DB or *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (s *sqlStore) InsertEvent(ctx context.Context, event Event) (bool, error) {
+	return s.insertEvent(ctx, s.db, event)
+}
+
+func (s *sqlStore) insertEvent(ctx context.Context, q querier, event Event) (bool, error) {
+	// NULL message ids are not unique, so events without id are always inserted
+	var messageId sql.NullString
+	if event.MessageID != "" {
+		messageId = sql.NullString{String: event.MessageID, Valid: true}
+	}
+	result, err := q.ExecContext(ctx, `INSERT INTO eventsub_events (message_id, userid, event, version, broadcaster_id, correlation_id, data, "timestamp")
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT (message_id) DO NOTHING`,
+		s.args(messageId, event.UserID, event.Type, event.Version, event.BroadcasterID, event.CorrelationID, event.Data, event.Timestamp.UTC())...,
+	)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := result.RowsAffected()
+	return inserted > 0, err
+}
+
+const eventColumns = `id, message_id, userid, event, version, broadcaster_id, correlation_id, data, "timestamp"`
+
+func scanEvent(row interface{ Scan(...any) error }) (Event, error) {
+	event := Event{}
+	var messageId sql.NullString
+	err := row.Scan(&event.ID, &messageId, &event.UserID, &event.Type, &event.Version, &event.BroadcasterID, &event.CorrelationID, &event.Data, &event.Timestamp)
+	event.MessageID = messageId.String
+	return event, err
+}
+
+func (s *sqlStore) NextEvent(ctx context.Context, userId string) (Event, bool, error) {
+	event, err := scanEvent(s.db.QueryRowContext(ctx, `SELECT `+eventColumns+` FROM eventsub_events WHERE userid=$1 ORDER BY "timestamp" ASC, id ASC LIMIT 1`, userId))
+	if err == sql.ErrNoRows {
+		return event, false, nil
+	}
+	if err != nil {
+		return event, false, err
+	}
+	return event, true, nil
+}
+
+func (s *sqlStore) NextEvents(ctx context.Context, userId string, limit int) ([]Event, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+eventColumns+` FROM eventsub_events WHERE userid=$1 ORDER BY "timestamp" ASC, id ASC LIMIT $2`, userId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func (s *sqlStore) DeleteEvent(ctx context.Context, event Event) error {
+	return s.exec(ctx, `DELETE FROM eventsub_events WHERE id=$1`, event.ID)
+}
+
+func (s *sqlStore) affected(ctx context.Context, query string, args ...any) (int64, error) {
+	result, err := s.db.ExecContext(ctx, query, s.args(args...)...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *sqlStore) QueueEvent(ctx context.Context, event Event, max int, policy QueuePolicy) (bool, int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, 0, err
+	}
+	defer tx.Rollback()
+
+	// concurrent events of user wait here, so each trim sees the other insert
+	var userId string
+	err = tx.QueryRowContext(ctx, `SELECT "userId" FROM eventsub_users WHERE "userId"=$1`+s.dialect.forUpdate, event.UserID).Scan(&userId)
+	if err != nil && err != sql.ErrNoRows {
+		return false, 0, err
+	}
+
+	inserted, err := s.insertEvent(ctx, tx, event)
+	if err != nil || !inserted {
+		return inserted, 0, err
+	}
+	var dropped int64
+	if max > 0 {
+		if dropped, err = s.trimQueue(ctx, tx, event.UserID, max, policy, event.Type); err != nil {
+			return false, 0, err
+		}
+	}
+	return true, dropped, tx.Commit()
+}
+
+func (s *sqlStore) trimQueue(ctx context.Context, q querier, userId string, max int, policy QueuePolicy, eventType string) (int64, error) {
+	affected := func(query string, args ...any) (int64, error) {
+		result, err := q.ExecContext(ctx, query, s.args(args...)...)
+		if err != nil {
+			return 0, err
+		}
+		return result.RowsAffected()
+	}
+
+	var depth int
+	if err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM eventsub_events WHERE userid=$1`, userId).Scan(&depth); err != nil || depth <= max {
+		return 0, err
+	}
+
+	var dropped int64
+	var err error
+	switch policy {
+	case DropDuplicates:
+		dropped, err = affected(`DELETE FROM eventsub_events WHERE userid=$1 AND event=$2
+			AND id < (SELECT MAX(id) FROM eventsub_events WHERE userid=$1 AND event=$2)`, userId, eventType)
+	case CollapseProgress:
+		dropped, err = affected(`DELETE FROM eventsub_events WHERE userid=$1 AND event LIKE '%.progress'
+			AND id NOT IN (SELECT MAX(id) FROM eventsub_events WHERE userid=$1 AND event LIKE '%.progress' GROUP BY event)`, userId)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if over := depth - int(dropped) - max; over > 0 {
+		oldest, err := affected(`DELETE FROM eventsub_events WHERE id IN
+			(SELECT id FROM eventsub_events WHERE userid=$1 ORDER BY "timestamp" ASC, id ASC LIMIT $2)`, userId, over)
+		if err != nil {
+			return 0, err
+		}
+		dropped += oldest
+	}
+
+	if dropped > 0 {
+		_, err = affected(`UPDATE eventsub_users SET dropped=dropped+$1 WHERE "userId"=$2`, dropped, userId)
+	}
+	return dropped, err
+}
+
+func (s *sqlStore) Dropped(ctx context.Context, userId string) (int64, error) {
+	var dropped int64
+	err := s.db.QueryRowContext(ctx, `SELECT dropped FROM eventsub_users WHERE "userId"=$1`, userId).Scan(&dropped)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return dropped, err
+}
+
+func (s *sqlStore) AckDropped(ctx context.Context, userId string, dropped int64) error {
+	if dropped == 0 {
+		return nil
+	}
+	// subtract instead of reset, so drops counted meanwhile are not lost
+	return s.exec(ctx, `UPDATE eventsub_users SET dropped=dropped-$1 WHERE "userId"=$2`, dropped, userId)
+}
+
+func (s *sqlStore) CleanEvents(ctx context.Context, before time.Time) error {
+	return s.exec(ctx, `DELETE FROM eventsub_events WHERE "timestamp" < $1`, before.UTC())
+}
+
+func (s *sqlStore) PurgeEvents(ctx context.Context, userId string) (int64, error) {
+	return s.affected(ctx, `DELETE FROM eventsub_events WHERE userid=$1`, userId)
+}
+
+func (s *sqlStore) QueueDepth(ctx context.Context, userId string) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM eventsub_events WHERE userid=$1`, userId).Scan(&count)
+	return count, err
+}
+
+func (s *sqlStore) QueueDepths(ctx context.Context) (map[string]int, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT userid, COUNT(*) FROM eventsub_events GROUP BY userid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	depths := map[string]int{}
+	for rows.Next() {
+		var userId string
+		var count int
+		if err := rows.Scan(&userId, &count); err != nil {
+			return nil, err
+		}
+		depths[userId] = count
+	}
+	return depths, rows.Err()
+}
+
+func (s *sqlStore) GetState(ctx context.Context, broadcasterId string) ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, `SELECT data FROM channel_state WHERE broadcaster_id=$1`, broadcasterId).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *sqlStore) UpdateState(ctx context.Context, broadcasterId string, update func(data []byte) ([]byte, error)) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// row has to exist before it is locked, otherwise concurrent first updates
+	// do not wait for each other and one of them is lost
+	_, err = tx.ExecContext(ctx, `INSERT INTO channel_state (broadcaster_id, data, updated_at) VALUES ($1, '', $2)
+		ON CONFLICT (broadcaster_id) DO NOTHING`, s.args(broadcasterId, time.Now().UTC())...)
+	if err != nil {
+		return err
+	}
+	var data []byte
+	if err := tx.QueryRowContext(ctx, `SELECT data FROM channel_state WHERE broadcaster_id=$1`+s.dialect.forUpdate, broadcasterId).Scan(&data); err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		data = nil
+	}
+	data, err = update(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO channel_state (broadcaster_id, data, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (broadcaster_id) DO UPDATE SET data=excluded.data, updated_at=excluded.updated_at`,
+		s.args(broadcasterId, string(data), time.Now().UTC())...,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) StartSession(ctx context.Context, session Session) error {
+	return s.exec(ctx, `INSERT INTO stream_sessions (broadcaster_id, stream_id, started_at, title, category_id, category_name)
+		VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (stream_id) DO NOTHING`,
+		session.BroadcasterID, session.StreamID, session.StartedAt.UTC(), session.Title, session.CategoryID, session.CategoryName,
+	)
+}
+
+func (s *sqlStore) EndSession(ctx context.Context, broadcasterId string, endedAt time.Time) error {
+	return s.exec(ctx, `UPDATE stream_sessions SET ended_at=$1 WHERE broadcaster_id=$2 AND ended_at IS NULL`, endedAt.UTC(), broadcasterId)
+}
+
+func (s *sqlStore) UpdateSessionInfo(ctx context.Context, broadcasterId string, title string, categoryId string, categoryName string) error {
+	return s.exec(ctx, `UPDATE stream_sessions SET title=$1, category_id=$2, category_name=$3 WHERE broadcaster_id=$4 AND ended_at IS NULL`,
+		title, categoryId, categoryName, broadcasterId,
+	)
+}
+
+func (s *sqlStore) ListSessions(ctx context.Context, broadcasterId string, limit int) ([]Session, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, broadcaster_id, stream_id, started_at, ended_at, title, category_id, category_name
+		FROM stream_sessions WHERE broadcaster_id=$1 ORDER BY started_at DESC LIMIT $2`, broadcasterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		session := Session{}
+		var endedAt sql.NullTime
+		if err := rows.Scan(&session.ID, &session.BroadcasterID, &session.StreamID, &session.StartedAt, &endedAt, &session.Title, &session.CategoryID, &session.CategoryName); err != nil {
+			return nil, err
+		}
+		if endedAt.Valid {
+			session.EndedAt = &endedAt.Time
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (s *sqlStore) SetCorrelation(ctx context.Context, messageId string, correlationId string) error {
+	return s.exec(ctx, `UPDATE eventsub_events SET correlation_id=$1 WHERE message_id=$2`, correlationId, messageId)
+}
+
+const giftBombColumns = `id, broadcaster_id, gifter_id, gifter_login, gifter_name, is_anonymous, tier, total, cumulative_total, received, recipients, created_at, finished`
+
+func scanGiftBomb(row interface{ Scan(...any) error }) (GiftBomb, error) {
+	bomb := GiftBomb{}
+	var cumulativeTotal sql.NullInt64
+	var recipients string
+	err := row.Scan(&bomb.ID, &bomb.BroadcasterID, &bomb.GifterID, &bomb.GifterLogin, &bomb.GifterName, &bomb.IsAnonymous,
+		&bomb.Tier, &bomb.Total, &cumulativeTotal, &bomb.Received, &recipients, &bomb.CreatedAt, &bomb.Finished)
+	if err != nil {
+		return bomb, err
+	}
+	if cumulativeTotal.Valid {
+		total := int(cumulativeTotal.Int64)
+		bomb.CumulativeTotal = &total
+	}
+	err = json.Unmarshal([]byte(recipients), &bomb.Recipients)
+	return bomb, err
+}
+
+func (s *sqlStore) CreateGiftBomb(ctx context.Context, bomb GiftBomb) (int64, error) {
+	if bomb.Recipients == nil {
+		bomb.Recipients = []GiftRecipient{}
+	}
+	recipients, err := json.Marshal(bomb.Recipients)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = s.db.QueryRowContext(ctx, `INSERT INTO gift_bombs (broadcaster_id, gifter_id, gifter_login, gifter_name, is_anonymous, tier, total, cumulative_total, received, recipients, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`,
+		s.args(bomb.BroadcasterID, bomb.GifterID, bomb.GifterLogin, bomb.GifterName, bomb.IsAnonymous, bomb.Tier, bomb.Total, bomb.CumulativeTotal, len(bomb.Recipients), string(recipients), bomb.CreatedAt.UTC())...,
+	).Scan(&id)
+	return id, err
+}
+
+func (s *sqlStore) AddPendingGiftRecipient(ctx context.Context, broadcasterId string, tier string, recipient PendingGiftRecipient, receivedAt time.Time) error {
+	return s.exec(ctx, `INSERT INTO pending_gift_recipients (broadcaster_id, tier, message_id, user_id, user_login, user_name, received_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		broadcasterId, tier, recipient.MessageID, recipient.UserID, recipient.UserLogin, recipient.UserName, receivedAt.UTC(),
+	)
+}
+
+func (s *sqlStore) TakePendingGiftRecipients(ctx context.Context, broadcasterId string, tier string, since time.Time, limit int) ([]PendingGiftRecipient, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, message_id, user_id, user_login, user_name FROM pending_gift_recipients
+		WHERE broadcaster_id=$1 AND tier=$2 AND received_at >= $3 ORDER BY received_at ASC, id ASC LIMIT $4`+s.dialect.forUpdate,
+		s.args(broadcasterId, tier, since.UTC(), limit)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	ids := []int64{}
+	recipients := []PendingGiftRecipient{}
+	for rows.Next() {
+		var id int64
+		recipient := PendingGiftRecipient{}
+		if err := rows.Scan(&id, &recipient.MessageID, &recipient.UserID, &recipient.UserLogin, &recipient.UserName); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+		recipients = append(recipients, recipient)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM pending_gift_recipients WHERE id=$1`, id); err != nil {
+			return nil, err
+		}
+	}
+	return recipients, tx.Commit()
+}
+
+func (s *sqlStore) AttachGiftRecipient(ctx context.Context, broadcasterId string, tier string, since time.Time, recipient GiftRecipient) (GiftBomb, bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return GiftBomb{}, false, err
+	}
+	defer tx.Rollback()
+
+	bomb, err := scanGiftBomb(tx.QueryRowContext(ctx, `SELECT `+giftBombColumns+` FROM gift_bombs
+		WHERE broadcaster_id=$1 AND tier=$2 AND finished=$3 AND received < total AND created_at >= $4
+		ORDER BY created_at ASC, id ASC LIMIT 1`+s.dialect.forUpdate,
+		s.args(broadcasterId, tier, false, since.UTC())...,
+	))
+	if err == sql.ErrNoRows {
+		return bomb, false, nil
+	}
+	if err != nil {
+		return bomb, false, err
+	}
+
+	bomb.Received++
+	bomb.Recipients = append(bomb.Recipients, recipient)
+	recipients, err := json.Marshal(bomb.Recipients)
+	if err != nil {
+		return bomb, false, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE gift_bombs SET received=$1, recipients=$2 WHERE id=$3`, bomb.Received, string(recipients), bomb.ID); err != nil {
+		return bomb, false, err
+	}
+	return bomb, true, tx.Commit()
+}
+
+func (s *sqlStore) FinishGiftBomb(ctx context.Context, id int64) (bool, error) {
+	finished, err := s.affected(ctx, `UPDATE gift_bombs SET finished=$1 WHERE id=$2 AND finished=$3`, true, id, false)
+	return finished > 0, err
+}
+
+func (s *sqlStore) OpenGiftBombs(ctx context.Context, before time.Time) ([]GiftBomb, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+giftBombColumns+` FROM gift_bombs WHERE finished=$1 AND created_at < $2`, s.args(false, before.UTC())...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bombs := []GiftBomb{}
+	for rows.Next() {
+		bomb, err := scanGiftBomb(rows)
+		if err != nil {
+			return nil, err
+		}
+		bombs = append(bombs, bomb)
+	}
+	return bombs, rows.Err()
+}
+
+func (s *sqlStore) CleanGiftBombs(ctx context.Context, before time.Time) error {
+	if err := s.exec(ctx, `DELETE FROM pending_gift_recipients WHERE received_at < $1`, before.UTC()); err != nil {
+		return err
+	}
+	return s.exec(ctx, `DELETE FROM gift_bombs WHERE finished=$1 AND created_at < $2`, true, before.UTC())
+}
+
+func (s *sqlStore) StartVersionMigration(ctx context.Context, migration VersionMigration) error {
+	now := time.Now().UTC()
+	return s.exec(ctx, `INSERT INTO subscription_migrations (broadcaster_id, type, from_version, to_version, old_subscription_id, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT (old_subscription_id) DO NOTHING`,
+		migration.BroadcasterID, migration.Type, migration.FromVersion, migration.ToVersion, migration.OldSubscriptionID, string(MigrationPending), now, now,
+	)
+}
+
+func (s *sqlStore) VerifyVersionMigrations(ctx context.Context, broadcasterId string, subscriptionType string, version string) (int64, error) {
+	return s.affected(ctx, `UPDATE subscription_migrations SET status=$1, updated_at=$2
+		WHERE broadcaster_id=$3 AND type=$4 AND to_version=$5 AND status=$6`,
+		string(MigrationVerified), time.Now().UTC(), broadcasterId, subscriptionType, version, string(MigrationPending),
+	)
+}
+
+func (s *sqlStore) SetVersionMigrationStatus(ctx context.Context, id int64, status MigrationStatus) error {
+	return s.exec(ctx, `UPDATE subscription_migrations SET status=$1, updated_at=$2 WHERE id=$3`, string(status), time.Now().UTC(), id)
+}
+
+func (s *sqlStore) ListVersionMigrations(ctx context.Context, broadcasterId string) ([]VersionMigration, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, broadcaster_id, type, from_version, to_version, old_subscription_id, status, created_at, updated_at
+		FROM subscription_migrations WHERE broadcaster_id=$1 ORDER BY id`, broadcasterId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	migrations := []VersionMigration{}
+	for rows.Next() {
+		migration := VersionMigration{}
+		err := rows.Scan(&migration.ID, &migration.BroadcasterID, &migration.Type, &migration.FromVersion, &migration.ToVersion,
+			&migration.OldSubscriptionID, &migration.Status, &migration.CreatedAt, &migration.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration)
+	}
+	return migrations, rows.Err()
+}
+
+const customSubscriptionColumns = `id, requester_id, broadcaster_id, type, version, condition, deleted, created_at`
+
+func scanCustomSubscription(row interface{ Scan(...any) error }) (CustomSubscription, error) {
+	subscription := CustomSubscription{}
+	err := row.Scan(&subscription.ID, &subscription.RequesterID, &subscription.BroadcasterID, &subscription.Type,
+		&subscription.Version, &subscription.Condition, &subscription.Deleted, &subscription.CreatedAt)
+	return subscription, err
+}
+
+func (s *sqlStore) CreateCustomSubscription(ctx context.Context, subscription CustomSubscription) (CustomSubscription, bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return subscription, false, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	_, err = tx.ExecContext(ctx, `INSERT INTO custom_subscriptions (requester_id, broadcaster_id, type, version, condition, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (type, version, condition) DO NOTHING`,
+		s.args(subscription.RequesterID, subscription.BroadcasterID, subscription.Type, subscription.Version, subscription.Condition, now)...,
+	)
+	if err != nil {
+		return subscription, false, err
+	}
+	requesterId := subscription.RequesterID
+	subscription, err = scanCustomSubscription(tx.QueryRowContext(ctx, `SELECT `+customSubscriptionColumns+` FROM custom_subscriptions
+		WHERE type=$1 AND version=$2 AND condition=$3`+s.dialect.forUpdate, subscription.Type, subscription.Version, subscription.Condition))
+	if err != nil {
+		return subscription, false, err
+	}
+
+	var created int64
+	if !subscription.Deleted {
+		result, err := tx.ExecContext(ctx, `INSERT INTO custom_subscription_requesters (subscription_id, requester_id, created_at)
+			VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`, s.args(subscription.ID, requesterId, now)...)
+		if err != nil {
+			return subscription, false, err
+		}
+		if created, err = result.RowsAffected(); err != nil {
+			return subscription, false, err
+		}
+	}
+	if subscription.Requesters, err = s.customRequesters(ctx, tx, subscription.ID); err != nil {
+		return subscription, false, err
+	}
+	return subscription, created > 0, tx.Commit()
+}
+
+func (s *sqlStore) GetCustomSubscription(ctx context.Context, id int64) (CustomSubscription, bool, error) {
+	subscription, err := scanCustomSubscription(s.db.QueryRowContext(ctx, `SELECT `+customSubscriptionColumns+` FROM custom_subscriptions WHERE id=$1`, id))
+	if err == sql.ErrNoRows {
+		return subscription, false, nil
+	}
+	if err != nil {
+		return subscription, false, err
+	}
+	subscription.Requesters, err = s.customRequesters(ctx, s.db, id)
+	return subscription, err == nil, err
+}
+
+func (s *sqlStore) ListCustomSubscriptions(ctx context.Context, userId string) ([]CustomSubscription, error) {
+	return s.listCustomSubscriptions(ctx, `WHERE broadcaster_id=$1 OR requester_id=$1
+		OR id IN (SELECT subscription_id FROM custom_subscription_requesters WHERE requester_id=$1)`, userId)
+}
+
+func (s *sqlStore) ListOrphanedCustomSubscriptions(ctx context.Context) ([]CustomSubscription, error) {
+	return s.listCustomSubscriptions(ctx, `WHERE broadcaster_id NOT IN (SELECT "userId" FROM eventsub_users)`)
+}
+
+func (s *sqlStore) listCustomSubscriptions(ctx context.Context, where string, args ...any) ([]CustomSubscription, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+customSubscriptionColumns+` FROM custom_subscriptions `+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	list := []CustomSubscription{}
+	for rows.Next() {
+		subscription, err := scanCustomSubscription(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		list = append(list, subscription)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range list {
+		if list[i].Requesters, err = s.customRequesters(ctx, s.db, list[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// customRequesters returns requesters of subscription, oldest first
+func (s *sqlStore) customRequesters(ctx context.Context, q querier, id int64) ([]string, error) {
+	rows, err := q.QueryContext(ctx, `SELECT requester_id FROM custom_subscription_requesters
+		WHERE subscription_id=$1 ORDER BY created_at, requester_id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requesters := []string{}
+	for rows.Next() {
+		var requesterId string
+		if err := rows.Scan(&requesterId); err != nil {
+			return nil, err
+		}
+		requesters = append(requesters, requesterId)
+	}
+	return requesters, rows.Err()
+}
+
+func (s *sqlStore) RemoveCustomRequester(ctx context.Context, id int64, requesterId string) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	deleted, err := s.removeCustomRequester(ctx, tx, id, requesterId)
+	if err != nil {
+		return false, err
+	}
+	return deleted, tx.Commit()
+}
+
+// removeCustomRequester removes requester in transaction, it tells if subscription has no requester left
+func (s *sqlStore) removeCustomRequester(ctx context.Context, tx *sql.Tx, id int64, requesterId string) (bool, error) {
+	subscription, err := scanCustomSubscription(tx.QueryRowContext(ctx, `SELECT `+customSubscriptionColumns+` FROM custom_subscriptions
+		WHERE id=$1`+s.dialect.forUpdate, id))
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM custom_subscription_requesters WHERE subscription_id=$1 AND requester_id=$2`, s.args(id, requesterId)...); err != nil {
+		return false, err
+	}
+	requesters, err := s.customRequesters(ctx, tx, id)
+	if err != nil {
+		return false, err
+	}
+
+	if len(requesters) == 0 {
+		_, err = tx.ExecContext(ctx, `UPDATE custom_subscriptions SET deleted=$1 WHERE id=$2`, s.args(true, id)...)
+	} else if subscription.RequesterID == requesterId {
+		// remaining requester takes the subscription over with own authorization
+		_, err = tx.ExecContext(ctx, `UPDATE custom_subscriptions SET requester_id=$1 WHERE id=$2`, s.args(requesters[0], id)...)
+	}
+	if err != nil {
+		return false, err
+	}
+	return len(requesters) == 0, nil
+}
+
+func (s *sqlStore) MarkCustomSubscriptionDeleted(ctx context.Context, id int64) error {
+	if err := s.exec(ctx, `DELETE FROM custom_subscription_requesters WHERE subscription_id=$1`, id); err != nil {
+		return err
+	}
+	return s.exec(ctx, `UPDATE custom_subscriptions SET deleted=$1 WHERE id=$2`, true, id)
+}
+
+func (s *sqlStore) DeleteCustomSubscription(ctx context.Context, id int64) error {
+	if err := s.exec(ctx, `DELETE FROM custom_subscription_requesters WHERE subscription_id=$1`, id); err != nil {
+		return err
+	}
+	return s.exec(ctx, `DELETE FROM custom_subscriptions WHERE id=$1`, id)
+}
+
+func (s *sqlStore) Heartbeat(ctx context.Context, instance Instance) error {
+	return s.exec(ctx, `INSERT INTO conduit_instances (instance_id, callback, heartbeat_at) VALUES ($1, $2, $3)
+		ON CONFLICT (instance_id) DO UPDATE SET callback=excluded.callback, heartbeat_at=excluded.heartbeat_at`,
+		instance.ID, instance.Callback, instance.HeartbeatAt.UTC(),
+	)
+}
+
+func (s *sqlStore) ListInstances(ctx context.Context, since time.Time) ([]Instance, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT instance_id, callback, heartbeat_at FROM conduit_instances
+		WHERE heartbeat_at>=$1 ORDER BY instance_id`, s.args(since.UTC())...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	instances := []Instance{}
+	for rows.Next() {
+		instance := Instance{}
+		if err := rows.Scan(&instance.ID, &instance.Callback, &instance.HeartbeatAt); err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
+	return instances, rows.Err()
+}
+
+func (s *sqlStore) RemoveInstance(ctx context.Context, instanceId string) error {
+	return s.exec(ctx, `DELETE FROM conduit_instances WHERE instance_id=$1`, instanceId)
+}
+
+func (s *sqlStore) StoredConduit(ctx context.Context) (string, error) {
+	var conduitId string
+	err := s.db.QueryRowContext(ctx, `SELECT conduit_id FROM conduits ORDER BY created_at DESC LIMIT 1`).Scan(&conduitId)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return conduitId, err
+}
+
+func (s *sqlStore) SetStoredConduit(ctx context.Context, conduitId string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM conduits`); err != nil {
+		return err
+	}
+	if conduitId != "" {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO conduits (conduit_id, created_at) VALUES ($1, $2)`, s.args(conduitId, time.Now().UTC())...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) SetUserToken(ctx context.Context, userId string, token UserToken) error {
+	return s.exec(ctx, `INSERT INTO user_tokens (user_id, access_token, refresh_token, updated_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET access_token=excluded.access_token, updated_at=excluded.updated_at,
+			refresh_token=CASE WHEN excluded.refresh_token='' THEN user_tokens.refresh_token ELSE excluded.refresh_token END`,
+		userId, token.AccessToken, token.RefreshToken, time.Now().UTC(),
+	)
+}
+
+func (s *sqlStore) UserTokens(ctx context.Context) (map[string]UserToken, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT user_id, access_token, refresh_token FROM user_tokens`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := map[string]UserToken{}
+	for rows.Next() {
+		var userId string
+		var token UserToken
+		if err := rows.Scan(&userId, &token.AccessToken, &token.RefreshToken); err != nil {
+			return nil, err
+		}
+		tokens[userId] = token
+	}
+	return tokens, rows.Err()
+}
+
+func (s *sqlStore) DeleteUserToken(ctx context.Context, userId string, accessToken string) error {
+	return s.exec(ctx, `DELETE FROM user_tokens WHERE user_id=$1 AND access_token=$2`, userId, accessToken)
+}
+
+const forwardEndpointColumns = `user_id, url, secret, enabled, attempts, failures, last_error, next_attempt_at, updated_at`
+
+func scanForwardEndpoint(row interface{ Scan(...any) error }) (ForwardEndpoint, error) {
+	endpoint := ForwardEndpoint{}
+	err := row.Scan(&endpoint.UserID, &endpoint.URL, &endpoint.Secret, &endpoint.Enabled, &endpoint.Attempts,
+		&endpoint.Failures, &endpoint.LastError, &endpoint.NextAttemptAt, &endpoint.UpdatedAt)
+	return endpoint, err
+}
+
+func (s *sqlStore) GetForwardEndpoint(ctx context.Context, userId string) (ForwardEndpoint, bool, error) {
+	endpoint, err := scanForwardEndpoint(s.db.QueryRowContext(ctx, `SELECT `+forwardEndpointColumns+` FROM forward_endpoints WHERE user_id=$1`, userId))
+	if err == sql.ErrNoRows {
+		return endpoint, false, nil
+	}
+	return endpoint, err == nil, err
+}
+
+func (s *sqlStore) SetForwardEndpoint(ctx context.Context, userId string, url string, secret string) error {
+	now := time.Now().UTC()
+	return s.exec(ctx, `INSERT INTO forward_endpoints (user_id, url, secret, enabled, attempts, failures, last_error, next_attempt_at, updated_at)
+		VALUES ($1, $2, $3, $4, 0, 0, '', $5, $6)
+		ON CONFLICT (user_id) DO UPDATE SET url=excluded.url, secret=excluded.secret, enabled=excluded.enabled, attempts=0, failures=0,
+			last_error='', next_attempt_at=excluded.next_attempt_at, updated_at=excluded.updated_at`,
+		userId, url, secret, true, now, now,
+	)
+}
+
+func (s *sqlStore) DeleteForwardEndpoint(ctx context.Context, userId string) error {
+	return s.exec(ctx, `DELETE FROM forward_endpoints WHERE user_id=$1`, userId)
+}
+
+func (s *sqlStore) DueForwardEndpoints(ctx context.Context, now time.Time) ([]ForwardEndpoint, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+forwardEndpointColumns+` FROM forward_endpoints f
+		WHERE enabled=$1 AND next_attempt_at<=$2 AND EXISTS (SELECT 1 FROM eventsub_events e WHERE e.userid=f.user_id)`,
+		s.args(true, now.UTC())...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	endpoints := []ForwardEndpoint{}
+	for rows.Next() {
+		endpoint, err := scanForwardEndpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, rows.Err()
+}
+
+func (s *sqlStore) ClaimForwardEndpoint(ctx context.Context, userId string, now time.Time, until time.Time) (bool, error) {
+	claimed, err := s.affected(ctx, `UPDATE forward_endpoints SET next_attempt_at=$1 WHERE user_id=$2 AND enabled=$3 AND next_attempt_at<=$4`,
+		until.UTC(), userId, true, now.UTC())
+	return claimed > 0, err
+}
+
+func (s *sqlStore) UpdateForwardEndpoint(ctx context.Context, endpoint ForwardEndpoint) error {
+	return s.exec(ctx, `UPDATE forward_endpoints SET enabled=$1, attempts=$2, failures=$3, last_error=$4, next_attempt_at=$5, updated_at=$6
+		WHERE user_id=$7 AND url=$8`,
+		endpoint.Enabled, endpoint.Attempts, endpoint.Failures, endpoint.LastError, endpoint.NextAttemptAt.UTC(), time.Now().UTC(),
+		endpoint.UserID, endpoint.URL,
+	)
+}
+
+func (s *sqlStore) InsertDeadLetter(ctx context.Context, letter DeadLetter, keep int) error {
+	err := s.exec(ctx, `INSERT INTO forward_dead_letters (user_id, message_id, type, data, error, created_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		letter.UserID, letter.MessageID, letter.Type, letter.Data, letter.Error, time.Now().UTC(),
+	)
+	if err != nil {
+		return err
+	}
+	return s.exec(ctx, `DELETE FROM forward_dead_letters WHERE user_id=$1 AND id NOT IN
+		(SELECT id FROM forward_dead_letters WHERE user_id=$2 ORDER BY id DESC LIMIT $3)`, letter.UserID, letter.UserID, keep)
+}
+
+func (s *sqlStore) ListDeadLetters(ctx context.Context, userId string, limit int) ([]DeadLetter, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, user_id, message_id, type, data, error, created_at FROM forward_dead_letters
+		WHERE user_id=$1 ORDER BY id DESC LIMIT $2`, userId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	letters := []DeadLetter{}
+	for rows.Next() {
+		letter := DeadLetter{}
+		if err := rows.Scan(&letter.ID, &letter.UserID, &letter.MessageID, &letter.Type, &letter.Data, &letter.Error, &letter.CreatedAt); err != nil {
+			return nil, err
+		}
+		letters = append(letters, letter)
+	}
+	return letters, rows.Err()
+}
+
+func (s *sqlStore) PurgeDeadLetters(ctx context.Context, userId string) (int64, error) {
+	return s.affected(ctx, `DELETE FROM forward_dead_letters WHERE user_id=$1`, userId)
+}
+
+func (s *sqlStore) InsertAudit(ctx context.Context, entry AuditEntry) error {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	return s.exec(ctx, `INSERT INTO admin_audit ("timestamp", actor, action, target, details) VALUES ($1, $2, $3, $4, $5)`,
+		entry.Timestamp.UTC(), entry.Actor, entry.Action, entry.Target, entry.Details,
+	)
+}
+
+func (s *sqlStore) ListAudit(ctx context.Context, limit int) ([]AuditEntry, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, "timestamp", actor, action, target, details FROM admin_audit ORDER BY id DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		entry := AuditEntry{}
+		if err := rows.Scan(&entry.ID, &entry.Timestamp, &entry.Actor, &entry.Action, &entry.Target, &entry.Details); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
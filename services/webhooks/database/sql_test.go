@@ -0,0 +1,320 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) Store {
+	t.Helper()
+	store, err := NewSQLite(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestQueueEvent(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		max    int
+		policy QueuePolicy
+		// types of queued events, in order
+		types   []string
+		dropped int64
+		// remaining are types left in queue, oldest first
+		remaining []string
+	}{
+		{
+			name:      "no limit",
+			max:       0,
+			policy:    DropOldest,
+			types:     []string{"a", "b", "c"},
+			remaining: []string{"a", "b", "c"},
+		},
+		{
+			name:      "drop oldest",
+			max:       2,
+			policy:    DropOldest,
+			types:     []string{"a", "b", "c", "d"},
+			dropped:   2,
+			remaining: []string{"c", "d"},
+		},
+		{
+			name:      "drop duplicates keeps newest of type",
+			max:       3,
+			policy:    DropDuplicates,
+			types:     []string{"a", "b", "b", "b"},
+			dropped:   2,
+			remaining: []string{"a", "b"},
+		},
+		{
+			name:      "collapse progress",
+			max:       3,
+			policy:    CollapseProgress,
+			types:     []string{"x.progress", "a", "x.progress", "y.progress", "x.progress"},
+			dropped:   2,
+			remaining: []string{"a", "y.progress", "x.progress"},
+		},
+		{
+			name:      "policy falls back to oldest",
+			max:       2,
+			policy:    DropDuplicates,
+			types:     []string{"a", "b", "c"},
+			dropped:   1,
+			remaining: []string{"b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newTestStore(t)
+			if err := store.CreateUser(ctx, "1", ""); err != nil {
+				t.Fatal(err)
+			}
+
+			for i, eventType := range tt.types {
+				inserted, _, err := store.QueueEvent(ctx, Event{
+					MessageID: fmt.Sprint(i),
+					UserID:    "1",
+					Type:      eventType,
+					Data:      "{}",
+					Timestamp: start.Add(time.Duration(i) * time.Second),
+				}, tt.max, tt.policy)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !inserted {
+					t.Fatalf("event %d was not inserted", i)
+				}
+			}
+
+			events, err := store.NextEvents(ctx, "1", 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			remaining := []string{}
+			for _, event := range events {
+				remaining = append(remaining, event.Type)
+			}
+			if fmt.Sprint(remaining) != fmt.Sprint(tt.remaining) {
+				t.Errorf("remaining = %v, want %v", remaining, tt.remaining)
+			}
+
+			dropped, err := store.Dropped(ctx, "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dropped != tt.dropped {
+				t.Errorf("dropped = %d, want %d", dropped, tt.dropped)
+			}
+		})
+	}
+}
+
+func TestQueueEventDuplicate(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	event := Event{MessageID: "m", UserID: "1", Type: "a", Data: "{}", Timestamp: time.Now()}
+	if inserted, _, err := store.QueueEvent(ctx, event, 10, DropOldest); err != nil || !inserted {
+		t.Fatalf("first insert = %v, %v", inserted, err)
+	}
+	if inserted, _, err := store.QueueEvent(ctx, event, 10, DropOldest); err != nil || inserted {
+		t.Fatalf("duplicate insert = %v, %v", inserted, err)
+	}
+}
+
+func TestAckDropped(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	if err := store.CreateUser(ctx, "1", ""); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, _, err := store.QueueEvent(ctx, Event{MessageID: fmt.Sprint(i), UserID: "1", Type: "a", Data: "{}", Timestamp: time.Now()}, 1, DropOldest); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dropped, err := store.Dropped(ctx, "1")
+	if err != nil || dropped != 2 {
+		t.Fatalf("Dropped = %d, %v, want 2", dropped, err)
+	}
+	// not acknowledged response leaves counter untouched
+	if again, _ := store.Dropped(ctx, "1"); again != 2 {
+		t.Fatalf("Dropped without ack = %d, want 2", again)
+	}
+
+	// drop counted between read and ack is kept
+	if _, _, err := store.QueueEvent(ctx, Event{MessageID: "late", UserID: "1", Type: "a", Data: "{}", Timestamp: time.Now()}, 1, DropOldest); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AckDropped(ctx, "1", dropped); err != nil {
+		t.Fatal(err)
+	}
+	if left, _ := store.Dropped(ctx, "1"); left != 1 {
+		t.Errorf("Dropped after ack = %d, want 1", left)
+	}
+}
+
+func TestCustomRequesters(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	if err := store.CreateUser(ctx, "1", ""); err != nil {
+		t.Fatal(err)
+	}
+	request := func(requesterId string) (CustomSubscription, bool) {
+		t.Helper()
+		subscription, created, err := store.CreateCustomSubscription(ctx, CustomSubscription{
+			RequesterID:   requesterId,
+			BroadcasterID: "1",
+			Type:          "channel.vip.add",
+			Version:       "1",
+			Condition:     `{"broadcaster_user_id":"1"}`,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return subscription, created
+	}
+
+	first, created := request("2")
+	if !created || first.RequesterID != "2" {
+		t.Fatalf("first request = %+v, %v", first, created)
+	}
+	if _, created := request("2"); created {
+		t.Fatal("repeated request created subscription")
+	}
+	second, created := request("3")
+	if !created || second.ID != first.ID || fmt.Sprint(second.Requesters) != "[2 3]" {
+		t.Fatalf("second request = %+v, %v", second, created)
+	}
+
+	// first requester leaves, the other one takes subscription over
+	if deleted, err := store.RemoveCustomRequester(ctx, first.ID, "2"); err != nil || deleted {
+		t.Fatalf("RemoveCustomRequester(2) = %v, %v", deleted, err)
+	}
+	subscription, _, err := store.GetCustomSubscription(ctx, first.ID)
+	if err != nil || subscription.RequesterID != "3" || subscription.Deleted {
+		t.Fatalf("after first removal = %+v, %v", subscription, err)
+	}
+	if list, _ := store.ListCustomSubscriptions(ctx, "2"); len(list) != 0 {
+		t.Errorf("removed requester still lists %+v", list)
+	}
+
+	if deleted, err := store.RemoveCustomRequester(ctx, first.ID, "3"); err != nil || !deleted {
+		t.Fatalf("RemoveCustomRequester(3) = %v, %v", deleted, err)
+	}
+	if subscription, _, _ := store.GetCustomSubscription(ctx, first.ID); !subscription.Deleted {
+		t.Error("subscription without requesters is not deleted")
+	}
+	if _, created := request("4"); created {
+		t.Error("subscription being deleted was requested again")
+	}
+}
+
+func TestOrphanedCustomSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	for _, userId := range []string{"1", "2"} {
+		if err := store.CreateUser(ctx, userId, ""); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := store.CreateCustomSubscription(ctx, CustomSubscription{
+			RequesterID:   userId,
+			BroadcasterID: userId,
+			Type:          "channel.vip.add",
+			Version:       "1",
+			Condition:     `{"broadcaster_user_id":"` + userId + `"}`,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.DeleteUser(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := store.ListOrphanedCustomSubscriptions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].BroadcasterID != "1" || !list[0].Deleted {
+		t.Errorf("orphaned = %+v, want deleted subscription of 1", list)
+	}
+}
+
+func TestStoredConduit(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	for _, conduitId := range []string{"a", "b", ""} {
+		if err := store.SetStoredConduit(ctx, conduitId); err != nil {
+			t.Fatal(err)
+		}
+		stored, err := store.StoredConduit(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored != conduitId {
+			t.Errorf("StoredConduit = %q, want %q", stored, conduitId)
+		}
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	for _, userId := range []string{"1", "2", "3"} {
+		if err := store.CreateUser(ctx, userId, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// user 1 is broadcaster moderated by bot 2 and bot of broadcaster 3
+	if err := store.SetBotLinks(ctx, "2", []string{"1", "3"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetBotLinks(ctx, "1", []string{"3"}); err != nil {
+		t.Fatal(err)
+	}
+	var id int64
+	for _, requesterId := range []string{"1", "2"} {
+		subscription, _, err := store.CreateCustomSubscription(ctx, CustomSubscription{
+			RequesterID:   requesterId,
+			BroadcasterID: "3",
+			Type:          "channel.vip.add",
+			Version:       "1",
+			Condition:     `{"broadcaster_user_id":"3"}`,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		id = subscription.ID
+	}
+
+	if err := store.DeleteUser(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, err := store.GetUser(ctx, "1"); err != nil || found {
+		t.Errorf("GetUser(1) = %v, %v, want deleted", found, err)
+	}
+	if links, err := store.BotLinks(ctx, "2"); err != nil || fmt.Sprint(links) != "[3]" {
+		t.Errorf("BotLinks(2) = %v, %v, want link to deleted broadcaster removed", links, err)
+	}
+	if links, err := store.BotLinks(ctx, "1"); err != nil || len(links) != 0 {
+		t.Errorf("BotLinks(1) = %v, %v, want links of deleted bot removed", links, err)
+	}
+	subscription, _, err := store.GetCustomSubscription(ctx, id)
+	if err != nil || subscription.RequesterID != "2" || fmt.Sprint(subscription.Requesters) != "[2]" || subscription.Deleted {
+		t.Errorf("custom subscription = %+v, %v, want it taken over by 2", subscription, err)
+	}
+}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+var sqliteMigrationMutex sync.Mutex
+
+var sqlite = dialect{
+	name: "sqlite",
+	// sqlite database is used by single process only
+	lock: func(ctx context.Context, conn *sql.Conn) error {
+		sqliteMigrationMutex.Lock()
+		return nil
+	},
+	unlock: func(ctx context.Context, conn *sql.Conn) error {
+		sqliteMigrationMutex.Unlock()
+		return nil
+	},
+	migrationsTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`,
+	// fixed width, so timestamps stored as text are ordered correctly
+	time: func(t time.Time) any {
+		return t.UTC().Format("2006-01-02 15:04:05.000000000")
+	},
+}
+
+// NewSQLite opens SQLite database at path, use ":memory:" for in-memory store
+func NewSQLite(path string) (Store, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// sqlite allows single writer, in-memory database exists only in one connection
+	db.SetMaxOpenConns(1)
+
+	return &sqlStore{db: db, dialect: sqlite, notifier: newLocalNotifier()}, nil
+}
@@ -0,0 +1,345 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+type User struct {
+	ID      string
+	Scopes  string
+	Updated bool
+	// LastSeen is time of last bot poll, nil if bot never polled
+	LastSeen *time.Time
+}
+
+// AuditEntry is one recorded admin action
+type AuditEntry struct {
+	ID        int64     `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target"`
+	Details   string    `json:"details"`
+}
+
+// Event is queued EventSub notification, Data is raw message sent by Twitch
+// and Timestamp is time the event occurred at
+type Event struct {
+	ID            int64
+	MessageID     string
+	UserID        string
+	Type          string
+	Version       string
+	BroadcasterID string
+	// CorrelationID groups related events, e.g. gifted subscriptions of one gift bomb
+	CorrelationID string
+	Data          string
+	Timestamp     time.Time
+}
+
+type GiftRecipient struct {
+	UserID    string `json:"user_id"`
+	UserLogin string `json:"user_login"`
+	UserName  string `json:"user_name"`
+}
+
+// PendingGiftRecipient is gifted subscription received before its gift event
+type PendingGiftRecipient struct {
+	GiftRecipient
+	MessageID string
+}
+
+// GiftBomb collects gifted subscriptions of one channel.subscription.gift event
+type GiftBomb struct {
+	ID              int64
+	BroadcasterID   string
+	GifterID        string
+	GifterLogin     string
+	GifterName      string
+	IsAnonymous     bool
+	Tier            string
+	Total           int
+	CumulativeTotal *int
+	Received        int
+	Recipients      []GiftRecipient
+	CreatedAt       time.Time
+	Finished        bool
+}
+
+// Session is one stream of broadcaster, EndedAt is nil while stream is live
+type Session struct {
+	ID            int64
+	BroadcasterID string
+	StreamID      string
+	StartedAt     time.Time
+	EndedAt       *time.Time
+	Title         string
+	CategoryID    string
+	CategoryName  string
+}
+
+// VersionMigration tracks replacing subscription of superseded version with
+// subscription of version from catalog
+type VersionMigration struct {
+	ID            int64  `json:"id"`
+	BroadcasterID string `json:"broadcaster_id"`
+	Type          string `json:"type"`
+	FromVersion   string `json:"from_version"`
+	ToVersion     string `json:"to_version"`
+	// OldSubscriptionID is deleted once subscription of ToVersion is verified
+	OldSubscriptionID string          `json:"old_subscription_id"`
+	Status            MigrationStatus `json:"status"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+}
+
+type MigrationStatus string
+
+const (
+	// MigrationPending waits for verification of new subscription
+	MigrationPending MigrationStatus = "pending"
+	// MigrationVerified has new subscription verified, old one can be deleted
+	MigrationVerified MigrationStatus = "verified"
+	// MigrationCompleted has old subscription deleted
+	MigrationCompleted MigrationStatus = "completed"
+)
+
+// CustomSubscription is subscription requested by bots, Condition is JSON object
+type CustomSubscription struct {
+	ID int64 `json:"id"`
+	// RequesterID is account whose authorization creates the subscription, the
+	// first of Requesters
+	RequesterID string `json:"requester_id"`
+	// Requesters are all accounts which requested the subscription, it is
+	// deleted at Twitch when last of them deletes it
+	Requesters    []string `json:"requesters"`
+	BroadcasterID string   `json:"broadcaster_id"`
+	Type          string   `json:"type"`
+	Version       string   `json:"version"`
+	Condition     string   `json:"condition"`
+	// Deleted subscriptions wait for reconciler to delete them at Twitch
+	Deleted   bool      `json:"deleted"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserToken is encrypted access token of user with refresh token it is renewed with
+type UserToken struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+// Instance is replica receiving conduit shards at Callback
+type Instance struct {
+	ID          string
+	Callback    string
+	HeartbeatAt time.Time
+}
+
+// ForwardEndpoint is HTTPS endpoint queued events of user are posted to,
+// Attempts counts failed deliveries of oldest queued event and Failures all
+// consecutive failed deliveries
+type ForwardEndpoint struct {
+	UserID        string    `json:"user_id"`
+	URL           string    `json:"url"`
+	Secret        string    `json:"-"`
+	Enabled       bool      `json:"enabled"`
+	Attempts      int       `json:"attempts"`
+	Failures      int       `json:"failures"`
+	LastError     string    `json:"last_error"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// DeadLetter is event which could not be forwarded
+type DeadLetter struct {
+	ID        int64     `json:"id"`
+	UserID    string    `json:"user_id"`
+	MessageID string    `json:"message_id"`
+	Type      string    `json:"type"`
+	Data      string    `json:"data"`
+	Error     string    `json:"error"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// QueuePolicy decides which events are dropped when user queue is over limit
+type QueuePolicy string
+
+const (
+	// DropOldest drops oldest events
+	DropOldest QueuePolicy = "drop_oldest"
+	// DropDuplicates keeps only newest queued event of incoming event type
+	DropDuplicates QueuePolicy = "drop_duplicates"
+	// CollapseProgress keeps only newest event of each *.progress type
+	CollapseProgress QueuePolicy = "collapse_progress"
+)
+
+// Store keeps users and their queued events, implemented by Postgres and SQLite
+type Store interface {
+	Ping(ctx context.Context) error
+	Close() error
+	Migrate(ctx context.Context) error
+	MigrationStatus(ctx context.Context) ([]Migration, error)
+
+	GetUser(ctx context.Context, userId string) (User, bool, error)
+	CreateUser(ctx context.Context, userId string, scopes string) error
+	// UpdateUserScopes stores new scopes and marks user as updated, so subscriptions are synced
+	UpdateUserScopes(ctx context.Context, userId string, scopes string) error
+	DeleteUser(ctx context.Context, userId string) error
+	ListUsers(ctx context.Context, updatedOnly bool) ([]User, error)
+	// SearchUsers returns users with id starting with query, ordered by id
+	SearchUsers(ctx context.Context, query string, limit int) ([]User, error)
+	ResetUpdated(ctx context.Context) error
+	// MarkUpdated flags user to be synced in next reconciliation pass
+	MarkUpdated(ctx context.Context, userId string) error
+	TouchUser(ctx context.Context, userId string, seen time.Time) error
+
+	// BotLinks returns ids of broadcasters moderated by bot account
+	BotLinks(ctx context.Context, botId string) ([]string, error)
+	// SetBotLinks replaces broadcasters moderated by bot account
+	SetBotLinks(ctx context.Context, botId string, broadcasterIds []string) error
+	// LinkedBots returns stored users linked as bots moderating broadcaster
+	LinkedBots(ctx context.Context, broadcasterId string) ([]User, error)
+
+	// RewardFilters returns ids of channel point rewards broadcaster is subscribed
+	// to, empty when redemptions of all rewards are wanted
+	RewardFilters(ctx context.Context, broadcasterId string) ([]string, error)
+	SetRewardFilters(ctx context.Context, broadcasterId string, rewardIds []string) error
+	// RemoveRewardFilter forgets reward which no longer exists, returns false if it was not filtered
+	RemoveRewardFilter(ctx context.Context, broadcasterId string, rewardId string) (bool, error)
+
+	// InsertEvent queues event, returns false if event with same message id is already queued
+	InsertEvent(ctx context.Context, event Event) (bool, error)
+	// NextEvent returns oldest queued event of user
+	NextEvent(ctx context.Context, userId string) (Event, bool, error)
+	// NextEvents returns up to limit oldest queued events of user
+	NextEvents(ctx context.Context, userId string, limit int) ([]Event, error)
+	DeleteEvent(ctx context.Context, event Event) error
+	CleanEvents(ctx context.Context, before time.Time) error
+	// QueueEvent inserts event like InsertEvent and in the same transaction
+	// applies policy when user has more than max queued events, oldest events are
+	// dropped if policy alone is not enough. Dropped events are counted and
+	// returned by Dropped. Zero max disables the limit.
+	QueueEvent(ctx context.Context, event Event, max int, policy QueuePolicy) (bool, int64, error)
+	// Dropped returns number of events dropped and not yet acknowledged
+	Dropped(ctx context.Context, userId string) (int64, error)
+	// AckDropped subtracts dropped events bot was told about
+	AckDropped(ctx context.Context, userId string, dropped int64) error
+	// PurgeEvents deletes all queued events of user and returns their count
+	PurgeEvents(ctx context.Context, userId string) (int64, error)
+	// QueueDepths returns number of queued events per user
+	QueueDepths(ctx context.Context) (map[string]int, error)
+	QueueDepth(ctx context.Context, userId string) (int, error)
+
+	// GetState returns stored channel state document, nil if there is none
+	GetState(ctx context.Context, broadcasterId string) ([]byte, error)
+	// UpdateState replaces channel state with result of update, update gets
+	// current document (nil if there is none) and runs in transaction
+	UpdateState(ctx context.Context, broadcasterId string, update func(data []byte) ([]byte, error)) error
+
+	// StartSession stores new session, session with the same stream id is ignored
+	StartSession(ctx context.Context, session Session) error
+	// EndSession ends all live sessions of broadcaster
+	EndSession(ctx context.Context, broadcasterId string, endedAt time.Time) error
+	// UpdateSessionInfo sets title and category of live session
+	UpdateSessionInfo(ctx context.Context, broadcasterId string, title string, categoryId string, categoryName string) error
+	// ListSessions returns latest sessions of broadcaster, newest first
+	ListSessions(ctx context.Context, broadcasterId string, limit int) ([]Session, error)
+
+	// SetCorrelation sets correlation id of event with message id
+	SetCorrelation(ctx context.Context, messageId string, correlationId string) error
+
+	// CreateGiftBomb stores gift bomb with recipients it already has
+	CreateGiftBomb(ctx context.Context, bomb GiftBomb) (int64, error)
+	// AddPendingGiftRecipient keeps recipient which arrived before its gift bomb
+	AddPendingGiftRecipient(ctx context.Context, broadcasterId string, tier string, recipient PendingGiftRecipient, receivedAt time.Time) error
+	// TakePendingGiftRecipients removes and returns up to limit oldest pending
+	// recipients of broadcaster and tier received since time
+	TakePendingGiftRecipients(ctx context.Context, broadcasterId string, tier string, since time.Time, limit int) ([]PendingGiftRecipient, error)
+	// AttachGiftRecipient adds recipient to oldest unfinished gift bomb of broadcaster
+	// and tier created after since, returns false if there is no such gift bomb
+	AttachGiftRecipient(ctx context.Context, broadcasterId string, tier string, since time.Time, recipient GiftRecipient) (GiftBomb, bool, error)
+	// FinishGiftBomb marks gift bomb finished, returns false if it was already finished
+	FinishGiftBomb(ctx context.Context, id int64) (bool, error)
+	// OpenGiftBombs returns unfinished gift bombs created before time
+	OpenGiftBombs(ctx context.Context, before time.Time) ([]GiftBomb, error)
+	// CleanGiftBombs deletes finished gift bombs and pending recipients older than time
+	CleanGiftBombs(ctx context.Context, before time.Time) error
+
+	// StartVersionMigration stores pending migration, it is ignored when
+	// migration of the same old subscription exists
+	StartVersionMigration(ctx context.Context, migration VersionMigration) error
+	// VerifyVersionMigrations marks pending migrations to type and version of
+	// broadcaster verified, returns number of them
+	VerifyVersionMigrations(ctx context.Context, broadcasterId string, subscriptionType string, version string) (int64, error)
+	SetVersionMigrationStatus(ctx context.Context, id int64, status MigrationStatus) error
+	// ListVersionMigrations returns migrations of broadcaster, oldest first
+	ListVersionMigrations(ctx context.Context, broadcasterId string) ([]VersionMigration, error)
+
+	// CreateCustomSubscription stores subscription and adds its requester,
+	// subscription with the same type, version and condition is shared. Returns
+	// false when requester already requested it or it is being deleted.
+	CreateCustomSubscription(ctx context.Context, subscription CustomSubscription) (CustomSubscription, bool, error)
+	GetCustomSubscription(ctx context.Context, id int64) (CustomSubscription, bool, error)
+	// ListCustomSubscriptions returns subscriptions requested by user or for user as broadcaster
+	ListCustomSubscriptions(ctx context.Context, userId string) ([]CustomSubscription, error)
+	// ListOrphanedCustomSubscriptions returns subscriptions of deleted broadcasters
+	ListOrphanedCustomSubscriptions(ctx context.Context) ([]CustomSubscription, error)
+	// RemoveCustomRequester removes requester of subscription, subscription is
+	// flagged deleted when no requester remains, which is returned as true
+	RemoveCustomRequester(ctx context.Context, id int64, requesterId string) (bool, error)
+	// MarkCustomSubscriptionDeleted flags subscription to be deleted by reconciler
+	MarkCustomSubscriptionDeleted(ctx context.Context, id int64) error
+	DeleteCustomSubscription(ctx context.Context, id int64) error
+
+	// Heartbeat stores instance as alive at time
+	Heartbeat(ctx context.Context, instance Instance) error
+	// ListInstances returns instances alive since time, ordered by id
+	ListInstances(ctx context.Context, since time.Time) ([]Instance, error)
+	RemoveInstance(ctx context.Context, instanceId string) error
+	// StoredConduit returns id of conduit created by this service, empty when there is none
+	StoredConduit(ctx context.Context) (string, error)
+	// SetStoredConduit replaces id of conduit created by this service, empty forgets it
+	SetStoredConduit(ctx context.Context, conduitId string) error
+
+	// SetUserToken stores encrypted tokens of user used for WebSocket sessions,
+	// empty refresh token keeps the stored one
+	SetUserToken(ctx context.Context, userId string, token UserToken) error
+	// UserTokens returns stored encrypted tokens by user id
+	UserTokens(ctx context.Context) (map[string]UserToken, error)
+	// DeleteUserToken deletes token of user, only if it was not replaced meanwhile
+	DeleteUserToken(ctx context.Context, userId string, accessToken string) error
+
+	GetForwardEndpoint(ctx context.Context, userId string) (ForwardEndpoint, bool, error)
+	// SetForwardEndpoint stores endpoint of user, it is enabled and its failures are reset
+	SetForwardEndpoint(ctx context.Context, userId string, url string, secret string) error
+	DeleteForwardEndpoint(ctx context.Context, userId string) error
+	// DueForwardEndpoints returns enabled endpoints of users with queued events,
+	// which are due for delivery at time
+	DueForwardEndpoints(ctx context.Context, now time.Time) ([]ForwardEndpoint, error)
+	// ClaimForwardEndpoint postpones next attempt of due endpoint to until, returns
+	// false when other replica claimed it first
+	ClaimForwardEndpoint(ctx context.Context, userId string, now time.Time, until time.Time) (bool, error)
+	// UpdateForwardEndpoint stores delivery state of endpoint
+	UpdateForwardEndpoint(ctx context.Context, endpoint ForwardEndpoint) error
+	// InsertDeadLetter stores event which could not be forwarded, only keep
+	// newest dead letters of user are retained
+	InsertDeadLetter(ctx context.Context, letter DeadLetter, keep int) error
+	// ListDeadLetters returns dead letters of user, newest first
+	ListDeadLetters(ctx context.Context, userId string, limit int) ([]DeadLetter, error)
+	PurgeDeadLetters(ctx context.Context, userId string) (int64, error)
+
+	// Publish sends payload to handlers listening on channel in all replicas,
+	// payloads are not stored, so handlers not listening at the time miss them
+	Publish(ctx context.Context, channel string, payload string) error
+	// Listen calls handler with payloads published to channel until ctx is done
+	Listen(ctx context.Context, channel string, handler func(payload string)) error
+	// StoreChatPayload keeps chat event too large to be published directly
+	StoreChatPayload(ctx context.Context, messageId string, payload string, createdAt time.Time) error
+	ChatPayload(ctx context.Context, messageId string) (string, bool, error)
+	CleanChatPayloads(ctx context.Context, before time.Time) error
+
+	InsertAudit(ctx context.Context, entry AuditEntry) error
+	// ListAudit returns latest audit entries, newest first
+	ListAudit(ctx context.Context, limit int) ([]AuditEntry, error)
+}
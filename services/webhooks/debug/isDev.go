@@ -1,7 +1,25 @@
 package debug
 
-import "os"
+import "slices"
+
+var development bool
+
+// users limits users handled in development, empty means all of them
+var users []string
+
+func SetDevelopment(value bool) {
+	development = value
+}
+
+func SetUsers(userIds []string) {
+	users = userIds
+}
 
 func IsDEV() bool {
-	return os.Getenv("ENV") == "development"
+	return development
+}
+
+// Allowed tells if user is handled, in development only allowlisted users are
+func Allowed(userId string) bool {
+	return !development || len(users) == 0 || slices.Contains(users, userId)
 }
@@ -0,0 +1,122 @@
+// Package devtwitch mocks Twitch API and OAuth, so relay can be run locally
+// without Twitch application or channel.
+//
+// EventSub subscriptions and conduits are kept in memory. Webhooks are verified
+// with a challenge and then receive recorded notification of their type, signed
+// with TWITCH_EVENTSUB_SECRET. Conduit subscriptions are delivered to callback
+// of their first shard. WebSocket sessions get no notifications.
+//
+// Other requests are answered from fixtures named by method and path, e.g.
+// GET /oauth2/validate is get_oauth2_validate.json, query is ignored. Fixture
+// holds status and JSON body of response, {client_id} and {user_id} in body are
+// replaced by configured client id and first development user.
+//
+// Notification fixtures are event objects in notifications/<type>.json,
+// {broadcaster_user_id}, {from_broadcaster_user_id}, {to_broadcaster_user_id},
+// {moderator_user_id} and {message_id} are replaced by values of subscription.
+package devtwitch
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"services/shared/logging"
+	"services/webhooks/config"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+//go:embed fixtures/*.json fixtures/notifications/*.json
+var builtin embed.FS
+
+// userId is used in fixtures when no development user is configured
+const userId = "12345"
+
+type fixture struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// Start serves fixtures on local address and points Twitch URLs of cfg to it
+func Start(cfg *config.Config) error {
+	user := userId
+	if len(cfg.Dev.UserIDs) > 0 {
+		user = cfg.Dev.UserIDs[0]
+	}
+	replacer := strings.NewReplacer("{client_id}", cfg.Twitch.ClientID, "{user_id}", user)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	twitch := &state{secret: cfg.Twitch.Secret, fixtures: cfg.Dev.Fixtures, shards: map[string]map[string]shard{}}
+	router := mux.NewRouter()
+	router.HandleFunc("/helix/eventsub/subscriptions", twitch.listSubscriptions).Methods(http.MethodGet)
+	router.HandleFunc("/helix/eventsub/subscriptions", twitch.createSubscription).Methods(http.MethodPost)
+	router.HandleFunc("/helix/eventsub/subscriptions", twitch.deleteSubscription).Methods(http.MethodDelete)
+	router.HandleFunc("/helix/eventsub/conduits", twitch.listConduits).Methods(http.MethodGet)
+	router.HandleFunc("/helix/eventsub/conduits", twitch.createConduit).Methods(http.MethodPost)
+	router.HandleFunc("/helix/eventsub/conduits", twitch.updateConduit).Methods(http.MethodPatch)
+	router.HandleFunc("/helix/eventsub/conduits/shards", twitch.listShards).Methods(http.MethodGet)
+	router.HandleFunc("/helix/eventsub/conduits/shards", twitch.updateShards).Methods(http.MethodPatch)
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serve(w, r, cfg.Dev.Fixtures, replacer)
+	})
+
+	go func() {
+		if err := http.Serve(listener, router); err != nil {
+			slog.Error("Error serving Twitch fixtures", logging.Error(err))
+			os.Exit(1)
+		}
+	}()
+
+	url := "http://" + listener.Addr().String()
+	cfg.Twitch.HelixURL = url + "/helix"
+	cfg.Twitch.AuthURL = url + "/oauth2"
+	slog.Info("Twitch is mocked by fixtures", slog.String("url", url), slog.String("fixtures", cfg.Dev.Fixtures))
+	return nil
+}
+
+func serve(w http.ResponseWriter, r *http.Request, dir string, replacer *strings.Replacer) {
+	name := strings.ToLower(r.Method) + strings.ReplaceAll(strings.TrimSuffix(r.URL.Path, "/"), "/", "_") + ".json"
+	data, err := load(dir, name)
+	if err != nil {
+		slog.Warn("Missing Twitch fixture", slog.String("method", r.Method), slog.String("path", r.URL.Path), slog.String("fixture", name), logging.Error(err))
+		http.Error(w, "no fixture "+name, http.StatusNotFound)
+		return
+	}
+
+	var response fixture
+	if err := json.Unmarshal(data, &response); err != nil {
+		slog.Error("Invalid Twitch fixture", slog.String("fixture", name), logging.Error(err))
+		http.Error(w, "invalid fixture "+name, http.StatusInternalServerError)
+		return
+	}
+	slog.Debug("Answering from Twitch fixture", slog.String("method", r.Method), slog.String("path", r.URL.Path), slog.Int("status", response.Status))
+
+	if len(response.Body) == 0 {
+		w.WriteHeader(response.Status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(response.Status)
+	w.Write([]byte(replacer.Replace(string(response.Body))))
+}
+
+// load reads fixture from dir, built-in one is used when dir does not have it
+func load(dir string, name string) ([]byte, error) {
+	if dir != "" {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return data, err
+		}
+	}
+	return builtin.ReadFile("fixtures/" + name)
+}
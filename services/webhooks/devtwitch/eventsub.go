@@ -0,0 +1,286 @@
+package devtwitch
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"services/webhooks/eventsub"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type transport struct {
+	Method    string `json:"method"`
+	Callback  string `json:"callback,omitempty"`
+	ConduitID string `json:"conduit_id,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+}
+
+type subscription struct {
+	ID        string             `json:"id"`
+	Status    string             `json:"status"`
+	Type      string             `json:"type"`
+	Version   string             `json:"version"`
+	Condition eventsub.Condition `json:"condition"`
+	CreatedAt string             `json:"created_at"`
+	Transport transport          `json:"transport"`
+	Cost      int                `json:"cost"`
+}
+
+type conduit struct {
+	ID         string `json:"id"`
+	ShardCount int    `json:"shard_count"`
+}
+
+type shard struct {
+	ID        string    `json:"id"`
+	Status    string    `json:"status"`
+	Transport transport `json:"transport"`
+}
+
+// state holds subscriptions and conduits created at mocked Twitch, it is lost on restart
+type state struct {
+	// secret signs callbacks like TWITCH_EVENTSUB_SECRET at Twitch
+	secret string
+	// fixtures is directory overriding built-in fixtures
+	fixtures string
+
+	mutex         sync.Mutex
+	subscriptions []subscription
+	conduits      []conduit
+	// shards by conduit and shard id
+	shards map[string]map[string]shard
+}
+
+// newID returns random id formatted like UUIDs of Twitch
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError answers in format of Helix errors
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]any{"error": http.StatusText(status), "status": status, "message": message})
+}
+
+// mentions tells if user is any of users in condition, like user_id filter of Twitch
+func mentions(condition eventsub.Condition, userId string) bool {
+	return slices.ContainsFunc([]*string{
+		condition.BroadcasterUserID,
+		condition.FromBroadcasterUserID,
+		condition.ToBroadcasterUserID,
+		condition.ModeratorUserID,
+		condition.UserId,
+	}, func(id *string) bool {
+		return id != nil && *id == userId
+	})
+}
+
+func (s *state) listSubscriptions(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	query := r.URL.Query()
+	data := []subscription{}
+	for _, item := range s.subscriptions {
+		if query.Has("user_id") && !mentions(item.Condition, query.Get("user_id")) {
+			continue
+		}
+		if query.Has("type") && item.Type != query.Get("type") {
+			continue
+		}
+		if query.Has("status") && item.Status != query.Get("status") {
+			continue
+		}
+		data = append(data, item)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"data":           data,
+		"total":          len(data),
+		"total_cost":     0,
+		"max_total_cost": 10000,
+		"pagination":     map[string]any{},
+	})
+}
+
+// createSubscription echoes requested subscription, webhooks are verified and
+// recorded notifications are sent once subscription is enabled
+func (s *state) createSubscription(w http.ResponseWriter, r *http.Request) {
+	var request subscription
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if request.Type == "" || request.Version == "" || request.Transport.Method == "" {
+		writeError(w, http.StatusBadRequest, "type, version and transport are required")
+		return
+	}
+
+	s.mutex.Lock()
+	for _, item := range s.subscriptions {
+		if item.Type == request.Type && item.Version == request.Version && item.Condition.Equal(&request.Condition) && item.Transport == request.Transport {
+			s.mutex.Unlock()
+			writeError(w, http.StatusConflict, "subscription already exists")
+			return
+		}
+	}
+	created := subscription{
+		ID:        newID(),
+		Status:    "enabled",
+		Type:      request.Type,
+		Version:   request.Version,
+		Condition: request.Condition,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+		Transport: request.Transport,
+	}
+	if created.Transport.Method == "webhook" {
+		created.Status = "webhook_callback_verification_pending"
+	}
+	s.subscriptions = append(s.subscriptions, created)
+	total := len(s.subscriptions)
+	s.mutex.Unlock()
+
+	writeJSON(w, http.StatusAccepted, map[string]any{
+		"data":           []subscription{created},
+		"total":          total,
+		"total_cost":     0,
+		"max_total_cost": 10000,
+	})
+	// like Twitch, callbacks come after the subscription is created
+	time.AfterFunc(time.Second, func() { s.deliver(created) })
+}
+
+func (s *state) deleteSubscription(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	id := r.URL.Query().Get("id")
+	i := slices.IndexFunc(s.subscriptions, func(item subscription) bool { return item.ID == id })
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "subscription not found")
+		return
+	}
+	s.subscriptions = slices.Delete(s.subscriptions, i, i+1)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setStatus changes status of subscription, it tells if subscription still exists
+func (s *state) setStatus(id string, status string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.subscriptions {
+		if s.subscriptions[i].ID == id {
+			s.subscriptions[i].Status = status
+			return true
+		}
+	}
+	return false
+}
+
+// callback returns URL conduit subscription is delivered to, the first shard with callback
+func (s *state) callback(conduitId string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ids := []string{}
+	for id := range s.shards[conduitId] {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := strconv.Atoi(ids[i])
+		b, _ := strconv.Atoi(ids[j])
+		return a < b
+	})
+	for _, id := range ids {
+		if callback := s.shards[conduitId][id].Transport.Callback; callback != "" {
+			return callback
+		}
+	}
+	return ""
+}
+
+func (s *state) listConduits(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"data": append([]conduit{}, s.conduits...)})
+}
+
+func (s *state) createConduit(w http.ResponseWriter, r *http.Request) {
+	var request conduit
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.ShardCount < 1 {
+		writeError(w, http.StatusBadRequest, "shard_count must be at least 1")
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	created := conduit{ID: newID(), ShardCount: request.ShardCount}
+	s.conduits = append(s.conduits, created)
+	writeJSON(w, http.StatusOK, map[string]any{"data": []conduit{created}})
+}
+
+func (s *state) updateConduit(w http.ResponseWriter, r *http.Request) {
+	var request conduit
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.ShardCount < 1 {
+		writeError(w, http.StatusBadRequest, "id and shard_count are required")
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.conduits {
+		if s.conduits[i].ID == request.ID {
+			s.conduits[i].ShardCount = request.ShardCount
+			writeJSON(w, http.StatusOK, map[string]any{"data": []conduit{s.conduits[i]}})
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "conduit not found")
+}
+
+func (s *state) listShards(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	data := []shard{}
+	for _, item := range s.shards[r.URL.Query().Get("conduit_id")] {
+		data = append(data, item)
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].ID < data[j].ID })
+	writeJSON(w, http.StatusOK, map[string]any{"data": data, "pagination": map[string]any{}})
+}
+
+// updateShards enables shards right away, shard callbacks are not verified
+func (s *state) updateShards(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ConduitID string  `json:"conduit_id"`
+		Shards    []shard `json:"shards"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !slices.ContainsFunc(s.conduits, func(item conduit) bool { return item.ID == request.ConduitID }) {
+		writeError(w, http.StatusNotFound, "conduit not found")
+		return
+	}
+	if s.shards[request.ConduitID] == nil {
+		s.shards[request.ConduitID] = map[string]shard{}
+	}
+	for i := range request.Shards {
+		request.Shards[i].Status = "enabled"
+		s.shards[request.ConduitID][request.Shards[i].ID] = request.Shards[i]
+	}
+	slog.Debug("Mocked conduit shards updated", slog.String("conduit_id", request.ConduitID), slog.Int("shards", len(request.Shards)))
+	writeJSON(w, http.StatusAccepted, map[string]any{"data": request.Shards, "errors": []any{}})
+}
@@ -0,0 +1,125 @@
+package devtwitch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"services/shared/logging"
+	"services/webhooks/forward"
+	"strings"
+	"time"
+)
+
+// otherUserId stands for users other than owner of subscription in notifications
+const otherUserId = "98765"
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// deliver verifies webhook of subscription and sends its recorded notification,
+// notifications of WebSocket sessions are not mocked
+func (s *state) deliver(sub subscription) {
+	logger := slog.With(slog.String("subscription_id", sub.ID), logging.EventType(sub.Type))
+	var callback string
+	switch sub.Transport.Method {
+	case "webhook":
+		callback = sub.Transport.Callback
+		status := "enabled"
+		if err := s.verify(callback, sub); err != nil {
+			logger.Warn("Mocked webhook verification failed", logging.Error(err))
+			status = "webhook_callback_verification_failed"
+		}
+		sub.Status = status
+		if !s.setStatus(sub.ID, status) || status != "enabled" {
+			return
+		}
+	case "conduit":
+		if callback = s.callback(sub.Transport.ConduitID); callback == "" {
+			logger.Warn("Mocked conduit has no shard to deliver to", slog.String("conduit_id", sub.Transport.ConduitID))
+			return
+		}
+	default:
+		logger.Debug("Notifications are not mocked for transport", slog.String("transport", sub.Transport.Method))
+		return
+	}
+
+	data, err := load(s.fixtures, "notifications/"+sub.Type+".json")
+	if err != nil {
+		logger.Debug("No recorded notification of subscription type")
+		return
+	}
+	owner := sub.Condition.Owner()
+	replacer := strings.NewReplacer(
+		"{broadcaster_user_id}", owner,
+		"{from_broadcaster_user_id}", valueOr(sub.Condition.FromBroadcasterUserID, otherUserId),
+		"{to_broadcaster_user_id}", valueOr(sub.Condition.ToBroadcasterUserID, otherUserId),
+		"{moderator_user_id}", valueOr(sub.Condition.ModeratorUserID, owner),
+		"{message_id}", newID(),
+	)
+	body, err := json.Marshal(map[string]any{
+		"subscription": sub,
+		"event":        json.RawMessage(replacer.Replace(string(data))),
+	})
+	if err != nil {
+		logger.Error("Invalid recorded notification", logging.Error(err))
+		return
+	}
+	status, _, err := s.post(callback, "notification", sub, body)
+	if err != nil {
+		logger.Warn("Error sending mocked notification", logging.Error(err))
+		return
+	}
+	logger.Info("Mocked notification sent", slog.String("callback", callback), slog.Int("status", status))
+}
+
+// verify sends challenge to callback, it has to be echoed
+func (s *state) verify(callback string, sub subscription) error {
+	challenge := newID()
+	body, err := json.Marshal(map[string]any{"challenge": challenge, "subscription": sub})
+	if err != nil {
+		return err
+	}
+	status, response, err := s.post(callback, "webhook_callback_verification", sub, body)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK || string(response) != challenge {
+		return fmt.Errorf("callback answered %d %q instead of challenge", status, response)
+	}
+	return nil
+}
+
+// post sends message signed like EventSub callbacks of Twitch
+func (s *state) post(callback string, messageType string, sub subscription, body []byte) (int, []byte, error) {
+	req, err := http.NewRequest(http.MethodPost, callback, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	messageId := newID()
+	timestamp := time.Now().UTC().Format(time.RFC3339Nano)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Twitch-Eventsub-Message-Id", messageId)
+	req.Header.Set("Twitch-Eventsub-Message-Retry", "0")
+	req.Header.Set("Twitch-Eventsub-Message-Type", messageType)
+	req.Header.Set("Twitch-Eventsub-Message-Timestamp", timestamp)
+	req.Header.Set("Twitch-Eventsub-Message-Signature", forward.Sign(s.secret, messageId, timestamp, body))
+	req.Header.Set("Twitch-Eventsub-Subscription-Type", sub.Type)
+	req.Header.Set("Twitch-Eventsub-Subscription-Version", sub.Version)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	response, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, response, err
+}
+
+func valueOr(value *string, fallback string) string {
+	if value == nil {
+		return fallback
+	}
+	return *value
+}
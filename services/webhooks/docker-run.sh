@@ -1,2 +1,2 @@
 # /bin/bash
-docker build . -t sogebot/webhooks:latest && docker run sogebot/webhooks:latest
\ No newline at end of file
+docker build .. -f Dockerfile -t sogebot/webhooks:latest && docker run sogebot/webhooks:latest
\ No newline at end of file
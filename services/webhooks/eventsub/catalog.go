@@ -0,0 +1,221 @@
+package eventsub
+
+import (
+	"slices"
+	"strings"
+)
+
+// Definition describes one subscription created for every user with Scope,
+// Scope is space separated list of required scopes, alternatives of one scope
+// are separated by |. Empty Scope means the subscription does not need any scope
+type Definition struct {
+	Type    string
+	Version string
+	Scope   string
+	// Condition returns condition of subscription for broadcaster, moderatorId
+	// is broadcaster itself or bot account linked to it
+	Condition func(broadcasterId string, moderatorId string) map[string]interface{}
+	// Moderated subscriptions can be created by linked bot with Scope when
+	// broadcaster does not have it
+	Moderated bool
+	// RewardFiltered subscriptions are created per reward when broadcaster has
+	// reward filters, reward_id is added to Condition
+	RewardFiltered bool
+	// Supersedes are older versions of Type, their subscriptions are deleted
+	// once subscription of Version is verified
+	Supersedes []string
+	// New returns pointer to event model
+	New func() any
+}
+
+func broadcaster(broadcasterId string, moderatorId string) map[string]interface{} {
+	return map[string]interface{}{
+		"broadcaster_user_id": broadcasterId,
+	}
+}
+
+func moderator(broadcasterId string, moderatorId string) map[string]interface{} {
+	return map[string]interface{}{
+		"broadcaster_user_id": broadcasterId,
+		"moderator_user_id":   moderatorId,
+	}
+}
+
+// chatter reads chat of broadcaster as user_id
+func chatter(broadcasterId string, moderatorId string) map[string]interface{} {
+	return map[string]interface{}{
+		"broadcaster_user_id": broadcasterId,
+		"user_id":             moderatorId,
+	}
+}
+
+func model[T any]() func() any {
+	return func() any { return new(T) }
+}
+
+// moderateScopes are needed for channel.moderate, it reports actions of all of them
+const moderateScopes = "moderator:read:blocked_terms|moderator:manage:blocked_terms" +
+	" moderator:read:chat_settings|moderator:manage:chat_settings" +
+	" moderator:read:unban_requests|moderator:manage:unban_requests" +
+	" moderator:read:banned_users|moderator:manage:banned_users" +
+	" moderator:read:chat_messages|moderator:manage:chat_messages" +
+	" moderator:read:warnings|moderator:manage:warnings" +
+	" moderator:read:moderators|channel:manage:moderators" +
+	" moderator:read:vips|channel:manage:vips"
+
+var Catalog = []Definition{
+	{
+		Type: "channel.raid", Version: "1",
+		Condition: func(userId string, _ string) map[string]interface{} {
+			return map[string]interface{}{"to_broadcaster_user_id": userId}
+		},
+		New: model[ChannelRaid](),
+	}, {
+		Type: "channel.raid", Version: "1",
+		Condition: func(userId string, _ string) map[string]interface{} {
+			return map[string]interface{}{"from_broadcaster_user_id": userId}
+		},
+		New: model[ChannelRaid](),
+	},
+	{Type: "channel.update", Version: "2", Supersedes: []string{"1"}, Condition: broadcaster, New: model[ChannelUpdate]()},
+	{Type: "stream.online", Version: "1", Condition: broadcaster, New: model[StreamOnline]()},
+	{Type: "stream.offline", Version: "1", Condition: broadcaster, New: model[StreamOffline]()},
+	{
+		Type: "user.update", Version: "1", Scope: "user:read:email",
+		Condition: func(userId string, _ string) map[string]interface{} {
+			return map[string]interface{}{"user_id": userId}
+		},
+		New: model[UserUpdate](),
+	},
+	{Type: "channel.follow", Version: "2", Supersedes: []string{"1"}, Scope: "moderator:read:followers", Condition: moderator, Moderated: true, New: model[ChannelFollow]()},
+
+	{Type: "channel.channel_points_custom_reward_redemption.add", Version: "1", Scope: "channel:read:redemptions", Condition: broadcaster, RewardFiltered: true, New: model[ChannelPointsCustomRewardRedemption]()},
+	{Type: "channel.channel_points_custom_reward_redemption.update", Version: "1", Scope: "channel:read:redemptions", Condition: broadcaster, RewardFiltered: true, New: model[ChannelPointsCustomRewardRedemption]()},
+	{Type: "channel.channel_points_custom_reward.add", Version: "1", Scope: "channel:read:redemptions", Condition: broadcaster, New: model[ChannelPointsCustomReward]()},
+	{Type: "channel.channel_points_custom_reward.update", Version: "1", Scope: "channel:read:redemptions", Condition: broadcaster, New: model[ChannelPointsCustomReward]()},
+	{Type: "channel.channel_points_custom_reward.remove", Version: "1", Scope: "channel:read:redemptions", Condition: broadcaster, New: model[ChannelPointsCustomReward]()},
+
+	{Type: "channel.subscribe", Version: "1", Scope: "channel:read:subscriptions", Condition: broadcaster, New: model[ChannelSubscribe]()},
+	{Type: "channel.subscription.gift", Version: "1", Scope: "channel:read:subscriptions", Condition: broadcaster, New: model[ChannelSubscriptionGift]()},
+	{Type: "channel.subscription.message", Version: "1", Scope: "channel:read:subscriptions", Condition: broadcaster, New: model[ChannelSubscriptionMessage]()},
+	{Type: "channel.subscription.end", Version: "1", Scope: "channel:read:subscriptions", Condition: broadcaster, New: model[ChannelSubscriptionEnd]()},
+
+	{Type: "channel.chat.message", Version: "1", Scope: "user:read:chat user:bot", Condition: chatter, Moderated: true, New: model[ChannelChatMessage]()},
+	{Type: "channel.chat.notification", Version: "1", Scope: "user:read:chat user:bot", Condition: chatter, Moderated: true, New: model[ChannelChatNotification]()},
+	{Type: "channel.chat.clear", Version: "1", Scope: "user:read:chat user:bot", Condition: chatter, Moderated: true, New: model[ChannelChatClear]()},
+	{Type: "channel.chat.message_delete", Version: "1", Scope: "user:read:chat user:bot", Condition: chatter, Moderated: true, New: model[ChannelChatMessageDelete]()},
+
+	{Type: "channel.cheer", Version: "1", Scope: "bits:read", Condition: broadcaster, New: model[ChannelCheer]()},
+
+	{Type: "channel.ban", Version: "1", Scope: "channel:moderate", Condition: broadcaster, New: model[ChannelBan]()},
+	{Type: "channel.unban", Version: "1", Scope: "channel:moderate", Condition: broadcaster, New: model[ChannelUnban]()},
+
+	{Type: "channel.moderate", Version: "2", Scope: moderateScopes, Condition: moderator, Moderated: true, New: model[ChannelModerate]()},
+	{Type: "channel.warning.send", Version: "1", Scope: "moderator:read:warnings|moderator:manage:warnings", Condition: moderator, Moderated: true, New: model[ChannelWarningSend]()},
+	{Type: "channel.warning.acknowledge", Version: "1", Scope: "moderator:read:warnings|moderator:manage:warnings", Condition: moderator, Moderated: true, New: model[ChannelWarningAcknowledge]()},
+	{Type: "channel.unban_request.create", Version: "1", Scope: "moderator:read:unban_requests|moderator:manage:unban_requests", Condition: moderator, Moderated: true, New: model[ChannelUnbanRequestCreate]()},
+	{Type: "channel.unban_request.resolve", Version: "1", Scope: "moderator:read:unban_requests|moderator:manage:unban_requests", Condition: moderator, Moderated: true, New: model[ChannelUnbanRequestResolve]()},
+	{Type: "channel.suspicious_user.message", Version: "1", Scope: "moderator:read:suspicious_users", Condition: moderator, Moderated: true, New: model[ChannelSuspiciousUserMessage]()},
+	{Type: "channel.suspicious_user.update", Version: "1", Scope: "moderator:read:suspicious_users", Condition: moderator, Moderated: true, New: model[ChannelSuspiciousUserUpdate]()},
+	{Type: "automod.message.hold", Version: "1", Scope: "moderator:manage:automod", Condition: moderator, Moderated: true, New: model[AutomodMessageHold]()},
+	{Type: "automod.message.update", Version: "1", Scope: "moderator:manage:automod", Condition: moderator, Moderated: true, New: model[AutomodMessageUpdate]()},
+
+	{Type: "channel.prediction.begin", Version: "1", Scope: "channel:read:predictions", Condition: broadcaster, New: model[ChannelPredictionBegin]()},
+	{Type: "channel.prediction.progress", Version: "1", Scope: "channel:read:predictions", Condition: broadcaster, New: model[ChannelPredictionProgress]()},
+	{Type: "channel.prediction.lock", Version: "1", Scope: "channel:read:predictions", Condition: broadcaster, New: model[ChannelPredictionLock]()},
+	{Type: "channel.prediction.end", Version: "1", Scope: "channel:read:predictions", Condition: broadcaster, New: model[ChannelPredictionEnd]()},
+
+	{Type: "channel.poll.begin", Version: "1", Scope: "channel:read:polls", Condition: broadcaster, New: model[ChannelPollBegin]()},
+	{Type: "channel.poll.progress", Version: "1", Scope: "channel:read:polls", Condition: broadcaster, New: model[ChannelPollProgress]()},
+	{Type: "channel.poll.end", Version: "1", Scope: "channel:read:polls", Condition: broadcaster, New: model[ChannelPollEnd]()},
+
+	{Type: "channel.hype_train.begin", Version: "1", Scope: "channel:read:hype_train", Condition: broadcaster, New: model[ChannelHypeTrainBegin]()},
+	{Type: "channel.hype_train.progress", Version: "1", Scope: "channel:read:hype_train", Condition: broadcaster, New: model[ChannelHypeTrainProgress]()},
+	{Type: "channel.hype_train.end", Version: "1", Scope: "channel:read:hype_train", Condition: broadcaster, New: model[ChannelHypeTrainEnd]()},
+
+	{Type: "channel.charity_campaign.donate", Version: "1", Scope: "channel:read:charity", Condition: broadcaster, New: model[ChannelCharityCampaignDonate]()},
+	{Type: "channel.charity_campaign.start", Version: "1", Scope: "channel:read:charity", Condition: broadcaster, New: model[ChannelCharityCampaignStart]()},
+	{Type: "channel.charity_campaign.progress", Version: "1", Scope: "channel:read:charity", Condition: broadcaster, New: model[ChannelCharityCampaignProgress]()},
+	{Type: "channel.charity_campaign.stop", Version: "1", Scope: "channel:read:charity", Condition: broadcaster, New: model[ChannelCharityCampaignStop]()},
+
+	{Type: "channel.goal.begin", Version: "1", Scope: "channel:read:goals", Condition: broadcaster, New: model[ChannelGoal]()},
+	{Type: "channel.goal.progress", Version: "1", Scope: "channel:read:goals", Condition: broadcaster, New: model[ChannelGoal]()},
+	{Type: "channel.goal.end", Version: "1", Scope: "channel:read:goals", Condition: broadcaster, New: model[ChannelGoal]()},
+
+	{Type: "channel.moderator.add", Version: "1", Scope: "moderation:read", Condition: broadcaster, New: model[ChannelModerator]()},
+	{Type: "channel.moderator.remove", Version: "1", Scope: "moderation:read", Condition: broadcaster, New: model[ChannelModerator]()},
+
+	{Type: "channel.shield_mode.begin", Version: "1", Scope: "moderator:read:shield_mode", Condition: moderator, Moderated: true, New: model[ChannelShieldModeBegin]()},
+	{Type: "channel.shield_mode.end", Version: "1", Scope: "moderator:read:shield_mode", Condition: moderator, Moderated: true, New: model[ChannelShieldModeEnd]()},
+
+	{Type: "channel.ad_break.begin", Version: "1", Scope: "channel:read:ads", Condition: broadcaster, New: model[ChannelAdBreakBegin]()},
+
+	{Type: "channel.shoutout.create", Version: "1", Scope: "moderator:read:shoutouts", Condition: moderator, Moderated: true, New: model[ChannelShoutoutCreate]()},
+	{Type: "channel.shoutout.receive", Version: "1", Scope: "moderator:read:shoutouts", Condition: moderator, Moderated: true, New: model[ChannelShoutoutReceive]()},
+}
+
+// Lookup returns definition of subscription type and version
+func Lookup(subscriptionType string, version string) (Definition, bool) {
+	for _, definition := range Catalog {
+		if definition.Type == subscriptionType && definition.Version == version {
+			return definition, true
+		}
+	}
+	return Definition{}, false
+}
+
+// LookupSuperseded returns definition of catalog version which supersedes
+// version of type, events of old subscription still arrive until it is deleted
+func LookupSuperseded(subscriptionType string, version string) (Definition, bool) {
+	for _, definition := range Catalog {
+		if definition.Type == subscriptionType && slices.Contains(definition.Supersedes, version) {
+			return definition, true
+		}
+	}
+	return Definition{}, false
+}
+
+// Label returns subscription type to be used as metric label, types outside of
+// catalog and custom subscriptions are "unknown" so labels stay bounded
+func Label(subscriptionType string) string {
+	for _, definition := range Catalog {
+		if definition.Type == subscriptionType {
+			return subscriptionType
+		}
+	}
+	for _, definition := range Custom {
+		if definition.Type == subscriptionType {
+			return subscriptionType
+		}
+	}
+	return "unknown"
+}
+
+// Authorized tells if user with scopes (space separated) can create the subscription
+func (d Definition) Authorized(scopes string) bool {
+	granted := strings.Fields(scopes)
+	for _, required := range strings.Fields(d.Scope) {
+		if !slices.ContainsFunc(strings.Split(required, "|"), func(scope string) bool {
+			return slices.Contains(granted, scope)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// RewardConditions returns conditions of RewardFiltered definition for
+// broadcaster, one per filtered reward or broadcaster wide one when there are
+// no filters
+func (d Definition) RewardConditions(broadcasterId string, rewardIds []string) []map[string]interface{} {
+	if len(rewardIds) == 0 {
+		return []map[string]interface{}{d.Condition(broadcasterId, broadcasterId)}
+	}
+	conditions := []map[string]interface{}{}
+	for _, rewardId := range rewardIds {
+		condition := d.Condition(broadcasterId, broadcasterId)
+		condition["reward_id"] = rewardId
+		conditions = append(conditions, condition)
+	}
+	return conditions
+}
@@ -0,0 +1,50 @@
+package eventsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Custom are subscriptions bots can request on top of Catalog, their events
+// are passed through without model
+var Custom []Definition
+
+// Configure parses allowlist of custom subscriptions, entries look like
+// type@version:scopes, e.g. channel.vip.add@1:channel:read:vips|channel:manage:vips,
+// multiple required scopes are joined by +
+func Configure(allowlist []string) error {
+	custom := []Definition{}
+	for _, entry := range allowlist {
+		// type and version have no colons, everything after first one are scopes
+		name, scope, _ := strings.Cut(entry, ":")
+		subscriptionType, version, ok := strings.Cut(name, "@")
+		if !ok || subscriptionType == "" || version == "" {
+			return fmt.Errorf("invalid custom subscription %q, expected type@version:scopes", entry)
+		}
+		for _, definition := range Catalog {
+			if definition.Type == subscriptionType && (definition.Version == version || slices.Contains(definition.Supersedes, version)) {
+				return fmt.Errorf("custom subscription %s v%s is managed by catalog", subscriptionType, version)
+			}
+		}
+		custom = append(custom, Definition{
+			Type:    subscriptionType,
+			Version: version,
+			Scope:   strings.ReplaceAll(scope, "+", " "),
+			New:     model[json.RawMessage](),
+		})
+	}
+	Custom = custom
+	return nil
+}
+
+// LookupCustom returns allowlisted custom subscription of type and version
+func LookupCustom(subscriptionType string, version string) (Definition, bool) {
+	for _, definition := range Custom {
+		if definition.Type == subscriptionType && definition.Version == version {
+			return definition, true
+		}
+	}
+	return Definition{}, false
+}
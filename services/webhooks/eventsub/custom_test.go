@@ -0,0 +1,127 @@
+package eventsub
+
+import "testing"
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   string
+		wantErr bool
+		// expected definition of valid entry
+		subscriptionType string
+		version          string
+		scope            string
+		// granted scopes checked by Authorized, with expected result
+		granted    string
+		authorized bool
+	}{
+		{
+			name:             "single scope",
+			entry:            "channel.vip.add@1:channel:read:vips",
+			subscriptionType: "channel.vip.add",
+			version:          "1",
+			scope:            "channel:read:vips",
+			granted:          "channel:read:vips",
+			authorized:       true,
+		},
+		{
+			name:             "alternative scopes",
+			entry:            "channel.vip.add@1:channel:read:vips|channel:manage:vips",
+			subscriptionType: "channel.vip.add",
+			version:          "1",
+			scope:            "channel:read:vips|channel:manage:vips",
+			granted:          "user:read:chat channel:manage:vips",
+			authorized:       true,
+		},
+		{
+			name:             "all scopes required",
+			entry:            "channel.guest_star_session.begin@beta:channel:read:guest_star+moderator:read:guest_star",
+			subscriptionType: "channel.guest_star_session.begin",
+			version:          "beta",
+			scope:            "channel:read:guest_star moderator:read:guest_star",
+			granted:          "channel:read:guest_star",
+			authorized:       false,
+		},
+		{
+			name:             "combined alternatives and required",
+			entry:            "channel.ad_break.begin@2:channel:read:ads|channel:manage:ads+user:read:chat",
+			subscriptionType: "channel.ad_break.begin",
+			version:          "2",
+			scope:            "channel:read:ads|channel:manage:ads user:read:chat",
+			granted:          "channel:manage:ads user:read:chat",
+			authorized:       true,
+		},
+		{
+			name:             "no scopes",
+			entry:            "stream.online@2",
+			subscriptionType: "stream.online",
+			version:          "2",
+			scope:            "",
+			granted:          "",
+			authorized:       true,
+		},
+		{
+			name:    "missing version",
+			entry:   "channel.vip.add:channel:read:vips",
+			wantErr: true,
+		},
+		{
+			name:    "empty version",
+			entry:   "channel.vip.add@:channel:read:vips",
+			wantErr: true,
+		},
+		{
+			name:    "empty type",
+			entry:   "@1:channel:read:vips",
+			wantErr: true,
+		},
+		{
+			name:    "managed by catalog",
+			entry:   "channel.channel_points_custom_reward.add@1:channel:read:redemptions",
+			wantErr: true,
+		},
+	}
+
+	defer Configure(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Configure([]string{tt.entry})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Configure(%q) succeeded, want error", tt.entry)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			definition, ok := LookupCustom(tt.subscriptionType, tt.version)
+			if !ok {
+				t.Fatalf("LookupCustom(%q, %q) found nothing", tt.subscriptionType, tt.version)
+			}
+			if definition.Scope != tt.scope {
+				t.Errorf("Scope = %q, want %q", definition.Scope, tt.scope)
+			}
+			if got := definition.Authorized(tt.granted); got != tt.authorized {
+				t.Errorf("Authorized(%q) = %v, want %v", tt.granted, got, tt.authorized)
+			}
+		})
+	}
+}
+
+func TestConfigureReplacesAllowlist(t *testing.T) {
+	defer Configure(nil)
+	if err := Configure([]string{"channel.vip.add@1:channel:read:vips"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Configure([]string{"channel.vip.remove@1:channel:read:vips", "invalid"}); err == nil {
+		t.Fatal("invalid allowlist was accepted")
+	}
+	if _, ok := LookupCustom("channel.vip.add", "1"); !ok {
+		t.Error("failed Configure changed allowlist")
+	}
+	if _, ok := LookupCustom("channel.vip.remove", "1"); ok {
+		t.Error("failed Configure added entry")
+	}
+}
@@ -0,0 +1,661 @@
+package eventsub
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Models of events in Catalog, see https://dev.twitch.tv/docs/eventsub/eventsub-reference/
+
+type Broadcaster struct {
+	BroadcasterUserID    string `json:"broadcaster_user_id" validate:"required"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+}
+
+type User struct {
+	UserID    string `json:"user_id" validate:"required"`
+	UserLogin string `json:"user_login"`
+	UserName  string `json:"user_name"`
+}
+
+type Moderator struct {
+	ModeratorUserID    string `json:"moderator_user_id" validate:"required"`
+	ModeratorUserLogin string `json:"moderator_user_login"`
+	ModeratorUserName  string `json:"moderator_user_name"`
+}
+
+type ChannelRaid struct {
+	FromBroadcasterUserID    string `json:"from_broadcaster_user_id" validate:"required"`
+	FromBroadcasterUserLogin string `json:"from_broadcaster_user_login"`
+	FromBroadcasterUserName  string `json:"from_broadcaster_user_name"`
+	ToBroadcasterUserID      string `json:"to_broadcaster_user_id" validate:"required"`
+	ToBroadcasterUserLogin   string `json:"to_broadcaster_user_login"`
+	ToBroadcasterUserName    string `json:"to_broadcaster_user_name"`
+	Viewers                  int    `json:"viewers"`
+}
+
+type ChannelUpdate struct {
+	Broadcaster
+	Title                       string   `json:"title"`
+	Language                    string   `json:"language"`
+	CategoryID                  string   `json:"category_id"`
+	CategoryName                string   `json:"category_name"`
+	ContentClassificationLabels []string `json:"content_classification_labels"`
+}
+
+type StreamOnline struct {
+	// ID is id of the stream
+	ID string `json:"id" validate:"required"`
+	Broadcaster
+	// Type is live, playlist, watch_party, premiere or rerun
+	Type      string    `json:"type"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+type StreamOffline struct {
+	Broadcaster
+}
+
+type UserUpdate struct {
+	User
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Description   string `json:"description"`
+}
+
+type ChannelFollow struct {
+	User
+	Broadcaster
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type Reward struct {
+	ID     string `json:"id" validate:"required"`
+	Title  string `json:"title"`
+	Cost   int    `json:"cost"`
+	Prompt string `json:"prompt"`
+}
+
+type ChannelPointsCustomRewardRedemption struct {
+	ID string `json:"id" validate:"required"`
+	Broadcaster
+	User
+	UserInput  string    `json:"user_input"`
+	Status     string    `json:"status"`
+	Reward     Reward    `json:"reward"`
+	RedeemedAt time.Time `json:"redeemed_at"`
+}
+
+type Image struct {
+	URL1x string `json:"url_1x"`
+	URL2x string `json:"url_2x"`
+	URL4x string `json:"url_4x"`
+}
+
+type ChannelPointsCustomReward struct {
+	ID string `json:"id" validate:"required"`
+	Broadcaster
+	IsEnabled                         bool       `json:"is_enabled"`
+	IsPaused                          bool       `json:"is_paused"`
+	IsInStock                         bool       `json:"is_in_stock"`
+	Title                             string     `json:"title"`
+	Cost                              int        `json:"cost"`
+	Prompt                            string     `json:"prompt"`
+	IsUserInputRequired               bool       `json:"is_user_input_required"`
+	ShouldRedemptionsSkipRequestQueue bool       `json:"should_redemptions_skip_request_queue"`
+	CooldownExpiresAt                 *time.Time `json:"cooldown_expires_at"`
+	RedemptionsRedeemedCurrentStream  *int       `json:"redemptions_redeemed_current_stream"`
+	MaxPerStream                      struct {
+		IsEnabled bool `json:"is_enabled"`
+		Value     int  `json:"value"`
+	} `json:"max_per_stream"`
+	MaxPerUserPerStream struct {
+		IsEnabled bool `json:"is_enabled"`
+		Value     int  `json:"value"`
+	} `json:"max_per_user_per_stream"`
+	GlobalCooldown struct {
+		IsEnabled bool `json:"is_enabled"`
+		Seconds   int  `json:"seconds"`
+	} `json:"global_cooldown"`
+	BackgroundColor string `json:"background_color"`
+	Image           *Image `json:"image"`
+	DefaultImage    Image  `json:"default_image"`
+}
+
+type ChannelSubscribe struct {
+	User
+	Broadcaster
+	// Tier is 1000, 2000 or 3000
+	Tier   string `json:"tier" validate:"required"`
+	IsGift bool   `json:"is_gift"`
+}
+
+type ChannelSubscriptionGift struct {
+	Broadcaster
+	// user fields are empty for anonymous gifts
+	UserID          string `json:"user_id"`
+	UserLogin       string `json:"user_login"`
+	UserName        string `json:"user_name"`
+	Total           int    `json:"total" validate:"gt=0"`
+	Tier            string `json:"tier" validate:"required"`
+	CumulativeTotal *int   `json:"cumulative_total"`
+	IsAnonymous     bool   `json:"is_anonymous"`
+}
+
+type Emote struct {
+	Begin int    `json:"begin"`
+	End   int    `json:"end"`
+	ID    string `json:"id"`
+}
+
+type ChannelSubscriptionMessage struct {
+	User
+	Broadcaster
+	Tier    string `json:"tier" validate:"required"`
+	Message struct {
+		Text   string  `json:"text"`
+		Emotes []Emote `json:"emotes"`
+	} `json:"message"`
+	CumulativeMonths int  `json:"cumulative_months"`
+	StreakMonths     *int `json:"streak_months"`
+	DurationMonths   int  `json:"duration_months"`
+}
+
+type ChannelSubscriptionEnd struct {
+	User
+	Broadcaster
+	Tier   string `json:"tier" validate:"required"`
+	IsGift bool   `json:"is_gift"`
+}
+
+// GiftBombType is type of synthetic event combining channel.subscription.gift
+// with channel.subscribe events it spawned, it is not sent by Twitch
+const GiftBombType = "channel.subscription.gift_bomb"
+
+type GiftRecipient struct {
+	UserID    string `json:"user_id"`
+	UserLogin string `json:"user_login"`
+	UserName  string `json:"user_name"`
+}
+
+type ChannelSubscriptionGiftBomb struct {
+	ID string `json:"id"`
+	Broadcaster
+	UserID          string          `json:"user_id"`
+	UserLogin       string          `json:"user_login"`
+	UserName        string          `json:"user_name"`
+	IsAnonymous     bool            `json:"is_anonymous"`
+	Tier            string          `json:"tier"`
+	Total           int             `json:"total"`
+	CumulativeTotal *int            `json:"cumulative_total"`
+	Recipients      []GiftRecipient `json:"recipients"`
+	// Complete is false when not all gifted subscriptions arrived in time
+	Complete  bool      `json:"complete"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+type Chatter struct {
+	ChatterUserID    string `json:"chatter_user_id"`
+	ChatterUserLogin string `json:"chatter_user_login"`
+	ChatterUserName  string `json:"chatter_user_name"`
+}
+
+type Badge struct {
+	SetID string `json:"set_id"`
+	ID    string `json:"id"`
+	Info  string `json:"info"`
+}
+
+// Fragment is part of chat message, only field matching Type is set
+type Fragment struct {
+	// Type is text, cheermote, emote or mention
+	Type      string `json:"type"`
+	Text      string `json:"text"`
+	Cheermote *struct {
+		Prefix string `json:"prefix"`
+		Bits   int    `json:"bits"`
+		Tier   int    `json:"tier"`
+	} `json:"cheermote"`
+	Emote *struct {
+		ID         string   `json:"id"`
+		EmoteSetID string   `json:"emote_set_id"`
+		OwnerID    string   `json:"owner_id"`
+		Format     []string `json:"format"`
+	} `json:"emote"`
+	Mention *struct {
+		UserID    string `json:"user_id"`
+		UserLogin string `json:"user_login"`
+		UserName  string `json:"user_name"`
+	} `json:"mention"`
+}
+
+type ChatMessage struct {
+	Text      string     `json:"text"`
+	Fragments []Fragment `json:"fragments"`
+}
+
+type ChannelChatMessage struct {
+	Broadcaster
+	Chatter
+	MessageID string      `json:"message_id" validate:"required"`
+	Message   ChatMessage `json:"message"`
+	// MessageType is text, channel_points_highlighted, channel_points_sub_only,
+	// user_intro, power_ups_message_effect or power_ups_gigantified_emote
+	MessageType string  `json:"message_type"`
+	Badges      []Badge `json:"badges"`
+	Cheer       *struct {
+		Bits int `json:"bits"`
+	} `json:"cheer"`
+	Color string `json:"color"`
+	Reply *struct {
+		ParentMessageID   string `json:"parent_message_id"`
+		ParentMessageBody string `json:"parent_message_body"`
+		ParentUserID      string `json:"parent_user_id"`
+		ParentUserLogin   string `json:"parent_user_login"`
+		ParentUserName    string `json:"parent_user_name"`
+		ThreadMessageID   string `json:"thread_message_id"`
+		ThreadUserID      string `json:"thread_user_id"`
+		ThreadUserLogin   string `json:"thread_user_login"`
+		ThreadUserName    string `json:"thread_user_name"`
+	} `json:"reply"`
+	ChannelPointsCustomRewardID *string `json:"channel_points_custom_reward_id"`
+}
+
+type ChannelChatNotification struct {
+	Broadcaster
+	// chatter fields are empty for anonymous notifications
+	Chatter
+	ChatterIsAnonymous bool        `json:"chatter_is_anonymous"`
+	Color              string      `json:"color"`
+	Badges             []Badge     `json:"badges"`
+	SystemMessage      string      `json:"system_message"`
+	MessageID          string      `json:"message_id" validate:"required"`
+	Message            ChatMessage `json:"message"`
+	// NoticeType names the field with details, e.g. sub_gift for sub_gift
+	NoticeType       string          `json:"notice_type" validate:"required"`
+	Sub              json.RawMessage `json:"sub"`
+	Resub            json.RawMessage `json:"resub"`
+	SubGift          json.RawMessage `json:"sub_gift"`
+	CommunitySubGift json.RawMessage `json:"community_sub_gift"`
+	GiftPaidUpgrade  json.RawMessage `json:"gift_paid_upgrade"`
+	PrimePaidUpgrade json.RawMessage `json:"prime_paid_upgrade"`
+	Raid             json.RawMessage `json:"raid"`
+	Unraid           json.RawMessage `json:"unraid"`
+	PayItForward     json.RawMessage `json:"pay_it_forward"`
+	Announcement     json.RawMessage `json:"announcement"`
+	CharityDonation  json.RawMessage `json:"charity_donation"`
+	BitsBadgeTier    json.RawMessage `json:"bits_badge_tier"`
+}
+
+type ChannelChatClear struct {
+	Broadcaster
+}
+
+type ChannelChatMessageDelete struct {
+	Broadcaster
+	TargetUserID    string `json:"target_user_id"`
+	TargetUserLogin string `json:"target_user_login"`
+	TargetUserName  string `json:"target_user_name"`
+	MessageID       string `json:"message_id" validate:"required"`
+}
+
+type ChannelCheer struct {
+	Broadcaster
+	IsAnonymous bool `json:"is_anonymous"`
+	// user fields are empty for anonymous cheers
+	UserID    string `json:"user_id"`
+	UserLogin string `json:"user_login"`
+	UserName  string `json:"user_name"`
+	Message   string `json:"message"`
+	Bits      int    `json:"bits" validate:"gt=0"`
+}
+
+type ChannelBan struct {
+	User
+	Broadcaster
+	Moderator
+	Reason      string     `json:"reason"`
+	BannedAt    time.Time  `json:"banned_at"`
+	EndsAt      *time.Time `json:"ends_at"`
+	IsPermanent bool       `json:"is_permanent"`
+}
+
+type ChannelUnban struct {
+	User
+	Broadcaster
+	Moderator
+}
+
+// ChannelModerate is one moderation action, only field named by Action is set,
+// e.g. timeout for timeout and followers for followers mode
+type ChannelModerate struct {
+	Broadcaster
+	Moderator
+	Action       string          `json:"action" validate:"required"`
+	Followers    json.RawMessage `json:"followers"`
+	Slow         json.RawMessage `json:"slow"`
+	Vip          json.RawMessage `json:"vip"`
+	Unvip        json.RawMessage `json:"unvip"`
+	Mod          json.RawMessage `json:"mod"`
+	Unmod        json.RawMessage `json:"unmod"`
+	Ban          json.RawMessage `json:"ban"`
+	Unban        json.RawMessage `json:"unban"`
+	Timeout      json.RawMessage `json:"timeout"`
+	Untimeout    json.RawMessage `json:"untimeout"`
+	Raid         json.RawMessage `json:"raid"`
+	Unraid       json.RawMessage `json:"unraid"`
+	Delete       json.RawMessage `json:"delete"`
+	AutomodTerms json.RawMessage `json:"automod_terms"`
+	UnbanRequest json.RawMessage `json:"unban_request"`
+	Warn         json.RawMessage `json:"warn"`
+}
+
+type ChannelWarningSend struct {
+	Broadcaster
+	Moderator
+	User
+	Reason         *string  `json:"reason"`
+	ChatRulesCited []string `json:"chat_rules_cited"`
+}
+
+type ChannelWarningAcknowledge struct {
+	Broadcaster
+	User
+}
+
+type ChannelUnbanRequestCreate struct {
+	ID string `json:"id" validate:"required"`
+	Broadcaster
+	User
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ChannelUnbanRequestResolve struct {
+	ID string `json:"id" validate:"required"`
+	Broadcaster
+	// moderator fields are empty when user canceled the request
+	ModeratorUserID    string `json:"moderator_user_id"`
+	ModeratorUserLogin string `json:"moderator_user_login"`
+	ModeratorUserName  string `json:"moderator_user_name"`
+	User
+	ResolutionText string `json:"resolution_text"`
+	// Status is approved, canceled or denied
+	Status string `json:"status" validate:"required"`
+}
+
+type ChannelSuspiciousUserMessage struct {
+	Broadcaster
+	User
+	// LowTrustStatus is none, active_monitoring or restricted
+	LowTrustStatus       string   `json:"low_trust_status"`
+	SharedBanChannelIDs  []string `json:"shared_ban_channel_ids"`
+	Types                []string `json:"types"`
+	BanEvasionEvaluation string   `json:"ban_evasion_evaluation"`
+	Message              struct {
+		MessageID string     `json:"message_id"`
+		Text      string     `json:"text"`
+		Fragments []Fragment `json:"fragments"`
+	} `json:"message"`
+}
+
+type ChannelSuspiciousUserUpdate struct {
+	Broadcaster
+	Moderator
+	User
+	LowTrustStatus string `json:"low_trust_status"`
+}
+
+type AutomodMessageHold struct {
+	Broadcaster
+	User
+	MessageID string      `json:"message_id" validate:"required"`
+	Message   ChatMessage `json:"message"`
+	Category  string      `json:"category"`
+	Level     int         `json:"level"`
+	HeldAt    time.Time   `json:"held_at"`
+}
+
+type AutomodMessageUpdate struct {
+	Broadcaster
+	User
+	Moderator
+	MessageID string      `json:"message_id" validate:"required"`
+	Message   ChatMessage `json:"message"`
+	Category  string      `json:"category"`
+	Level     int         `json:"level"`
+	// Status is approved, denied or expired
+	Status string    `json:"status" validate:"required"`
+	HeldAt time.Time `json:"held_at"`
+}
+
+type Predictor struct {
+	UserID            string `json:"user_id"`
+	UserLogin         string `json:"user_login"`
+	UserName          string `json:"user_name"`
+	ChannelPointsWon  *int   `json:"channel_points_won"`
+	ChannelPointsUsed int    `json:"channel_points_used"`
+}
+
+type Outcome struct {
+	ID            string      `json:"id" validate:"required"`
+	Title         string      `json:"title"`
+	Color         string      `json:"color"`
+	Users         int         `json:"users"`
+	ChannelPoints int         `json:"channel_points"`
+	TopPredictors []Predictor `json:"top_predictors"`
+}
+
+type ChannelPredictionBegin struct {
+	ID string `json:"id" validate:"required"`
+	Broadcaster
+	Title     string    `json:"title"`
+	Outcomes  []Outcome `json:"outcomes" validate:"dive"`
+	StartedAt time.Time `json:"started_at"`
+	LocksAt   time.Time `json:"locks_at"`
+}
+
+type ChannelPredictionProgress ChannelPredictionBegin
+
+type ChannelPredictionLock struct {
+	ID string `json:"id" validate:"required"`
+	Broadcaster
+	Title     string    `json:"title"`
+	Outcomes  []Outcome `json:"outcomes" validate:"dive"`
+	StartedAt time.Time `json:"started_at"`
+	LockedAt  time.Time `json:"locked_at"`
+}
+
+type ChannelPredictionEnd struct {
+	ID string `json:"id" validate:"required"`
+	Broadcaster
+	Title            string    `json:"title"`
+	WinningOutcomeID string    `json:"winning_outcome_id"`
+	Outcomes         []Outcome `json:"outcomes" validate:"dive"`
+	Status           string    `json:"status"`
+	StartedAt        time.Time `json:"started_at"`
+	EndedAt          time.Time `json:"ended_at"`
+}
+
+type Choice struct {
+	ID                 string `json:"id" validate:"required"`
+	Title              string `json:"title"`
+	BitsVotes          int    `json:"bits_votes"`
+	ChannelPointsVotes int    `json:"channel_points_votes"`
+	Votes              int    `json:"votes"`
+}
+
+type Voting struct {
+	IsEnabled     bool `json:"is_enabled"`
+	AmountPerVote int  `json:"amount_per_vote"`
+}
+
+type ChannelPollBegin struct {
+	ID string `json:"id" validate:"required"`
+	Broadcaster
+	Title               string    `json:"title"`
+	Choices             []Choice  `json:"choices" validate:"dive"`
+	BitsVoting          Voting    `json:"bits_voting"`
+	ChannelPointsVoting Voting    `json:"channel_points_voting"`
+	StartedAt           time.Time `json:"started_at"`
+	EndsAt              time.Time `json:"ends_at"`
+}
+
+type ChannelPollProgress ChannelPollBegin
+
+type ChannelPollEnd struct {
+	ID string `json:"id" validate:"required"`
+	Broadcaster
+	Title               string    `json:"title"`
+	Choices             []Choice  `json:"choices" validate:"dive"`
+	BitsVoting          Voting    `json:"bits_voting"`
+	ChannelPointsVoting Voting    `json:"channel_points_voting"`
+	Status              string    `json:"status"`
+	StartedAt           time.Time `json:"started_at"`
+	EndedAt             time.Time `json:"ended_at"`
+}
+
+type Contribution struct {
+	UserID    string `json:"user_id"`
+	UserLogin string `json:"user_login"`
+	UserName  string `json:"user_name"`
+	// Type is bits, subscription or other
+	Type  string `json:"type"`
+	Total int    `json:"total"`
+}
+
+type ChannelHypeTrainBegin struct {
+	ID string `json:"id" validate:"required"`
+	Broadcaster
+	Total            int            `json:"total"`
+	Progress         int            `json:"progress"`
+	Goal             int            `json:"goal"`
+	TopContributions []Contribution `json:"top_contributions"`
+	LastContribution Contribution   `json:"last_contribution"`
+	Level            int            `json:"level"`
+	StartedAt        time.Time      `json:"started_at"`
+	ExpiresAt        time.Time      `json:"expires_at"`
+}
+
+type ChannelHypeTrainProgress ChannelHypeTrainBegin
+
+type ChannelHypeTrainEnd struct {
+	ID string `json:"id" validate:"required"`
+	Broadcaster
+	Level            int            `json:"level"`
+	Total            int            `json:"total"`
+	TopContributions []Contribution `json:"top_contributions"`
+	StartedAt        time.Time      `json:"started_at"`
+	EndedAt          time.Time      `json:"ended_at"`
+	CooldownEndsAt   time.Time      `json:"cooldown_ends_at"`
+}
+
+type Amount struct {
+	Value         int    `json:"value"`
+	DecimalPlaces int    `json:"decimal_places"`
+	Currency      string `json:"currency"`
+}
+
+type Charity struct {
+	CharityName        string `json:"charity_name"`
+	CharityDescription string `json:"charity_description"`
+	CharityLogo        string `json:"charity_logo"`
+	CharityWebsite     string `json:"charity_website"`
+}
+
+type ChannelCharityCampaignDonate struct {
+	ID         string `json:"id" validate:"required"`
+	CampaignID string `json:"campaign_id"`
+	Broadcaster
+	User
+	Charity
+	Amount Amount `json:"amount"`
+}
+
+type ChannelCharityCampaignStart struct {
+	ID string `json:"id" validate:"required"`
+	Broadcaster
+	Charity
+	CurrentAmount Amount    `json:"current_amount"`
+	TargetAmount  Amount    `json:"target_amount"`
+	StartedAt     time.Time `json:"started_at"`
+}
+
+type ChannelCharityCampaignProgress struct {
+	ID string `json:"id" validate:"required"`
+	Broadcaster
+	Charity
+	CurrentAmount Amount `json:"current_amount"`
+	TargetAmount  Amount `json:"target_amount"`
+}
+
+type ChannelCharityCampaignStop struct {
+	ID string `json:"id" validate:"required"`
+	Broadcaster
+	Charity
+	CurrentAmount Amount    `json:"current_amount"`
+	TargetAmount  Amount    `json:"target_amount"`
+	StoppedAt     time.Time `json:"stopped_at"`
+}
+
+// ChannelGoal is used for begin, progress and end, IsAchieved and EndedAt are set on end only
+type ChannelGoal struct {
+	ID string `json:"id" validate:"required"`
+	Broadcaster
+	Type          string     `json:"type"`
+	Description   string     `json:"description"`
+	IsAchieved    bool       `json:"is_achieved"`
+	CurrentAmount int        `json:"current_amount"`
+	TargetAmount  int        `json:"target_amount"`
+	StartedAt     time.Time  `json:"started_at"`
+	EndedAt       *time.Time `json:"ended_at"`
+}
+
+type ChannelModerator struct {
+	Broadcaster
+	User
+}
+
+type ChannelShieldModeBegin struct {
+	Broadcaster
+	Moderator
+	StartedAt time.Time `json:"started_at"`
+}
+
+type ChannelShieldModeEnd struct {
+	Broadcaster
+	Moderator
+	EndedAt time.Time `json:"ended_at"`
+}
+
+type ChannelAdBreakBegin struct {
+	Broadcaster
+	DurationSeconds    int       `json:"duration_seconds"`
+	StartedAt          time.Time `json:"started_at"`
+	IsAutomatic        bool      `json:"is_automatic"`
+	RequesterUserID    string    `json:"requester_user_id"`
+	RequesterUserLogin string    `json:"requester_user_login"`
+	RequesterUserName  string    `json:"requester_user_name"`
+}
+
+type ChannelShoutoutCreate struct {
+	Broadcaster
+	Moderator
+	ToBroadcasterUserID    string    `json:"to_broadcaster_user_id" validate:"required"`
+	ToBroadcasterUserLogin string    `json:"to_broadcaster_user_login"`
+	ToBroadcasterUserName  string    `json:"to_broadcaster_user_name"`
+	ViewerCount            int       `json:"viewer_count"`
+	StartedAt              time.Time `json:"started_at"`
+	CooldownEndsAt         time.Time `json:"cooldown_ends_at"`
+	TargetCooldownEndsAt   time.Time `json:"target_cooldown_ends_at"`
+}
+
+type ChannelShoutoutReceive struct {
+	Broadcaster
+	FromBroadcasterUserID    string    `json:"from_broadcaster_user_id" validate:"required"`
+	FromBroadcasterUserLogin string    `json:"from_broadcaster_user_login"`
+	FromBroadcasterUserName  string    `json:"from_broadcaster_user_name"`
+	ViewerCount              int       `json:"viewer_count"`
+	StartedAt                time.Time `json:"started_at"`
+}
@@ -0,0 +1,152 @@
+// Package eventsub contains Twitch EventSub message and event models and
+// the catalog of subscriptions created for users
+package eventsub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var validate = validator.New()
+
+// Message is body of EventSub webhook request
+type Message struct {
+	Challenge    string          `json:"challenge,omitempty"`
+	Subscription Subscription    `json:"subscription"`
+	Event        json.RawMessage `json:"event,omitempty"`
+}
+
+type Subscription struct {
+	ID        string    `json:"id"`
+	Status    string    `json:"status"`
+	Type      string    `json:"type"`
+	Version   string    `json:"version"`
+	Cost      int64     `json:"cost"`
+	Condition Condition `json:"condition"`
+	Transport Transport `json:"transport"`
+	CreatedAt string    `json:"created_at"`
+}
+
+type Transport struct {
+	Method   string `json:"method"`
+	Callback string `json:"callback"`
+}
+
+type Condition struct {
+	BroadcasterUserID     *string `json:"broadcaster_user_id,omitempty"`
+	RewardID              *string `json:"reward_id,omitempty"`
+	FromBroadcasterUserID *string `json:"from_broadcaster_user_id,omitempty"`
+	ToBroadcasterUserID   *string `json:"to_broadcaster_user_id,omitempty"`
+	ModeratorUserID       *string `json:"moderator_user_id,omitempty"`
+	UserId                *string `json:"user_id,omitempty"`
+}
+
+func (c *Condition) Equal(other *Condition) bool {
+	return normalize(c.BroadcasterUserID) == normalize(other.BroadcasterUserID) &&
+		normalize(c.RewardID) == normalize(other.RewardID) &&
+		normalize(c.FromBroadcasterUserID) == normalize(other.FromBroadcasterUserID) &&
+		normalize(c.ToBroadcasterUserID) == normalize(other.ToBroadcasterUserID) &&
+		normalize(c.ModeratorUserID) == normalize(other.ModeratorUserID) &&
+		normalize(c.UserId) == normalize(other.UserId)
+}
+
+// Owner returns id of user the subscription was created for
+func (c *Condition) Owner() string {
+	owner := normalize(c.BroadcasterUserID)
+	if c.ToBroadcasterUserID != nil {
+		owner = *c.ToBroadcasterUserID
+	}
+	if c.FromBroadcasterUserID != nil {
+		owner = *c.FromBroadcasterUserID
+	}
+	// chat subscriptions have both, user_id is the account reading chat
+	if c.UserId != nil && owner == "" {
+		owner = *c.UserId
+	}
+	return owner
+}
+
+// Moderator returns id of account the subscription was created by when it is
+// not the owner, e.g. bot moderating the channel
+func (c *Condition) Moderator() string {
+	moderator := normalize(c.ModeratorUserID)
+	if moderator == "" {
+		moderator = normalize(c.UserId)
+	}
+	if moderator == c.Owner() {
+		return ""
+	}
+	return moderator
+}
+
+// normalize handles nil values by converting them to an empty string
+func normalize(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// Envelope is normalized notification, Event holds typed model from catalog
+// or *json.RawMessage for custom subscriptions and superseded versions
+type Envelope struct {
+	ID            string
+	Type          string
+	Version       string
+	BroadcasterID string
+	OccurredAt    time.Time
+	Subscription  Subscription
+	Event         any
+}
+
+var ErrUnknownType = errors.New("unknown subscription type")
+
+// Parse decodes and validates notification message, headers are Twitch-Eventsub-* headers of request
+func Parse(header http.Header, body []byte) (*Envelope, error) {
+	var message Message
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, err
+	}
+
+	definition, ok := Lookup(message.Subscription.Type, message.Subscription.Version)
+	if !ok {
+		definition, ok = LookupCustom(message.Subscription.Type, message.Subscription.Version)
+	}
+	if _, superseded := LookupSuperseded(message.Subscription.Type, message.Subscription.Version); !ok && superseded {
+		// old version has no model, its events are passed through until it is deleted
+		definition, ok = Definition{New: model[json.RawMessage]()}, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w %s v%s", ErrUnknownType, message.Subscription.Type, message.Subscription.Version)
+	}
+	event := definition.New()
+	if err := json.Unmarshal(message.Event, event); err != nil {
+		return nil, fmt.Errorf("decoding %s event: %w", message.Subscription.Type, err)
+	}
+	// custom and superseded events have no model to validate
+	if _, raw := event.(*json.RawMessage); !raw {
+		if err := validate.Struct(event); err != nil {
+			return nil, fmt.Errorf("invalid %s event: %w", message.Subscription.Type, err)
+		}
+	}
+
+	occurredAt, err := time.Parse(time.RFC3339Nano, header.Get("Twitch-Eventsub-Message-Timestamp"))
+	if err != nil {
+		occurredAt = time.Now()
+	}
+
+	return &Envelope{
+		ID:            header.Get("Twitch-Eventsub-Message-Id"),
+		Type:          message.Subscription.Type,
+		Version:       message.Subscription.Version,
+		BroadcasterID: message.Subscription.Condition.Owner(),
+		OccurredAt:    occurredAt,
+		Subscription:  message.Subscription,
+		Event:         event,
+	}, nil
+}
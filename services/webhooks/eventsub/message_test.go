@@ -0,0 +1,150 @@
+package eventsub
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		// wantErr is part of expected error, empty for valid message
+		wantErr     string
+		broadcaster string
+		// event is nil pointer of expected event model
+		event any
+	}{
+		{
+			name:        "catalog event",
+			body:        `{"subscription":{"type":"channel.cheer","version":"1","condition":{"broadcaster_user_id":"1"}},"event":{"broadcaster_user_id":"1","bits":100}}`,
+			broadcaster: "1",
+			event:       (*ChannelCheer)(nil),
+		},
+		{
+			name:        "owner of raid to broadcaster",
+			body:        `{"subscription":{"type":"channel.raid","version":"1","condition":{"to_broadcaster_user_id":"1"}},"event":{"from_broadcaster_user_id":"2","to_broadcaster_user_id":"1","viewers":5}}`,
+			broadcaster: "1",
+			event:       (*ChannelRaid)(nil),
+		},
+		{
+			name:    "validation failure",
+			body:    `{"subscription":{"type":"channel.cheer","version":"1","condition":{"broadcaster_user_id":"1"}},"event":{"broadcaster_user_id":"1","bits":0}}`,
+			wantErr: "invalid channel.cheer event",
+		},
+		{
+			name:    "missing required user",
+			body:    `{"subscription":{"type":"channel.follow","version":"2","condition":{"broadcaster_user_id":"1","moderator_user_id":"1"}},"event":{"broadcaster_user_id":"1"}}`,
+			wantErr: "invalid channel.follow event",
+		},
+		{
+			name:    "event of other shape",
+			body:    `{"subscription":{"type":"channel.cheer","version":"1","condition":{"broadcaster_user_id":"1"}},"event":[]}`,
+			wantErr: "decoding channel.cheer event",
+		},
+		{
+			name:        "superseded version is passed through",
+			body:        `{"subscription":{"type":"channel.follow","version":"1","condition":{"broadcaster_user_id":"1"}},"event":{"broadcaster_user_id":"1"}}`,
+			broadcaster: "1",
+			event:       (*json.RawMessage)(nil),
+		},
+		{
+			name:        "custom event is passed through",
+			body:        `{"subscription":{"type":"channel.vip.add","version":"1","condition":{"broadcaster_user_id":"1"}},"event":{"user_id":"2"}}`,
+			broadcaster: "1",
+			event:       (*json.RawMessage)(nil),
+		},
+		{
+			name:    "unknown type",
+			body:    `{"subscription":{"type":"channel.unknown","version":"1","condition":{"broadcaster_user_id":"1"}},"event":{}}`,
+			wantErr: ErrUnknownType.Error(),
+		},
+		{
+			name:    "unknown version",
+			body:    `{"subscription":{"type":"channel.cheer","version":"99","condition":{"broadcaster_user_id":"1"}},"event":{}}`,
+			wantErr: ErrUnknownType.Error(),
+		},
+	}
+
+	if err := Configure([]string{"channel.vip.add@1:channel:read:vips"}); err != nil {
+		t.Fatal(err)
+	}
+	defer Configure(nil)
+
+	occurredAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	header := http.Header{}
+	header.Set("Twitch-Eventsub-Message-Id", "message")
+	header.Set("Twitch-Eventsub-Message-Timestamp", occurredAt.Format(time.RFC3339Nano))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envelope, err := Parse(header, []byte(tt.body))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse() = %v, want error with %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if envelope.BroadcasterID != tt.broadcaster {
+				t.Errorf("BroadcasterID = %q, want %q", envelope.BroadcasterID, tt.broadcaster)
+			}
+			if reflect.TypeOf(envelope.Event) != reflect.TypeOf(tt.event) {
+				t.Errorf("Event is %T, want %T", envelope.Event, tt.event)
+			}
+			if envelope.ID != "message" || !envelope.OccurredAt.Equal(occurredAt) {
+				t.Errorf("ID = %q, OccurredAt = %s, want values of headers", envelope.ID, envelope.OccurredAt)
+			}
+		})
+	}
+}
+
+func TestOwner(t *testing.T) {
+	id := func(value string) *string { return &value }
+	tests := []struct {
+		name      string
+		condition Condition
+		want      string
+	}{
+		{name: "broadcaster", condition: Condition{BroadcasterUserID: id("1")}, want: "1"},
+		{name: "moderator is not owner", condition: Condition{BroadcasterUserID: id("1"), ModeratorUserID: id("2")}, want: "1"},
+		{name: "reward", condition: Condition{BroadcasterUserID: id("1"), RewardID: id("r")}, want: "1"},
+		{name: "raid to", condition: Condition{ToBroadcasterUserID: id("1")}, want: "1"},
+		{name: "raid from", condition: Condition{FromBroadcasterUserID: id("2")}, want: "2"},
+		{name: "chat reader is not owner", condition: Condition{BroadcasterUserID: id("1"), UserId: id("2")}, want: "1"},
+		{name: "user only", condition: Condition{UserId: id("2")}, want: "2"},
+		{name: "empty", condition: Condition{}, want: ""},
+	}
+	for _, tt := range tests {
+		if got := tt.condition.Owner(); got != tt.want {
+			t.Errorf("%s: Owner() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestModerator(t *testing.T) {
+	id := func(value string) *string { return &value }
+	tests := []struct {
+		name      string
+		condition Condition
+		want      string
+	}{
+		{name: "broadcaster only", condition: Condition{BroadcasterUserID: id("1")}, want: ""},
+		{name: "linked moderator", condition: Condition{BroadcasterUserID: id("1"), ModeratorUserID: id("2")}, want: "2"},
+		{name: "moderator equal to owner", condition: Condition{BroadcasterUserID: id("1"), ModeratorUserID: id("1")}, want: ""},
+		{name: "chat reader", condition: Condition{BroadcasterUserID: id("1"), UserId: id("2")}, want: "2"},
+		{name: "chat of broadcaster", condition: Condition{BroadcasterUserID: id("1"), UserId: id("1")}, want: ""},
+		{name: "user only", condition: Condition{UserId: id("2")}, want: ""},
+	}
+	for _, tt := range tests {
+		if got := tt.condition.Moderator(); got != tt.want {
+			t.Errorf("%s: Moderator() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Package forward posts queued events to HTTPS endpoints registered by users,
+// for bots which cannot keep long poll open. Requests are signed like EventSub
+// callbacks and events are delivered in order, at least once.
+package forward
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"services/shared/logging"
+	"services/webhooks/config"
+	"services/webhooks/database"
+	"services/webhooks/metrics"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// batch is number of events delivered to endpoint in one claim
+const batch = 20
+
+var cfg config.Forward
+var ctx = context.Background()
+var sem = semaphore.NewWeighted(int64(10))
+var client = &http.Client{}
+
+var errPrivateAddress = errors.New("endpoint resolves to private address")
+
+func Configure(forward config.Forward) {
+	cfg = forward
+
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivate {
+		// checked after resolving, so DNS cannot point endpoint to our network
+		dialer.Control = func(network string, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || blocked(ip) {
+				return errPrivateAddress
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// proxy would be the only address checked by dialer
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	client = &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: transport,
+		// redirected POST would lose body, endpoint has to be registered directly
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// cgnat is shared address space of carrier grade NAT, see RFC 6598
+var cgnat = &net.IPNet{IP: net.IPv4(100, 64, 0, 0).To4(), Mask: net.CIDRMask(10, 32)}
+
+// nat64 is well-known prefix of IPv6 addresses translated to IPv4, see RFC 6052
+var nat64 = &net.IPNet{IP: net.ParseIP("64:ff9b::"), Mask: net.CIDRMask(96, 128)}
+
+// blocked tells if endpoint address is not public, IPv4-mapped and NAT64
+// addresses are checked as the IPv4 address they lead to
+func blocked(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	} else if nat64.Contains(ip) {
+		ip = ip[12:16]
+	}
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsMulticast() ||
+		ip.IsUnspecified() || ip.Equal(net.IPv4bcast) || cgnat.Contains(ip)
+}
+
+// Loop delivers events of due endpoints every second
+func Loop() {
+	for {
+		time.Sleep(time.Second)
+
+		endpoints, err := database.DB.DueForwardEndpoints(ctx, time.Now())
+		if err != nil {
+			slog.Error("Error loading forward endpoints", logging.Error(err))
+			continue
+		}
+		for _, endpoint := range endpoints {
+			// other replicas skip endpoint until this one is done with it
+			now := time.Now()
+			claimed, err := database.DB.ClaimForwardEndpoint(ctx, endpoint.UserID, now, now.Add(batch*cfg.Timeout+time.Minute))
+			if err != nil {
+				slog.Error("Error claiming forward endpoint", logging.UserID(endpoint.UserID), logging.Error(err))
+				continue
+			}
+			if !claimed {
+				continue
+			}
+			sem.Acquire(ctx, 1)
+			go func(endpoint database.ForwardEndpoint) {
+				defer sem.Release(1)
+				deliver(endpoint)
+			}(endpoint)
+		}
+	}
+}
+
+// deliver posts queued events in order until one fails, endpoint is then
+// retried with backoff
+func deliver(endpoint database.ForwardEndpoint) {
+	endpoint.NextAttemptAt = time.Now()
+	events, err := database.DB.NextEvents(ctx, endpoint.UserID, batch)
+	if err != nil {
+		slog.Error("Error loading events", logging.UserID(endpoint.UserID), logging.Error(err))
+	}
+
+	for _, event := range events {
+		err := post(endpoint, event)
+		if err == nil {
+			if err := database.DB.DeleteEvent(ctx, event); err != nil {
+				slog.Error("Error deleting forwarded event", logging.UserID(endpoint.UserID), logging.Error(err))
+			}
+			metrics.ForwardDeliveries.WithLabelValues("delivered").Inc()
+			metrics.Delivered(event.Type, event.Timestamp)
+			endpoint.Attempts, endpoint.Failures, endpoint.LastError = 0, 0, ""
+			continue
+		}
+
+		endpoint.Attempts++
+		endpoint.Failures++
+		endpoint.LastError = err.Error()
+		metrics.ForwardDeliveries.WithLabelValues("failed").Inc()
+		slog.Warn("Error forwarding event", logging.UserID(endpoint.UserID), logging.EventType(event.Type), slog.Int("attempts", endpoint.Attempts), logging.Error(err))
+
+		if endpoint.Attempts >= cfg.Attempts {
+			deadLetter(endpoint, event, err)
+			endpoint.Attempts = 0
+		}
+		if endpoint.Failures >= cfg.DisableAfter {
+			// events stay queued, bot can poll them or enable endpoint again
+			slog.Warn("Disabling forward endpoint after repeated failures", logging.UserID(endpoint.UserID), slog.Int("failures", endpoint.Failures))
+			metrics.ForwardDeliveries.WithLabelValues("disabled").Inc()
+			endpoint.Enabled = false
+		}
+		endpoint.NextAttemptAt = time.Now().Add(backoff(endpoint.Failures))
+		break
+	}
+
+	if err := database.DB.UpdateForwardEndpoint(ctx, endpoint); err != nil {
+		slog.Error("Error updating forward endpoint", logging.UserID(endpoint.UserID), logging.Error(err))
+	}
+}
+
+// deadLetter moves event out of queue, so following events are delivered
+func deadLetter(endpoint database.ForwardEndpoint, event database.Event, cause error) {
+	slog.Warn("Event could not be forwarded, moving to dead letters", logging.UserID(endpoint.UserID), logging.EventType(event.Type), slog.String("message_id", event.MessageID))
+	err := database.DB.InsertDeadLetter(ctx, database.DeadLetter{
+		UserID:    endpoint.UserID,
+		MessageID: event.MessageID,
+		Type:      event.Type,
+		Data:      event.Data,
+		Error:     cause.Error(),
+	}, cfg.DeadLetters)
+	if err != nil {
+		slog.Error("Error storing dead letter", logging.UserID(endpoint.UserID), logging.Error(err))
+		return
+	}
+	if err := database.DB.DeleteEvent(ctx, event); err != nil {
+		slog.Error("Error deleting dead-lettered event", logging.UserID(endpoint.UserID), logging.Error(err))
+	}
+	metrics.ForwardDeliveries.WithLabelValues("dead_lettered").Inc()
+}
+
+// backoff returns wait after failures, doubled with each failure up to max
+func backoff(failures int) time.Duration {
+	wait := cfg.Backoff
+	for i := 1; i < failures && wait < cfg.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, cfg.MaxBackoff)
+}
+
+// Sign returns signature of message the same way as Twitch signs EventSub callbacks
+func Sign(secret string, messageId string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(messageId + timestamp))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func post(endpoint database.ForwardEndpoint, event database.Event) error {
+	messageId := event.MessageID
+	if messageId == "" {
+		messageId = strconv.FormatInt(event.ID, 10)
+	}
+	timestamp := time.Now().UTC().Format(time.RFC3339Nano)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, strings.NewReader(event.Data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Sogebot-Eventsub-Message-Id", messageId)
+	req.Header.Set("Sogebot-Eventsub-Message-Timestamp", timestamp)
+	req.Header.Set("Sogebot-Eventsub-Message-Type", "notification")
+	req.Header.Set("Sogebot-Eventsub-Message-Signature", Sign(endpoint.Secret, messageId, timestamp, []byte(event.Data)))
+	req.Header.Set("Sogebot-Eventsub-Subscription-Type", event.Type)
+	req.Header.Set("Sogebot-Eventsub-Subscription-Version", event.Version)
+	if event.CorrelationID != "" {
+		req.Header.Set("Sogebot-Correlation-Id", event.CorrelationID)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint answered with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package forward
+
+import (
+	"net"
+	"net/http"
+	"services/webhooks/config"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		messageId string
+		timestamp string
+		body      string
+		want      string
+	}{
+		{
+			// message id and timestamp of example in Twitch EventSub webhook docs
+			name:      "twitch example",
+			secret:    "s3cRe7",
+			messageId: "e76c6bd4-55c9-4987-8304-da1588d8988b",
+			timestamp: "2019-11-16T10:11:12.634234626Z",
+			body:      `{"subscription":{"id":"f1c2a387-161a-49f9-a165-0f21d7a4e1c4","type":"channel.follow","version":"2"},"event":{"user_id":"1337"}}`,
+			want:      "sha256=e83d9f576ca4accfaec64d2022041bd8b38ee27a250f0cc3304801286f0e9e1d",
+		},
+		{
+			// RFC 4231 test case 2, message is split into id, timestamp and body
+			name:      "rfc 4231",
+			secret:    "Jefe",
+			messageId: "what do ya ",
+			timestamp: "want ",
+			body:      "for nothing?",
+			want:      "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843",
+		},
+		{
+			name: "empty",
+			want: "sha256=b613679a0814d9ec772f95d778c35fc5ff1697c493715653c6c712144292c5ad",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign(tt.secret, tt.messageId, tt.timestamp, []byte(tt.body)); got != tt.want {
+				t.Errorf("Sign() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cfg = config.Forward{Backoff: 5 * time.Second, MaxBackoff: time.Minute}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 0, want: 5 * time.Second},
+		{failures: 1, want: 5 * time.Second},
+		{failures: 2, want: 10 * time.Second},
+		{failures: 3, want: 20 * time.Second},
+		{failures: 4, want: 40 * time.Second},
+		{failures: 5, want: time.Minute},
+		{failures: 1000, want: time.Minute},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.failures); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestBlocked(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"8.8.8.8", false},
+		{"2001:4860:4860::8888", false},
+		{"100.63.255.255", false},
+		{"100.128.0.0", false},
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"100.127.255.255", true},
+		{"224.0.0.1", true},
+		{"239.255.255.250", true},
+		{"255.255.255.255", true},
+		{"0.0.0.0", true},
+		{"::1", true},
+		{"::", true},
+		{"fc00::1", true},
+		{"fe80::1", true},
+		{"ff02::1", true},
+		{"::ffff:10.0.0.1", true},
+		{"::ffff:100.64.0.1", true},
+		{"::ffff:8.8.8.8", false},
+		{"64:ff9b::a9fe:a9fe", true},
+		{"64:ff9b::808:808", false},
+	}
+	for _, tt := range tests {
+		if got := blocked(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("blocked(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestConfigureIgnoresProxy(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://127.0.0.1:3128")
+	Configure(config.Forward{Timeout: time.Second})
+	if transport := client.Transport.(*http.Transport); transport.Proxy != nil {
+		t.Error("forward client uses proxy, dialer would check only its address")
+	}
+}
@@ -0,0 +1,177 @@
+// Package giftbomb correlates channel.subscription.gift with channel.subscribe
+// events it spawns and queues one combined event when all of them arrive
+package giftbomb
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"services/shared/logging"
+	"services/webhooks/config"
+	"services/webhooks/database"
+	"services/webhooks/eventsub"
+	"strconv"
+	"time"
+)
+
+var window = time.Minute
+
+func Configure(cfg config.GiftBomb) {
+	window = cfg.Window
+}
+
+// Tracks tells if events of type take part in gift bombs
+func Tracks(eventType string) bool {
+	return eventType == "channel.subscription.gift" || eventType == "channel.subscribe"
+}
+
+func correlationId(id int64) string {
+	return "giftbomb:" + strconv.FormatInt(id, 10)
+}
+
+// Correlate records gift or gifted subscription, returns correlation id of gift
+// bomb the event belongs to, empty if it does not belong to any
+func Correlate(ctx context.Context, envelope *eventsub.Envelope) (string, error) {
+	switch event := envelope.Event.(type) {
+	case *eventsub.ChannelSubscriptionGift:
+		bomb := database.GiftBomb{
+			BroadcasterID:   envelope.BroadcasterID,
+			GifterID:        event.UserID,
+			GifterLogin:     event.UserLogin,
+			GifterName:      event.UserName,
+			IsAnonymous:     event.IsAnonymous,
+			Tier:            event.Tier,
+			Total:           event.Total,
+			CumulativeTotal: event.CumulativeTotal,
+			Recipients:      []database.GiftRecipient{},
+			CreatedAt:       envelope.OccurredAt,
+		}
+		// gifted subscriptions often arrive before the gift itself
+		pending, err := database.DB.TakePendingGiftRecipients(ctx, envelope.BroadcasterID, event.Tier, envelope.OccurredAt.Add(-window), event.Total)
+		if err != nil {
+			return "", err
+		}
+		for _, recipient := range pending {
+			bomb.Recipients = append(bomb.Recipients, recipient.GiftRecipient)
+		}
+		bomb.Received = len(bomb.Recipients)
+
+		bomb.ID, err = database.DB.CreateGiftBomb(ctx, bomb)
+		if err != nil {
+			return "", err
+		}
+		for _, recipient := range pending {
+			if err := database.DB.SetCorrelation(ctx, recipient.MessageID, correlationId(bomb.ID)); err != nil {
+				slog.Error("Error correlating gift recipient", logging.UserID(bomb.BroadcasterID), logging.Error(err))
+			}
+		}
+		if bomb.Received >= bomb.Total {
+			if err := finish(ctx, bomb, envelope.OccurredAt); err != nil {
+				return "", err
+			}
+		}
+		return correlationId(bomb.ID), nil
+	case *eventsub.ChannelSubscribe:
+		if !event.IsGift {
+			return "", nil
+		}
+		recipient := database.GiftRecipient{
+			UserID:    event.UserID,
+			UserLogin: event.UserLogin,
+			UserName:  event.UserName,
+		}
+		bomb, found, err := database.DB.AttachGiftRecipient(ctx, envelope.BroadcasterID, event.Tier, envelope.OccurredAt.Add(-window), recipient)
+		if err != nil {
+			return "", err
+		}
+		if !found {
+			// gift may still come, it picks recipient up within window
+			pending := database.PendingGiftRecipient{GiftRecipient: recipient, MessageID: envelope.ID}
+			return "", database.DB.AddPendingGiftRecipient(ctx, envelope.BroadcasterID, event.Tier, pending, envelope.OccurredAt)
+		}
+		if bomb.Received >= bomb.Total {
+			if err := finish(ctx, bomb, envelope.OccurredAt); err != nil {
+				return "", err
+			}
+		}
+		return correlationId(bomb.ID), nil
+	}
+	return "", nil
+}
+
+// finish queues combined event at time at, only first caller finishing gift bomb queues it
+func finish(ctx context.Context, bomb database.GiftBomb, at time.Time) error {
+	finished, err := database.DB.FinishGiftBomb(ctx, bomb.ID)
+	if err != nil || !finished {
+		return err
+	}
+
+	event := eventsub.ChannelSubscriptionGiftBomb{
+		ID: correlationId(bomb.ID),
+		Broadcaster: eventsub.Broadcaster{
+			BroadcasterUserID: bomb.BroadcasterID,
+		},
+		UserID:          bomb.GifterID,
+		UserLogin:       bomb.GifterLogin,
+		UserName:        bomb.GifterName,
+		IsAnonymous:     bomb.IsAnonymous,
+		Tier:            bomb.Tier,
+		Total:           bomb.Total,
+		CumulativeTotal: bomb.CumulativeTotal,
+		Recipients:      []eventsub.GiftRecipient{},
+		Complete:        bomb.Received >= bomb.Total,
+		StartedAt:       bomb.CreatedAt,
+	}
+	for _, recipient := range bomb.Recipients {
+		event.Recipients = append(event.Recipients, eventsub.GiftRecipient(recipient))
+	}
+
+	// same shape as Twitch notification, so bots can parse it the same way
+	broadcasterId := bomb.BroadcasterID
+	data, err := json.Marshal(struct {
+		Subscription eventsub.Subscription                `json:"subscription"`
+		Event        eventsub.ChannelSubscriptionGiftBomb `json:"event"`
+	}{
+		Subscription: eventsub.Subscription{
+			Type:      eventsub.GiftBombType,
+			Version:   "1",
+			Condition: eventsub.Condition{BroadcasterUserID: &broadcasterId},
+		},
+		Event: event,
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Gift bomb finished", logging.UserID(bomb.BroadcasterID), slog.Int("total", bomb.Total), slog.Int("received", bomb.Received))
+	_, err = database.Queue(ctx, database.Event{
+		MessageID:     event.ID,
+		UserID:        bomb.BroadcasterID,
+		Type:          eventsub.GiftBombType,
+		Version:       "1",
+		BroadcasterID: bomb.BroadcasterID,
+		CorrelationID: event.ID,
+		Data:          string(data),
+		Timestamp:     at,
+	})
+	return err
+}
+
+// Loop finishes gift bombs which did not receive all gifted subscriptions in time
+func Loop() {
+	for {
+		time.Sleep(10 * time.Second)
+
+		ctx := context.Background()
+		bombs, err := database.DB.OpenGiftBombs(ctx, time.Now().Add(-window))
+		if err != nil {
+			slog.Error("Error loading open gift bombs", logging.Error(err))
+			continue
+		}
+		for _, bomb := range bombs {
+			if err := finish(ctx, bomb, time.Now()); err != nil {
+				slog.Error("Error finishing gift bomb", logging.UserID(bomb.BroadcasterID), logging.Error(err))
+			}
+		}
+	}
+}
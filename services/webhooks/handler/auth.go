@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"services/shared/logging"
+	"services/webhooks/metrics"
+	"services/webhooks/token"
+)
+
+// tokenInfo is response of Twitch token validation
+type tokenInfo struct {
+	ClientID  string   `json:"client_id"`
+	Login     string   `json:"login"`
+	Scopes    []string `json:"scopes"`
+	UserID    string   `json:"user_id"`
+	ExpiresIn int64    `json:"expires_in"`
+}
+
+var errInvalidToken = errors.New("invalid access token")
+
+// validateToken validates Twitch user access token sent in Authorization header,
+// errInvalidToken is returned when Twitch refuses it
+func validateToken(r *http.Request) (tokenInfo, error) {
+	var info tokenInfo
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		return info, errInvalidToken
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), "GET", cfg.Twitch.AuthURL+"/validate", nil)
+	if err != nil {
+		return info, err
+	}
+	req.Header.Set("Authorization", authorization)
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	metrics.Helix("oauth2.validate", resp, err)
+	if err != nil {
+		return info, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return info, errInvalidToken
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return info, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return info, errors.New("token validation failed: " + string(body))
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return info, err
+	}
+	if info.UserID == "" {
+		// app access tokens are valid, but do not belong to any user
+		return info, errInvalidToken
+	}
+	return info, nil
+}
+
+// authenticate validates token and writes error response when it fails
+func authenticate(w http.ResponseWriter, r *http.Request) (tokenInfo, bool) {
+	info, err := validateToken(r)
+	if errors.Is(err, errInvalidToken) {
+		http.Error(w, "Missing or invalid authorization header", http.StatusUnauthorized)
+		return info, false
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error validating token", logging.Error(err))
+		http.Error(w, "Failed to validate token", http.StatusBadGateway)
+		return info, false
+	}
+	return info, true
+}
+
+// storeToken keeps encrypted token of user for WebSocket sessions, tokens of
+// other applications cannot create subscriptions for our client id
+func storeToken(r *http.Request, info tokenInfo, refreshToken string) {
+	logger := logging.FromContext(r.Context())
+	if info.ClientID != cfg.Twitch.ClientID {
+		logger.Warn("Token was issued for another client, WebSocket session is not opened", logging.UserID(info.UserID), slog.String("client_id", info.ClientID))
+		return
+	}
+	if _, err := token.StoreUser(r.Context(), info.UserID, token.User{Access: accessToken(r), Refresh: refreshToken}); err != nil {
+		logger.Error("Error storing user token", logging.UserID(info.UserID), logging.Error(err))
+	}
+}
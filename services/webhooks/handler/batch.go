@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"services/shared/logging"
+	"services/webhooks/database"
+	"services/webhooks/metrics"
+	"strconv"
+	"time"
+)
+
+// maxBatchSize caps number of events sent in one response
+const maxBatchSize = 100
+
+type batchEvent struct {
+	ID            int64           `json:"id"`
+	MessageID     string          `json:"message_id,omitempty"`
+	Type          string          `json:"type"`
+	Version       string          `json:"version"`
+	BroadcasterID string          `json:"broadcaster_id"`
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	Timestamp     time.Time       `json:"timestamp"`
+	Data          json.RawMessage `json:"data"`
+}
+
+type batch struct {
+	Events []batchEvent `json:"events"`
+	// More is true when there are more events queued, bot should ask again right away
+	More       bool      `json:"more"`
+	ServerTime time.Time `json:"server_time"`
+	Dropped    int64     `json:"dropped"`
+}
+
+// batchSize reads max query parameter, 0 means single event response
+func batchSize(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("max")
+	if value == "" {
+		return 0, nil
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 1 {
+		return 0, errors.New("max must be positive number")
+	}
+	if size > maxBatchSize {
+		size = maxBatchSize
+	}
+	return size, nil
+}
+
+// writeBatch sends up to size events, returns true when response was written
+func writeBatch(w http.ResponseWriter, r *http.Request, userId string, size int, dropped int64) bool {
+	// load one more event, so we know if there is more
+	events, err := database.DB.NextEvents(r.Context(), userId, size+1)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error loading events", logging.UserID(userId), logging.Error(err))
+		http.Error(w, "Failed to load events", http.StatusInternalServerError)
+		return false
+	}
+
+	response := batch{
+		Events:     []batchEvent{},
+		More:       len(events) > size,
+		ServerTime: time.Now().UTC(),
+		Dropped:    dropped,
+	}
+	if response.More {
+		events = events[:size]
+	}
+	for _, event := range events {
+		response.Events = append(response.Events, batchEvent{
+			ID:            event.ID,
+			MessageID:     event.MessageID,
+			Type:          event.Type,
+			Version:       event.Version,
+			BroadcasterID: event.BroadcasterID,
+			CorrelationID: event.CorrelationID,
+			Timestamp:     event.Timestamp,
+			Data:          json.RawMessage(event.Data),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logging.FromContext(r.Context()).Error("Error writing events", logging.UserID(userId), logging.Error(err))
+		return false
+	}
+
+	for _, event := range events {
+		metrics.Delivered(event.Type, event.Timestamp)
+		// bot may already be gone, but events were sent
+		if err := database.DB.DeleteEvent(context.Background(), event); err != nil {
+			logging.FromContext(r.Context()).Error("Error deleting delivered event", logging.UserID(userId), logging.Error(err))
+		}
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"services/shared/logging"
+	"services/webhooks/chat"
+	"services/webhooks/database"
+	"services/webhooks/metrics"
+	"strconv"
+	"time"
+)
+
+// chatTimeout is how long bot waits for chat events, chat is usually busy
+const chatTimeout = 30 * time.Second
+
+type chatResponse struct {
+	Events []chat.Event `json:"events"`
+	// Cursor is sent back by bot to get events after this response
+	Cursor int64 `json:"cursor"`
+	// Missed is true when some events after requested cursor were dropped
+	Missed     bool      `json:"missed"`
+	ServerTime time.Time `json:"server_time"`
+}
+
+// getUserChat long polls chat events of user newer than cursor query parameter,
+// without cursor only events published from now on are returned
+func getUserChat(w http.ResponseWriter, r *http.Request) {
+	userId := requestUserId(r)
+	if len(userId) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	size, err := batchSize(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if size == 0 {
+		size = maxBatchSize
+	}
+
+	cursor := chat.Cursor()
+	if value := r.URL.Query().Get("cursor"); value != "" {
+		cursor, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			http.Error(w, "cursor must be number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := database.DB.TouchUser(r.Context(), userId, time.Now()); err != nil {
+		logging.FromContext(r.Context()).Warn("Error updating last seen", logging.UserID(userId), logging.Error(err))
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), chatTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-draining:
+			// shutting down, let bot reconnect to another instance
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	events, missed, _ := chat.Wait(ctx, userId, cursor, size)
+	if r.Context().Err() != nil {
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+	if len(events) == 0 && !missed {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	response := chatResponse{
+		Events:     events,
+		Cursor:     cursor,
+		Missed:     missed,
+		ServerTime: time.Now().UTC(),
+	}
+	if len(events) > 0 {
+		response.Cursor = events[len(events)-1].Cursor
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logging.FromContext(r.Context()).Error("Error writing chat events", logging.UserID(userId), logging.Error(err))
+		return
+	}
+	for _, event := range events {
+		metrics.Delivered(event.Type, event.Timestamp)
+	}
+}
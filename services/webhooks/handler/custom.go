@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"services/shared/logging"
+	"services/webhooks/database"
+	"services/webhooks/eventsub"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// customConditionKeys are condition fields custom subscriptions can use, the
+// ones we can compare with subscriptions listed at Twitch
+var customConditionKeys = []string{
+	"broadcaster_user_id",
+	"from_broadcaster_user_id",
+	"to_broadcaster_user_id",
+	"moderator_user_id",
+	"user_id",
+	"reward_id",
+}
+
+type customRequest struct {
+	Type      string            `json:"type"`
+	Version   string            `json:"version"`
+	Condition map[string]string `json:"condition"`
+}
+
+func getUserSubscriptions(w http.ResponseWriter, r *http.Request) {
+	info, ok := authenticate(w, r)
+	if !ok {
+		return
+	}
+	list, err := database.DB.ListCustomSubscriptions(r.Context(), info.UserID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error loading custom subscriptions", logging.UserID(info.UserID), logging.Error(err))
+		http.Error(w, "Failed to load subscriptions", http.StatusInternalServerError)
+		return
+	}
+	writeCustom(w, r, http.StatusOK, list)
+}
+
+// postUserSubscriptions stores subscription requested by bot, it is created
+// at Twitch by reconciler
+func postUserSubscriptions(w http.ResponseWriter, r *http.Request) {
+	info, ok := authenticate(w, r)
+	if !ok {
+		return
+	}
+	logger := logging.FromContext(r.Context())
+
+	var request customRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Failed to parse JSON payload", http.StatusBadRequest)
+		return
+	}
+	definition, allowed := eventsub.LookupCustom(request.Type, request.Version)
+	if !allowed {
+		http.Error(w, "Subscription type and version are not allowed", http.StatusForbidden)
+		return
+	}
+	for key, value := range request.Condition {
+		if !slices.Contains(customConditionKeys, key) || value == "" {
+			http.Error(w, "Unsupported condition "+key+", allowed are "+strings.Join(customConditionKeys, ", "), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// keys of marshaled map are sorted, so the same condition is stored the same way
+	data, err := json.Marshal(request.Condition)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var condition eventsub.Condition
+	if err := json.Unmarshal(data, &condition); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	broadcasterId := condition.Owner()
+	if broadcasterId == "" {
+		http.Error(w, "Condition must contain broadcaster", http.StatusBadRequest)
+		return
+	}
+	if moderatorId := condition.Moderator(); moderatorId != "" && moderatorId != info.UserID {
+		http.Error(w, "Condition can contain only your user id as moderator or user", http.StatusForbidden)
+		return
+	}
+	if broadcasterId != info.UserID {
+		// moderator creates subscription with own authorization
+		if condition.Moderator() != info.UserID {
+			http.Error(w, "Condition must contain your user id as moderator", http.StatusForbidden)
+			return
+		}
+		authorized, err := authorizedFor(r, info, broadcasterId)
+		if err != nil {
+			logger.Error("Error verifying moderated channels", logging.UserID(info.UserID), logging.Error(err))
+			http.Error(w, "Failed to verify moderated channels", http.StatusBadGateway)
+			return
+		}
+		if !authorized {
+			http.Error(w, "Token owner is not moderator of broadcaster", http.StatusForbidden)
+			return
+		}
+	}
+	if !definition.Authorized(strings.Join(info.Scopes, " ")) {
+		http.Error(w, "Missing scopes "+definition.Scope, http.StatusForbidden)
+		return
+	}
+
+	// reconciler uses stored scopes of requester
+	if _, found, err := database.DB.GetUser(r.Context(), info.UserID); err != nil || !found {
+		if err != nil {
+			logger.Error("Error loading user", logging.UserID(info.UserID), logging.Error(err))
+		}
+		http.Error(w, "User is not registered, use POST /user first", http.StatusForbidden)
+		return
+	}
+	list, err := database.DB.ListCustomSubscriptions(r.Context(), info.UserID)
+	if err != nil {
+		logger.Error("Error loading custom subscriptions", logging.UserID(info.UserID), logging.Error(err))
+		http.Error(w, "Failed to load subscriptions", http.StatusInternalServerError)
+		return
+	}
+	requested := 0
+	for _, subscription := range list {
+		if slices.Contains(subscription.Requesters, info.UserID) && !subscription.Deleted {
+			requested++
+		}
+	}
+	if requested >= cfg.Custom.MaxPerUser {
+		http.Error(w, "At most "+strconv.Itoa(cfg.Custom.MaxPerUser)+" custom subscriptions can be requested", http.StatusTooManyRequests)
+		return
+	}
+
+	subscription, created, err := database.DB.CreateCustomSubscription(r.Context(), database.CustomSubscription{
+		RequesterID:   info.UserID,
+		BroadcasterID: broadcasterId,
+		Type:          request.Type,
+		Version:       request.Version,
+		Condition:     string(data),
+	})
+	if err != nil {
+		logger.Error("Error storing custom subscription", logging.UserID(info.UserID), logging.Error(err))
+		http.Error(w, "Failed to store subscription", http.StatusInternalServerError)
+		return
+	}
+	if subscription.Deleted {
+		http.Error(w, "Subscription is being deleted, try again later", http.StatusConflict)
+		return
+	}
+	if !created {
+		writeCustom(w, r, http.StatusOK, subscription)
+		return
+	}
+
+	logger.Info("Custom subscription requested", logging.UserID(broadcasterId), logging.EventType(request.Type), slog.String("requester_id", info.UserID))
+	if err := database.DB.MarkUpdated(r.Context(), broadcasterId); err != nil {
+		logger.Warn("Error marking user updated", logging.UserID(broadcasterId), logging.Error(err))
+	}
+	writeCustom(w, r, http.StatusCreated, subscription)
+}
+
+// deleteUserSubscriptions removes requester of subscription with id query
+// parameter, it is flagged deleted when no requester remains or when
+// broadcaster deletes it
+func deleteUserSubscriptions(w http.ResponseWriter, r *http.Request) {
+	info, ok := authenticate(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "id must be number", http.StatusBadRequest)
+		return
+	}
+	subscription, found, err := database.DB.GetCustomSubscription(r.Context(), id)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error loading custom subscription", logging.UserID(info.UserID), logging.Error(err))
+		http.Error(w, "Failed to load subscription", http.StatusInternalServerError)
+		return
+	}
+	requested := slices.Contains(subscription.Requesters, info.UserID)
+	if !found || (!requested && subscription.BroadcasterID != info.UserID) {
+		http.Error(w, "Subscription not found", http.StatusNotFound)
+		return
+	}
+
+	deleted := true
+	if subscription.BroadcasterID == info.UserID {
+		err = database.DB.MarkCustomSubscriptionDeleted(r.Context(), id)
+	} else {
+		deleted, err = database.DB.RemoveCustomRequester(r.Context(), id, info.UserID)
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error deleting custom subscription", logging.UserID(info.UserID), logging.Error(err))
+		http.Error(w, "Failed to delete subscription", http.StatusInternalServerError)
+		return
+	}
+	if !deleted && subscription.RequesterID != info.UserID {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	// deleted subscription or the one taken over by another requester is changed by reconciler
+	if err := database.DB.MarkUpdated(r.Context(), subscription.BroadcasterID); err != nil {
+		logging.FromContext(r.Context()).Warn("Error marking user updated", logging.UserID(subscription.BroadcasterID), logging.Error(err))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeCustom(w http.ResponseWriter, r *http.Request, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		logging.FromContext(r.Context()).Error("Error writing custom subscriptions", logging.Error(err))
+	}
+}
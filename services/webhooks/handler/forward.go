@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"services/shared/logging"
+	"services/webhooks/database"
+)
+
+type forwardRegistration struct {
+	URL string `json:"url"`
+	// Secret signs forwarded events, see Sogebot-Eventsub-Message-Signature
+	Secret string `json:"secret"`
+}
+
+// forwardUser returns registered user owning token
+func forwardUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	info, ok := authenticate(w, r)
+	if !ok {
+		return "", false
+	}
+	if _, found, err := database.DB.GetUser(r.Context(), info.UserID); err != nil {
+		logging.FromContext(r.Context()).Error("Error loading user", logging.UserID(info.UserID), logging.Error(err))
+		http.Error(w, "Failed to load user", http.StatusInternalServerError)
+		return "", false
+	} else if !found {
+		http.Error(w, "User is not registered", http.StatusNotFound)
+		return "", false
+	}
+	return info.UserID, true
+}
+
+func getUserForward(w http.ResponseWriter, r *http.Request) {
+	userId, ok := forwardUser(w, r)
+	if !ok {
+		return
+	}
+	endpoint, found, err := database.DB.GetForwardEndpoint(r.Context(), userId)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error loading forward endpoint", logging.UserID(userId), logging.Error(err))
+		http.Error(w, "Failed to load forward endpoint", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Forward endpoint is not registered", http.StatusNotFound)
+		return
+	}
+	writeForward(w, r, userId, endpoint)
+}
+
+// putUserForward registers endpoint queued events are posted to, registering
+// it again enables disabled endpoint
+func putUserForward(w http.ResponseWriter, r *http.Request) {
+	userId, ok := forwardUser(w, r)
+	if !ok {
+		return
+	}
+
+	var registration forwardRegistration
+	if err := json.NewDecoder(r.Body).Decode(&registration); err != nil {
+		http.Error(w, "Failed to parse JSON payload", http.StatusBadRequest)
+		return
+	}
+	endpointUrl, err := url.Parse(registration.URL)
+	if err != nil || endpointUrl.Scheme != "https" || endpointUrl.Host == "" || len(registration.URL) > 2048 {
+		http.Error(w, "url must be HTTPS URL", http.StatusBadRequest)
+		return
+	}
+	// the same limits as Twitch has for EventSub secret
+	if len(registration.Secret) < 10 || len(registration.Secret) > 100 {
+		http.Error(w, "secret must have 10 to 100 characters", http.StatusBadRequest)
+		return
+	}
+
+	if err := database.DB.SetForwardEndpoint(r.Context(), userId, registration.URL, registration.Secret); err != nil {
+		logging.FromContext(r.Context()).Error("Error storing forward endpoint", logging.UserID(userId), logging.Error(err))
+		http.Error(w, "Failed to store forward endpoint", http.StatusInternalServerError)
+		return
+	}
+	logging.FromContext(r.Context()).Info("Forward endpoint registered", logging.UserID(userId), slog.String("host", endpointUrl.Host))
+
+	endpoint, _, err := database.DB.GetForwardEndpoint(r.Context(), userId)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error loading forward endpoint", logging.UserID(userId), logging.Error(err))
+		http.Error(w, "Failed to load forward endpoint", http.StatusInternalServerError)
+		return
+	}
+	writeForward(w, r, userId, endpoint)
+}
+
+func deleteUserForward(w http.ResponseWriter, r *http.Request) {
+	userId, ok := forwardUser(w, r)
+	if !ok {
+		return
+	}
+	if err := database.DB.DeleteForwardEndpoint(r.Context(), userId); err != nil {
+		logging.FromContext(r.Context()).Error("Error deleting forward endpoint", logging.UserID(userId), logging.Error(err))
+		http.Error(w, "Failed to delete forward endpoint", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getUserDeadLetters(w http.ResponseWriter, r *http.Request) {
+	userId, ok := forwardUser(w, r)
+	if !ok {
+		return
+	}
+	letters, err := database.DB.ListDeadLetters(r.Context(), userId, cfg.Forward.DeadLetters)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error loading dead letters", logging.UserID(userId), logging.Error(err))
+		http.Error(w, "Failed to load dead letters", http.StatusInternalServerError)
+		return
+	}
+	writeForward(w, r, userId, letters)
+}
+
+func deleteUserDeadLetters(w http.ResponseWriter, r *http.Request) {
+	userId, ok := forwardUser(w, r)
+	if !ok {
+		return
+	}
+	purged, err := database.DB.PurgeDeadLetters(r.Context(), userId)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error deleting dead letters", logging.UserID(userId), logging.Error(err))
+		http.Error(w, "Failed to delete dead letters", http.StatusInternalServerError)
+		return
+	}
+	writeForward(w, r, userId, map[string]int64{"purged": purged})
+}
+
+func writeForward(w http.ResponseWriter, r *http.Request, userId string, response any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logging.FromContext(r.Context()).Error("Error writing forward response", logging.UserID(userId), logging.Error(err))
+	}
+}
@@ -4,17 +4,21 @@ import (
 	"context"
 	"crypto/hmac"
 	"crypto/sha256"
-	"database/sql"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"services/webhooks/commons"
+	"services/shared/logging"
+	"services/webhooks/config"
 	"services/webhooks/database"
 	"services/webhooks/debug"
+	"services/webhooks/eventsub"
+	"services/webhooks/metrics"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,51 +28,24 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
 	"golang.ngrok.com/ngrok"
-	"golang.ngrok.com/ngrok/config"
+	ngrokconfig "golang.ngrok.com/ngrok/config"
 )
 
 var EVENTSUB_URL = "https://eventsub.sogebot.xyz"
 var EVENTSUB_URL_PROD = EVENTSUB_URL
 
-type WebhookCallbackVerification struct {
-	Challenge    string       `json:"challenge"`
-	Subscription Subscription `json:"subscription"`
-}
-
-type Subscription struct {
-	ID        string    `json:"id"`
-	Status    string    `json:"status"`
-	Type      string    `json:"type"`
-	Version   string    `json:"version"`
-	Cost      int64     `json:"cost"`
-	Condition Condition `json:"condition"`
-	Transport Transport `json:"transport"`
-	CreatedAt string    `json:"created_at"`
-}
-
-type Condition struct {
-	BroadcasterUserID     string `json:"broadcaster_user_id"`
-	ToBroadcasterUserID   string `json:"to_broadcaster_user_id"`
-	FromBroadcasterUserID string `json:"from_broadcaster_user_id"`
-	ModeratorUserID       string `json:"moderator_user_id"`
-	UserId                string `json:"user_id"`
-}
-
-type Transport struct {
-	Method   string `json:"method"`
-	Callback string `json:"callback"`
-}
-
 var c = cors.New(cors.Options{
 	AllowedOrigins:   []string{"*"},
 	AllowCredentials: true,
 	AllowedHeaders:   []string{"Authorization", "content-type"},
+	AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodHead},
 })
 
+// ngrokTunnel serves endpoint through ngrok, callbacks are sent to its URL
 func ngrokTunnel(done chan<- bool) error {
 	tun, err := ngrok.Listen(context.Background(),
-		config.HTTPEndpoint(),
-		ngrok.WithAuthtokenFromEnv(),
+		ngrokconfig.HTTPEndpoint(),
+		ngrok.WithAuthtoken(cfg.Ngrok.Authtoken),
 	)
 	if err != nil {
 		return err
@@ -76,10 +53,10 @@ func ngrokTunnel(done chan<- bool) error {
 
 	EVENTSUB_URL = tun.URL()
 	corshandler := c.Handler(http.HandlerFunc(handler))
-	loggerHandler := commons.Logger(corshandler)
+	server.Handler = logging.Middleware(corshandler)
 
 	done <- true
-	return http.Serve(tun, loggerHandler)
+	return server.Serve(tun)
 }
 
 func secureCompare(a, b []byte) bool {
@@ -98,7 +75,7 @@ func verifySignature(w http.ResponseWriter, r *http.Request, body []byte) bool {
 	timestamp := r.Header.Get("Twitch-Eventsub-Message-Timestamp")
 	messageID := r.Header.Get("Twitch-Eventsub-Message-Id")
 	signature := r.Header.Get("Twitch-Eventsub-Message-Signature")
-	secret := os.Getenv("TWITCH_EVENTSUB_SECRET")
+	secret := cfg.Twitch.Secret
 
 	// Recreate the message by concatenating the required values
 	message := messageID + timestamp + string(body)
@@ -110,125 +87,115 @@ func verifySignature(w http.ResponseWriter, r *http.Request, body []byte) bool {
 
 	// Compare the expected signature with the received signature securely
 	if !secureCompare([]byte(signature), []byte(expectedSignature)) {
-		commons.Debug("Signature verification FAILED!")
+		slog.Debug("Signature verification FAILED!")
+		metrics.SignatureFailures.Inc()
 		http.Error(w, "Signature verification failed", http.StatusBadRequest)
 		return false
 	}
 
-	commons.Debug("Signature verified!")
+	slog.Debug("Signature verified!")
 	return true
 }
 
 func postUser(w http.ResponseWriter, r *http.Request) {
-	code := r.Header.Get("Authorization")
-
-	if len(code) == 0 {
+	if len(r.Header.Get("Authorization")) == 0 {
 		w.WriteHeader(http.StatusUnauthorized)
 		w.Header().Add("Content-Type", "text/plain")
 		fmt.Fprint(w, "Missing authorization header")
 		return
 	}
 
-	url := "https://id.twitch.tv/oauth2/validate"
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		fmt.Println("Error creating request:", err)
-		return
-	}
-	req.Header.Set("Authorization", code)
-	// Send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	reg, err := readRegistration(r)
 	if err != nil {
-		fmt.Println("Error sending request:", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer resp.Body.Close()
 
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Println("Error reading response:", err)
+	response, ok := authenticate(w, r)
+	if !ok {
 		return
 	}
 
-	// Unmarshal the response body into a Response struct
-	var response struct {
-		ClientID  string   `json:"client_id"`
-		Login     string   `json:"login"`
-		Scopes    []string `json:"scopes"`
-		UserID    string   `json:"user_id"`
-		ExpiresIn int64    `json:"expires_in"`
-	}
-
-	err = json.Unmarshal(body, &response)
-	if err != nil {
-		fmt.Println("Error unmarshaling response:", err)
+	if err := verifyModerates(r, response, &reg); errors.Is(err, errModerationScope) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	} else if err != nil {
+		logging.FromContext(r.Context()).Error("Error verifying moderated channels", logging.UserID(response.UserID), logging.Error(err))
+		http.Error(w, "Failed to verify moderated channels", http.StatusBadGateway)
 		return
 	}
 
 	scopes := strings.Join(response.Scopes, " ")
 	userId := response.UserID
 
-	var db_scopes string
-	user_exists := true
-	row := database.DB.QueryRow("SELECT scopes FROM eventsub_users WHERE \"userId\"=$1", userId)
-	err = row.Scan(&db_scopes)
+	user, user_exists, err := database.DB.GetUser(r.Context(), userId)
 	if err != nil {
-		user_exists = false
-		if err != sql.ErrNoRows {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Header().Add("Content-Type", "text/plain")
-			fmt.Fprint(w, err)
-			commons.Log(err.Error())
-			return
-		}
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Header().Add("Content-Type", "text/plain")
+		fmt.Fprint(w, err)
+		logging.FromContext(r.Context()).Error("Error loading user", logging.UserID(userId), logging.Error(err))
+		return
 	}
 
 	if !user_exists {
-		commons.Debug("User " + userId + " not found. Creating.")
-		database.DB.Exec("INSERT INTO eventsub_users (\"userId\", scopes) VALUES ($1, $2)",
-			userId, scopes,
-		)
-		returnSuccess(w)
-		return
+		logging.FromContext(r.Context()).Debug("User not found. Creating.", logging.UserID(userId))
+		if err := database.DB.CreateUser(r.Context(), userId, scopes); err != nil {
+			logging.FromContext(r.Context()).Error("Error creating user", logging.UserID(userId), logging.Error(err))
+		}
+	} else if user.Scopes == scopes {
+		logging.FromContext(r.Context()).Debug("User have no new scopes. Skipping", logging.UserID(userId))
+	} else {
+		logging.FromContext(r.Context()).Debug("User have new scopes. Updating", logging.UserID(userId), slog.String("scopes", scopes))
+		if err := database.DB.UpdateUserScopes(r.Context(), userId, scopes); err != nil {
+			logging.FromContext(r.Context()).Error("Error updating user", logging.UserID(userId), logging.Error(err))
+		}
 	}
 
-	if db_scopes == scopes {
-		commons.Debug("User " + userId + " have no new scopes. Skipping")
-	} else {
-		commons.Debug("User " + userId + " have new scopes " + scopes + ". Updating")
-		database.DB.Exec("UPDATE eventsub_users SET scopes=$1, updated=$2 WHERE \"userId\"=$3",
-			scopes, true, userId,
-		)
+	if cfg.UsesWebSocket() {
+		storeToken(r, response, reg.RefreshToken)
+	}
+
+	if err := linkBot(r.Context(), userId, reg, !user_exists || user.Scopes != scopes); err != nil {
+		logging.FromContext(r.Context()).Error("Error linking bot", logging.UserID(userId), logging.Error(err))
+		http.Error(w, "Failed to link bot", http.StatusInternalServerError)
+		return
 	}
 	returnSuccess(w)
 }
 
+// verified lets reconciler delete subscriptions superseded by verified one
+func verified(ctx context.Context, broadcasterId string, subscription eventsub.Subscription) {
+	migrations, err := database.DB.VerifyVersionMigrations(ctx, broadcasterId, subscription.Type, subscription.Version)
+	if err == nil && migrations > 0 {
+		err = database.DB.MarkUpdated(ctx, broadcasterId)
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("Error verifying subscription migration", logging.UserID(broadcasterId), logging.EventType(subscription.Type), logging.Error(err))
+	}
+}
+
 func returnSuccess(w http.ResponseWriter) {
 	w.WriteHeader(http.StatusOK)
 	w.Header().Add("Content-Type", "text/plain")
 	fmt.Fprint(w, "Success")
 }
 
-func getUser(w http.ResponseWriter, r *http.Request) {
+// requestUserId returns id of user bot is asking for, users outside of
+// development allowlist are treated as missing
+func requestUserId(r *http.Request) string {
 	userId := r.Header.Get("sogebot-event-userid")
-
-	if debug.IsDEV() {
-		userId = "96965261"
+	if !debug.Allowed(userId) {
+		logging.FromContext(r.Context()).Debug("User is not allowed in development", logging.UserID(userId))
+		return ""
 	}
+	return userId
+}
 
-	t := time.Now()
-	log.Printf("[%s - %s] #%s \"%s %s %s\" %s %s \n",
-		r.RemoteAddr,
-		t.Format("02/Jan/2006:15:04:05 -0700"),
-		userId,
-		r.Method,
-		r.URL.Path,
-		r.Proto,
-		"???",
-		r.UserAgent(),
-	)
+func getUser(w http.ResponseWriter, r *http.Request) {
+	userId := requestUserId(r)
+
+	logging.AddAttrs(r.Context(), logging.UserID(userId))
+	logging.FromContext(r.Context()).Debug("Bot is waiting for events", logging.UserID(userId))
 
 	// Set the response headers
 	w.Header().Set("Content-Type", "text/plain")
@@ -240,11 +207,25 @@ func getUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// older bots expect single event object, batch envelope is sent only when asked for
+	size, err := batchSize(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := database.DB.TouchUser(r.Context(), userId, time.Now()); err != nil {
+		logging.FromContext(r.Context()).Warn("Error updating last seen", logging.UserID(userId), logging.Error(err))
+	}
+
 	timeout := time.Now().Add((time.Minute * 2) - 15*time.Second)
 
 	go Listen(userId)
 	defer Done(userId)
 
+	metrics.LongPollWaiters.Inc()
+	defer metrics.LongPollWaiters.Dec()
+
 	for {
 		if timeout.Before((time.Now())) {
 			// Set the response status code and write the initial response
@@ -256,17 +237,40 @@ func getUser(w http.ResponseWriter, r *http.Request) {
 		case <-r.Context().Done():
 			w.WriteHeader(http.StatusGone)
 			return
+		case <-draining:
+			// shutting down, let bot reconnect to another instance
+			w.WriteHeader(http.StatusNoContent)
+			return
 		default:
 			val, ok := Get(userId)
-			if ok && val.data != "" {
+			if ok && val.Data != "" {
+				// let bot know it missed some events because queue was full
+				dropped, err := database.DB.Dropped(r.Context(), userId)
+				if err != nil {
+					logging.FromContext(r.Context()).Warn("Error loading dropped events", logging.UserID(userId), logging.Error(err))
+				}
+				w.Header().Set("Sogebot-Dropped-Events", strconv.FormatInt(dropped, 10))
+				if val.CorrelationID != "" {
+					w.Header().Set("Sogebot-Correlation-Id", val.CorrelationID)
+				}
+
+				if size > 0 {
+					if writeBatch(w, r, userId, size, dropped) {
+						ackDropped(r, userId, dropped)
+					}
+					return
+				}
+
 				// Send the response
 				w.Header().Set("Content-Type", "application/json")
 				w.WriteHeader(http.StatusOK)
-				w.Write([]byte(val.data))
+				if _, err := w.Write([]byte(val.Data)); err == nil {
+					ackDropped(r, userId, dropped)
+				}
+				metrics.Delivered(val.Type, val.Timestamp)
 
 				// Delete the used data from the database
-				deleteQuery := `DELETE FROM "eventsub_events" WHERE "userid"=$1 AND "timestamp"=$2`
-				database.DB.Exec(deleteQuery, userId, val.timestamp)
+				database.DB.DeleteEvent(context.Background(), val)
 				return
 			} else {
 				// No event found for the user
@@ -276,7 +280,85 @@ func getUser(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// ackDropped forgets dropped events once bot received response telling about them
+func ackDropped(r *http.Request, userId string, dropped int64) {
+	if err := database.DB.AckDropped(context.Background(), userId, dropped); err != nil {
+		logging.FromContext(r.Context()).Warn("Error acknowledging dropped events", logging.UserID(userId), logging.Error(err))
+	}
+}
+
 func handler(w http.ResponseWriter, r *http.Request) {
+	if userId := r.Header.Get("sogebot-event-userid"); userId != "" {
+		logging.AddAttrs(r.Context(), logging.UserID(userId))
+	}
+	if r.Method == http.MethodGet && r.URL.Path == "/healthz" {
+		getHealthz(w, r)
+		return
+	}
+	if r.Method == http.MethodGet && r.URL.Path == "/readyz" {
+		getReadyz(w, r)
+		return
+	}
+	if Admin != nil && strings.HasPrefix(r.URL.Path, "/admin/") {
+		Admin.ServeHTTP(w, r)
+		return
+	}
+	if r.Method == http.MethodGet && r.URL.Path == "/user/sessions" {
+		getUserSessions(w, r)
+		return
+	}
+	if r.URL.Path == "/user/subscriptions" {
+		switch r.Method {
+		case http.MethodGet:
+			getUserSubscriptions(w, r)
+			return
+		case http.MethodPost:
+			postUserSubscriptions(w, r)
+			return
+		case http.MethodDelete:
+			deleteUserSubscriptions(w, r)
+			return
+		}
+	}
+	if r.Method == http.MethodGet && r.URL.Path == "/user/rewards" {
+		getUserRewards(w, r)
+		return
+	}
+	if r.Method == http.MethodPut && r.URL.Path == "/user/rewards" {
+		putUserRewards(w, r)
+		return
+	}
+	if r.URL.Path == "/user/forward" {
+		switch r.Method {
+		case http.MethodGet:
+			getUserForward(w, r)
+			return
+		case http.MethodPut:
+			putUserForward(w, r)
+			return
+		case http.MethodDelete:
+			deleteUserForward(w, r)
+			return
+		}
+	}
+	if r.URL.Path == "/user/forward/dead-letters" {
+		switch r.Method {
+		case http.MethodGet:
+			getUserDeadLetters(w, r)
+			return
+		case http.MethodDelete:
+			deleteUserDeadLetters(w, r)
+			return
+		}
+	}
+	if r.Method == http.MethodGet && r.URL.Path == "/user/chat" {
+		getUserChat(w, r)
+		return
+	}
+	if r.Method == http.MethodGet && r.URL.Path == "/user/state" {
+		getUserState(w, r)
+		return
+	}
 	if r.Method == http.MethodGet && r.URL.Path == "/user" {
 		getUser(w, r)
 		return
@@ -286,10 +368,14 @@ func handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if r.URL.Path == "/callback" {
-		commons.Debug("====== EVENT RECEIVED =======")
-		// List the available headers
-		for header, values := range r.Header {
-			commons.Debug(header + ":" + strings.Join(values, ""))
+		logger := logging.FromContext(r.Context())
+		if logger.Enabled(r.Context(), slog.LevelDebug) {
+			// List the available headers
+			headers := []any{}
+			for header, values := range r.Header {
+				headers = append(headers, slog.String(header, strings.Join(values, "")))
+			}
+			logger.Debug("EventSub message received", headers...)
 		}
 
 		messageType := strings.ToLower(r.Header.Get("Twitch-Eventsub-Message-Type"))
@@ -307,30 +393,29 @@ func handler(w http.ResponseWriter, r *http.Request) {
 			if !verifySignature(w, r, body) {
 				return
 			}
+			// headers are trusted only after verification, they become metric labels
+			metrics.Callbacks.WithLabelValues(messageType, eventsub.Label(r.Header.Get("Twitch-Eventsub-Subscription-Type"))).Inc()
 		}
 
 		if messageType == "webhook_callback_verification" {
 			if contentType == "application/json" {
-				var notification WebhookCallbackVerification
-				err = json.NewDecoder(strings.NewReader(string(body))).Decode(&notification)
+				var notification eventsub.Message
+				err = json.Unmarshal(body, &notification)
 				if err != nil {
 					http.Error(w, err.Error(), http.StatusBadRequest)
 					return
 				}
 				w.WriteHeader(http.StatusOK)
-				fmt.Fprintf(w, notification.Challenge)
-
-				broadcasterId := notification.Subscription.Condition.BroadcasterUserID
-				if notification.Subscription.Condition.ToBroadcasterUserID != "" {
-					broadcasterId = notification.Subscription.Condition.ToBroadcasterUserID
-				}
-				if notification.Subscription.Condition.FromBroadcasterUserID != "" {
-					broadcasterId = notification.Subscription.Condition.FromBroadcasterUserID
-				}
-				if notification.Subscription.Condition.UserId != "" {
-					broadcasterId = notification.Subscription.Condition.UserId
-				}
-				commons.Log("User " + broadcasterId + " subscribed to " + notification.Subscription.Type + ".v" + notification.Subscription.Version)
+				fmt.Fprint(w, notification.Challenge)
+
+				broadcasterId := notification.Subscription.Condition.Owner()
+				logger.Info("User subscribed",
+					logging.UserID(broadcasterId),
+					logging.EventType(notification.Subscription.Type),
+					slog.String("version", notification.Subscription.Version),
+					logging.SubscriptionID(notification.Subscription.ID),
+				)
+				verified(r.Context(), broadcasterId, notification.Subscription)
 				return
 			}
 		}
@@ -338,35 +423,13 @@ func handler(w http.ResponseWriter, r *http.Request) {
 		if messageType == "revocation" {
 			if contentType == "application/json" {
 
-				type Payload struct {
-					Subscription struct {
-						Type      string `json:"type"`
-						Condition struct {
-							BroadcasterUserID     *string `json:"broadcaster_user_id,omitempty"`
-							ToBroadcasterUserID   *string `json:"to_broadcaster_user_id,omitempty"`
-							FromBroadcasterUserID *string `json:"from_broadcaster_user_id,omitempty"`
-							ModeratorUserID       string `json:"moderator_user_id,omitempty"`
-							UserId                *string `json:"user_id,omitempty"`
-						} `json:"condition"`
-					} `json:"subscription"`
-				}
-				var payload Payload
+				var payload eventsub.Message
 				err = json.Unmarshal(body, &payload)
 				if err != nil {
 					http.Error(w, "Failed to parse JSON payload", http.StatusBadRequest)
 					return
 				}
-				userId := payload.Subscription.Condition.BroadcasterUserID
-				if payload.Subscription.Condition.ToBroadcasterUserID != nil {
-					userId = payload.Subscription.Condition.ToBroadcasterUserID
-				}
-				if payload.Subscription.Condition.FromBroadcasterUserID != nil {
-					userId = payload.Subscription.Condition.FromBroadcasterUserID
-				}
-				if payload.Subscription.Condition.UserId != nil {
-					userId = payload.Subscription.Condition.UserId
-				}
-				database.DB.Exec("DELETE FROM eventsub_users WHERE \"userId\"=$1", userId)
+				Revocation(r.Context(), payload)
 				w.WriteHeader(204)
 
 				return
@@ -375,46 +438,7 @@ func handler(w http.ResponseWriter, r *http.Request) {
 
 		if messageType == "notification" {
 			if contentType == "application/json" {
-				type Payload struct {
-					Subscription struct {
-						Type      string `json:"type"`
-						Condition struct {
-							BroadcasterUserID     *string `json:"broadcaster_user_id,omitempty"`
-							ToBroadcasterUserID   *string `json:"to_broadcaster_user_id,omitempty"`
-							FromBroadcasterUserID *string `json:"from_broadcaster_user_id,omitempty"`
-							ModeratorUserID       string `json:"moderator_user_id,omitempty"`
-							UserId                *string `json:"user_id,omitempty"`
-						} `json:"condition"`
-					} `json:"subscription"`
-				}
-				var payload Payload
-				err = json.Unmarshal(body, &payload)
-				if err != nil {
-					http.Error(w, "Failed to parse JSON payload", http.StatusBadRequest)
-					return
-				}
-
-				userId := payload.Subscription.Condition.BroadcasterUserID
-				if payload.Subscription.Condition.ToBroadcasterUserID != nil {
-					userId = payload.Subscription.Condition.ToBroadcasterUserID
-				}
-				if payload.Subscription.Condition.FromBroadcasterUserID != nil {
-					userId = payload.Subscription.Condition.FromBroadcasterUserID
-				}
-				if payload.Subscription.Condition.UserId != nil {
-					userId = payload.Subscription.Condition.UserId
-				}
-				event := payload.Subscription.Type
-				jsonData := string(body)
-
-				commons.Log("User " + *userId + " received new event " + event)
-				rows, err := database.DB.Query("INSERT INTO eventsub_events (userId, event, data) VALUES ($1, $2, $3)", userId, event, jsonData)
-				if err != nil {
-					http.Error(w, "Failed to insert into eventsub_events", http.StatusBadRequest)
-					return
-				}
-				defer rows.Close()
-				w.WriteHeader(204)
+				notification(w, r, body)
 				return
 			}
 		}
@@ -422,22 +446,45 @@ func handler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(404)
 }
 
-func Start() {
-	var ENV string = os.Getenv("ENV")
-	if ENV == "development" {
-		commons.Log("== development version, using ngrok tunnel ==")
+var cfg *config.Config
+
+// Admin serves /admin/ routes when set, it lives outside of handler package
+// because it needs subscriptions, which depend on handler
+var Admin http.Handler
+
+func Start(conf *config.Config) {
+	cfg = conf
+	EVENTSUB_URL = cfg.CallbackURL
+	EVENTSUB_URL_PROD = cfg.CallbackURL
+
+	if cfg.Development() {
+		slog.Info("== development version ==", slog.String("tunnel", cfg.Tunnel()), slog.Any("users", cfg.Dev.UserIDs), slog.Bool("mock_twitch", cfg.Dev.MockTwitch))
+	}
+	if cfg.Development() && cfg.Tunnel() == "none" && !cfg.UsesWebSocket() {
+		// only mocked Twitch calls back, it reaches us locally
+		EVENTSUB_URL = cfg.LocalURL()
+	}
+	if cfg.Tunnel() == "manual" {
+		// tunnel started by developer forwards to our listen address
+		EVENTSUB_URL = strings.TrimSuffix(cfg.Dev.PublicURL, "/")
+	}
+
+	if cfg.Tunnel() == "ngrok" {
 		done := make(chan bool)
 
 		go func() {
 			go startPprof()
-			if err := ngrokTunnel(done); err != nil {
-				log.Fatal(err)
+			if err := ngrokTunnel(done); err != nil && err != http.ErrServerClosed {
+				slog.Error("Error serving ngrok tunnel", logging.Error(err))
+				os.Exit(1)
 			}
 		}()
 		<-done
 	} else {
 		corshandler := c.Handler(http.HandlerFunc(handler))
-		loggerHandler := commons.Logger(corshandler)
+		loggerHandler := logging.Middleware(corshandler)
+		server.Addr = cfg.ListenAddr
+		server.Handler = loggerHandler
 		// limitHandler := httprate.Limit(
 		// 	60,          // requests
 		// 	time.Minute, // per duration
@@ -446,17 +493,26 @@ func Start() {
 
 		go func() {
 			go startPprof()
-			if err := http.ListenAndServe(":8080", loggerHandler); err != nil {
-				log.Fatal(err)
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("Error serving webhooks endpoint", logging.Error(err))
+				os.Exit(1)
 			}
 		}()
 	}
 
-	commons.Log("Webhooks endpoint: " + EVENTSUB_URL)
+	if cfg.Development() {
+		// development instance owns only callbacks of its tunnel, never the production ones
+		EVENTSUB_URL_PROD = EVENTSUB_URL
+	}
+	slog.Info("Webhooks endpoint", slog.String("url", EVENTSUB_URL))
 }
 
+// startPprof serves pprof and metrics on debug address, by default only on localhost
 func startPprof() {
+	var addr string = cfg.Debug.ListenAddr
+
 	router := mux.NewRouter()
+	router.Handle("/metrics", metrics.Handler())
 	router.Handle("/debug/pprof/", http.HandlerFunc(pprof.Index))
 	router.Handle("/debug/pprof/cmdline", http.HandlerFunc(pprof.Cmdline))
 	router.Handle("/debug/pprof/profile", http.HandlerFunc(pprof.Profile))
@@ -464,7 +520,9 @@ func startPprof() {
 	router.Handle("/debug/pprof/trace", http.HandlerFunc(pprof.Trace))
 	router.Handle("/debug/pprof/{cmd}", http.HandlerFunc(pprof.Index)) // special handling for Gorilla mux
 
-	if err := http.ListenAndServe(":8081", router); err != nil {
-		log.Fatal(err)
+	slog.Info("Debug endpoint", slog.String("addr", addr))
+	if err := http.ListenAndServe(addr, metrics.BasicAuth(cfg.Debug.Username, cfg.Debug.Password, router)); err != nil {
+		slog.Error("Error serving debug endpoint", logging.Error(err))
+		os.Exit(1)
 	}
 }
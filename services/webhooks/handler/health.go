@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"services/webhooks/database"
+	"services/webhooks/token"
+	"sync"
+	"time"
+)
+
+var server = &http.Server{}
+
+// draining is closed when shutdown begins, waiting bots are released with 204
+var draining = make(chan struct{})
+var drainingOnce sync.Once
+
+func isDraining() bool {
+	select {
+	case <-draining:
+		return true
+	default:
+		return false
+	}
+}
+
+func getHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+func getReadyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{
+		"database": "ok",
+		"token":    "ok",
+		"callback": "ok",
+	}
+	ready := true
+
+	if isDraining() {
+		checks["shutdown"] = "in progress"
+		ready = false
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	if err := database.DB.Ping(ctx); err != nil {
+		checks["database"] = err.Error()
+		ready = false
+	}
+
+	if accessToken, err := token.Access(); err != nil {
+		checks["token"] = err.Error()
+		ready = false
+	} else if accessToken == "" {
+		checks["token"] = "empty access token received"
+		ready = false
+	}
+
+	if EVENTSUB_URL == "" {
+		checks["callback"] = "callback URL is not known"
+		ready = false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if ready {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(checks)
+}
+
+// Shutdown stops accepting new connections, releases waiting bots and waits
+// for in-flight requests to finish or ctx to expire
+func Shutdown(ctx context.Context) error {
+	slog.Info("Shutting down webhooks endpoint")
+	drainingOnce.Do(func() {
+		close(draining)
+	})
+	return server.Shutdown(ctx)
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"services/shared/logging"
+	"services/webhooks/database"
+	"slices"
+	"strconv"
+)
+
+// maxBotLinks caps number of broadcasters one bot account can moderate
+const maxBotLinks = 100
+
+// registration is optional JSON body of POST /user
+type registration struct {
+	// Moderates links account as bot moderating listed broadcasters, moderator
+	// subscriptions of broadcasters are then created by the bot when broadcaster
+	// does not have needed scope. Nil keeps current links, empty list removes them.
+	Moderates *[]string `json:"moderates"`
+	// RefreshToken lets WebSocket sessions renew access token of the request,
+	// it must be issued for the same client
+	RefreshToken string `json:"refresh_token"`
+}
+
+func readRegistration(r *http.Request) (registration, error) {
+	var reg registration
+	body, err := io.ReadAll(r.Body)
+	if err != nil || len(body) == 0 {
+		return reg, err
+	}
+	if err := json.Unmarshal(body, &reg); err != nil {
+		return reg, errors.New("invalid registration body")
+	}
+	if reg.Moderates == nil {
+		return reg, nil
+	}
+	if len(*reg.Moderates) > maxBotLinks {
+		return reg, errors.New("bot can moderate at most " + strconv.Itoa(maxBotLinks) + " broadcasters")
+	}
+	for _, broadcasterId := range *reg.Moderates {
+		if _, err := strconv.ParseUint(broadcasterId, 10, 64); err != nil {
+			return reg, errors.New("moderates must contain Twitch user ids")
+		}
+	}
+	return reg, nil
+}
+
+// verifyModerates keeps in registration only broadcasters Twitch reports
+// bot as moderator of, so account can not link itself to any channel
+func verifyModerates(r *http.Request, info tokenInfo, reg *registration) error {
+	if reg.Moderates == nil || len(*reg.Moderates) == 0 {
+		return nil
+	}
+	channels, err := moderatedChannels(r, info)
+	if err != nil {
+		return err
+	}
+	moderates := slices.DeleteFunc(slices.Clone(*reg.Moderates), func(broadcasterId string) bool {
+		if slices.Contains(channels, broadcasterId) {
+			return false
+		}
+		logging.FromContext(r.Context()).Warn("Bot is not moderator of broadcaster, link is dropped", logging.UserID(info.UserID), slog.String("broadcaster_id", broadcasterId))
+		return true
+	})
+	reg.Moderates = &moderates
+	return nil
+}
+
+// linkBot stores broadcasters moderated by bot and marks affected broadcasters
+// updated, so reconciler moves their subscriptions, scopesChanged marks them
+// even when links did not change
+func linkBot(ctx context.Context, botId string, reg registration, scopesChanged bool) error {
+	affected, err := database.DB.BotLinks(ctx, botId)
+	if err != nil {
+		return err
+	}
+	if reg.Moderates != nil {
+		moderates := slices.DeleteFunc(slices.Clone(*reg.Moderates), func(broadcasterId string) bool {
+			return broadcasterId == botId
+		})
+		if err := database.DB.SetBotLinks(ctx, botId, moderates); err != nil {
+			return err
+		}
+		affected = append(affected, moderates...)
+	} else if !scopesChanged {
+		return nil
+	}
+
+	for _, broadcasterId := range affected {
+		if err := database.DB.MarkUpdated(ctx, broadcasterId); err != nil {
+			logging.FromContext(ctx).Warn("Error marking broadcaster updated", logging.UserID(broadcasterId), logging.Error(err))
+		}
+	}
+	return nil
+}
@@ -1,44 +1,29 @@
 package handler
 
 import (
+	"context"
 	"services/webhooks/database"
 	"sync"
 	"time"
 )
 
-var Events map[string]struct {
-	timestamp time.Time
-	data      string
-}
+var Events map[string]database.Event
 
 var mutex = &sync.RWMutex{}
 
 func Loop() {
-	Events = make(map[string]struct {
-		timestamp time.Time
-		data      string
-	})
+	Events = make(map[string]database.Event)
 	for {
 		mutex.Lock()
 		for userId := range Events {
 			val, ok := Events[userId]
-			if ok && val.data != "" {
+			if ok && val.Data != "" {
 				continue
 			}
 
-			row := database.DB.QueryRow(`SELECT "timestamp", "data" FROM "eventsub_events" WHERE "userid"=$1 ORDER BY "timestamp" ASC LIMIT 1`, userId)
-			var timestamp time.Time
-			var data string
-			err := row.Scan(&timestamp, &data)
-
-			if err == nil {
-				Events[userId] = struct {
-					timestamp time.Time
-					data      string
-				}{
-					timestamp: timestamp,
-					data:      data,
-				}
+			event, found, err := database.DB.NextEvent(context.Background(), userId)
+			if err == nil && found {
+				Events[userId] = event
 			}
 		}
 		mutex.Unlock()
@@ -49,12 +34,9 @@ func Loop() {
 
 func Listen(userId string) {
 	mutex.Lock()
-	Events[userId] = struct {
-		timestamp time.Time
-		data      string
-	}{
-		timestamp: time.Now(),
-		data:      "",
+	Events[userId] = database.Event{
+		UserID:    userId,
+		Timestamp: time.Now(),
 	}
 	mutex.Unlock()
 }
@@ -65,10 +47,7 @@ func Done(userId string) {
 	mutex.Unlock()
 }
 
-func Get(userId string) (struct {
-	timestamp time.Time
-	data      string
-}, bool) {
+func Get(userId string) (database.Event, bool) {
 	mutex.RLock()
 	val, ok := Events[userId]
 	mutex.RUnlock()
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"services/webhooks/metrics"
+	"slices"
+	"strings"
+)
+
+// moderationScope lets bot list channels it moderates, links are verified with it
+const moderationScope = "user:read:moderated_channels"
+
+var errModerationScope = errors.New("token needs " + moderationScope + " scope to act for other broadcasters")
+
+// accessToken returns token from Authorization header without its type
+func accessToken(r *http.Request) string {
+	authorization := r.Header.Get("Authorization")
+	if _, token, found := strings.Cut(authorization, " "); found {
+		return token
+	}
+	return authorization
+}
+
+// moderatedChannels returns ids of broadcasters user owning request token moderates,
+// see https://dev.twitch.tv/docs/api/reference/#get-moderated-channels
+func moderatedChannels(r *http.Request, info tokenInfo) ([]string, error) {
+	if !slices.Contains(info.Scopes, moderationScope) {
+		return nil, errModerationScope
+	}
+
+	client := &http.Client{}
+	channels := []string{}
+	query := url.Values{"user_id": {info.UserID}, "first": {"100"}}
+	for {
+		req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, cfg.Twitch.HelixURL+"/moderation/channels?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+accessToken(r))
+		req.Header.Set("Client-Id", info.ClientID)
+
+		resp, err := client.Do(req)
+		metrics.Helix("moderation.channels", resp, err)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("listing moderated channels failed with status %d: %s", resp.StatusCode, body)
+		}
+
+		var response struct {
+			Data []struct {
+				BroadcasterID string `json:"broadcaster_id"`
+			} `json:"data"`
+			Pagination struct {
+				Cursor string `json:"cursor"`
+			} `json:"pagination"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, err
+		}
+		for _, channel := range response.Data {
+			channels = append(channels, channel.BroadcasterID)
+		}
+		if response.Pagination.Cursor == "" {
+			return channels, nil
+		}
+		query.Set("after", response.Pagination.Cursor)
+	}
+}
+
+// authorizedFor tells if user owning request token may act for broadcaster,
+// which is true for broadcaster itself and for its moderators
+func authorizedFor(r *http.Request, info tokenInfo, broadcasterId string) (bool, error) {
+	if info.UserID == broadcasterId {
+		return true, nil
+	}
+	channels, err := moderatedChannels(r, info)
+	if errors.Is(err, errModerationScope) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(channels, broadcasterId), nil
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"services/shared/logging"
+	"services/webhooks/chat"
+	"services/webhooks/database"
+	"services/webhooks/eventsub"
+	"services/webhooks/giftbomb"
+	"services/webhooks/metrics"
+	"services/webhooks/sessions"
+	"services/webhooks/sinks"
+	"services/webhooks/state"
+)
+
+// notificationError is failure of notification processing, status is answered to Twitch
+type notificationError struct {
+	status  int
+	message string
+	err     error
+}
+
+func (e *notificationError) Error() string {
+	return e.message + ": " + e.err.Error()
+}
+
+func (e *notificationError) Unwrap() error {
+	return e.err
+}
+
+// notification validates EventSub notification and queues it for the user
+func notification(w http.ResponseWriter, r *http.Request, body []byte) {
+	if err := Notification(r.Context(), r.Header, body); err != nil {
+		var failed *notificationError
+		if errors.As(err, &failed) {
+			http.Error(w, failed.message, failed.status)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Notification queues EventSub notification for the user, header carries
+// Twitch-Eventsub-Message-* values of the message. It is shared by webhook
+// callbacks and WebSocket sessions.
+func Notification(ctx context.Context, header http.Header, body []byte) error {
+	logger := logging.FromContext(ctx)
+
+	envelope, err := eventsub.Parse(header, body)
+	if err != nil {
+		logger.Warn("Invalid EventSub notification", logging.Error(err))
+		if errors.Is(err, eventsub.ErrUnknownType) {
+			// we cannot do anything with it, retrying would not help
+			return nil
+		}
+		return &notificationError{http.StatusBadRequest, "Failed to parse JSON payload", err}
+	}
+	userId := envelope.BroadcasterID
+
+	if chat.Tracks(envelope.Type) {
+		// chat skips the queue, it is delivered only to bots waiting on /user/chat
+		if err := chat.Publish(ctx, envelope, body); err != nil {
+			// failed answers make Twitch revoke subscription, chat is not worth it
+			logger.Error("Error publishing chat event", logging.UserID(userId), logging.EventType(envelope.Type), logging.Error(err))
+			metrics.ChatPublishFailures.Inc()
+			return nil
+		}
+		metrics.ChatPublished.WithLabelValues(envelope.Type).Inc()
+		sinks.Publish(envelope)
+		return nil
+	}
+
+	logger.Info("User received new event", logging.UserID(userId), logging.EventType(envelope.Type))
+	inserted, err := database.Queue(ctx, database.Event{
+		MessageID:     envelope.ID,
+		UserID:        userId,
+		Type:          envelope.Type,
+		Version:       envelope.Version,
+		BroadcasterID: envelope.BroadcasterID,
+		Data:          string(body),
+		Timestamp:     envelope.OccurredAt,
+	})
+	if err != nil {
+		logger.Error("Error inserting event", logging.UserID(userId), logging.Error(err))
+		return &notificationError{http.StatusBadRequest, "Failed to insert into eventsub_events", err}
+	}
+	if !inserted {
+		// Twitch resends messages it did not get response for in time
+		logger.Debug("Duplicate EventSub message", logging.UserID(userId), slog.String("message_id", envelope.ID))
+		return nil
+	}
+	metrics.EventsInserted.WithLabelValues(envelope.Type).Inc()
+	sinks.Publish(envelope)
+
+	if giftbomb.Tracks(envelope.Type) {
+		correlationId, err := giftbomb.Correlate(ctx, envelope)
+		if err == nil && correlationId != "" {
+			err = database.DB.SetCorrelation(ctx, envelope.ID, correlationId)
+		}
+		if err != nil {
+			logger.Error("Error correlating gift bomb", logging.UserID(userId), logging.EventType(envelope.Type), logging.Error(err))
+		}
+	}
+	if sessions.Tracks(envelope.Type) {
+		if err := sessions.Record(ctx, envelope); err != nil {
+			logger.Error("Error recording stream session", logging.UserID(userId), logging.EventType(envelope.Type), logging.Error(err))
+		}
+	}
+	if reward, ok := envelope.Event.(*eventsub.ChannelPointsCustomReward); ok && envelope.Type == "channel.channel_points_custom_reward.remove" {
+		if err := database.PruneRewardFilter(ctx, userId, reward.ID); err != nil {
+			logger.Error("Error removing reward filter", logging.UserID(userId), slog.String("reward_id", reward.ID), logging.Error(err))
+		}
+	}
+	if state.Tracks(envelope.Type) {
+		if err := state.Update(ctx, envelope); err != nil {
+			logger.Error("Error updating channel state", logging.UserID(userId), logging.EventType(envelope.Type), logging.Error(err))
+		}
+	}
+	return nil
+}
+
+// Revocation forgets user whose subscription was revoked, bots only lose link
+// to broadcaster
+func Revocation(ctx context.Context, message eventsub.Message) {
+	userId := message.Subscription.Condition.Owner()
+	if botId := message.Subscription.Condition.Moderator(); botId != "" {
+		// bot lost authorization or moderator role, broadcaster is fine
+		if err := database.UnlinkBot(ctx, botId, userId); err != nil {
+			logging.FromContext(ctx).Error("Error unlinking bot", logging.UserID(userId), slog.String("bot_id", botId), logging.Error(err))
+		}
+	} else if userId != "" {
+		database.DB.DeleteUser(ctx, userId)
+	}
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"services/shared/logging"
+	"services/webhooks/database"
+	"slices"
+	"strconv"
+)
+
+// maxRewardFilters caps number of reward specific subscriptions per broadcaster
+const maxRewardFilters = 50
+
+type rewardFilters struct {
+	BroadcasterID string `json:"broadcaster_id"`
+	// RewardIDs are rewards redemptions are subscribed for, empty means all rewards
+	RewardIDs []string `json:"reward_ids"`
+}
+
+// rewardsBroadcaster returns broadcaster whose filters are managed, by default
+// the token owner, moderators can manage filters of broadcasters Twitch reports
+// them moderating
+func rewardsBroadcaster(w http.ResponseWriter, r *http.Request) (string, bool) {
+	info, ok := authenticate(w, r)
+	if !ok {
+		return "", false
+	}
+	broadcasterId := r.URL.Query().Get("broadcaster_id")
+	if broadcasterId == "" {
+		return info.UserID, true
+	}
+
+	authorized, err := authorizedFor(r, info, broadcasterId)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error verifying moderated channels", logging.UserID(info.UserID), logging.Error(err))
+		http.Error(w, "Failed to verify moderated channels", http.StatusBadGateway)
+		return "", false
+	}
+	if !authorized {
+		http.Error(w, "Token owner is not moderator of broadcaster", http.StatusForbidden)
+		return "", false
+	}
+	return broadcasterId, true
+}
+
+func getUserRewards(w http.ResponseWriter, r *http.Request) {
+	broadcasterId, ok := rewardsBroadcaster(w, r)
+	if !ok {
+		return
+	}
+	rewardIds, err := database.DB.RewardFilters(r.Context(), broadcasterId)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error loading reward filters", logging.UserID(broadcasterId), logging.Error(err))
+		http.Error(w, "Failed to load reward filters", http.StatusInternalServerError)
+		return
+	}
+	writeRewards(w, r, rewardFilters{BroadcasterID: broadcasterId, RewardIDs: rewardIds})
+}
+
+// putUserRewards replaces reward filters, subscriptions are changed by reconciler
+func putUserRewards(w http.ResponseWriter, r *http.Request) {
+	broadcasterId, ok := rewardsBroadcaster(w, r)
+	if !ok {
+		return
+	}
+
+	var filters rewardFilters
+	if err := json.NewDecoder(r.Body).Decode(&filters); err != nil {
+		http.Error(w, "Failed to parse JSON payload", http.StatusBadRequest)
+		return
+	}
+	rewardIds := []string{}
+	for _, rewardId := range filters.RewardIDs {
+		if rewardId == "" || len(rewardId) > 64 {
+			http.Error(w, "reward_ids must contain reward ids", http.StatusBadRequest)
+			return
+		}
+		if !slices.Contains(rewardIds, rewardId) {
+			rewardIds = append(rewardIds, rewardId)
+		}
+	}
+	if len(rewardIds) > maxRewardFilters {
+		http.Error(w, "at most "+strconv.Itoa(maxRewardFilters)+" rewards can be filtered", http.StatusBadRequest)
+		return
+	}
+
+	if err := database.DB.SetRewardFilters(r.Context(), broadcasterId, rewardIds); err != nil {
+		logging.FromContext(r.Context()).Error("Error storing reward filters", logging.UserID(broadcasterId), logging.Error(err))
+		http.Error(w, "Failed to store reward filters", http.StatusInternalServerError)
+		return
+	}
+	if err := database.DB.MarkUpdated(r.Context(), broadcasterId); err != nil {
+		logging.FromContext(r.Context()).Warn("Error marking user updated", logging.UserID(broadcasterId), logging.Error(err))
+	}
+	slices.Sort(rewardIds)
+	writeRewards(w, r, rewardFilters{BroadcasterID: broadcasterId, RewardIDs: rewardIds})
+}
+
+func writeRewards(w http.ResponseWriter, r *http.Request, filters rewardFilters) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(filters); err != nil {
+		logging.FromContext(r.Context()).Error("Error writing reward filters", logging.UserID(filters.BroadcasterID), logging.Error(err))
+	}
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"services/shared/logging"
+	"services/webhooks/database"
+	"strconv"
+	"time"
+)
+
+type session struct {
+	StreamID        string     `json:"stream_id"`
+	StartedAt       time.Time  `json:"started_at"`
+	EndedAt         *time.Time `json:"ended_at"`
+	DurationSeconds int64      `json:"duration_seconds"`
+	Title           string     `json:"title"`
+	CategoryID      string     `json:"category_id"`
+	CategoryName    string     `json:"category_name"`
+}
+
+type sessionsResponse struct {
+	Live bool `json:"live"`
+	// Current is live session, its duration is uptime
+	Current  *session  `json:"current"`
+	Sessions []session `json:"sessions"`
+}
+
+// getUserSessions returns stream sessions of user, newest first
+func getUserSessions(w http.ResponseWriter, r *http.Request) {
+	userId := requestUserId(r)
+
+	if len(userId) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	sessions, err := database.DB.ListSessions(r.Context(), userId, limit)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error loading stream sessions", logging.UserID(userId), logging.Error(err))
+		http.Error(w, "Failed to load stream sessions", http.StatusInternalServerError)
+		return
+	}
+
+	response := sessionsResponse{Sessions: []session{}}
+	for _, s := range sessions {
+		item := session{
+			StreamID:     s.StreamID,
+			StartedAt:    s.StartedAt,
+			EndedAt:      s.EndedAt,
+			Title:        s.Title,
+			CategoryID:   s.CategoryID,
+			CategoryName: s.CategoryName,
+		}
+		if s.EndedAt != nil {
+			item.DurationSeconds = int64(s.EndedAt.Sub(s.StartedAt).Seconds())
+		} else {
+			item.DurationSeconds = int64(time.Since(s.StartedAt).Seconds())
+			if response.Current == nil {
+				response.Live = true
+				response.Current = &item
+			}
+		}
+		response.Sessions = append(response.Sessions, item)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
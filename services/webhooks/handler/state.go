@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"services/shared/logging"
+	"services/webhooks/state"
+)
+
+// getUserState returns snapshot of channel state, so bot knows about running polls, predictions etc.
+func getUserState(w http.ResponseWriter, r *http.Request) {
+	userId := requestUserId(r)
+
+	if len(userId) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	snapshot, err := state.Get(r.Context(), userId)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error loading channel state", logging.UserID(userId), logging.Error(err))
+		http.Error(w, "Failed to load channel state", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(snapshot)
+}
@@ -2,368 +2,134 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"log"
-	"services/webhooks/commons"
+	"log/slog"
+	"os"
+	"os/signal"
+	sharedconfig "services/shared/config"
+	"services/shared/logging"
+	"services/webhooks/admin"
+	"services/webhooks/chat"
+	"services/webhooks/config"
 	"services/webhooks/database"
 	"services/webhooks/debug"
+	"services/webhooks/devtwitch"
+	"services/webhooks/eventsub"
+	"services/webhooks/forward"
+	"services/webhooks/giftbomb"
 	"services/webhooks/handler"
+	"services/webhooks/reconciler"
+	"services/webhooks/sinks"
+	"services/webhooks/socket"
 	"services/webhooks/subscriptions"
-	"strconv"
-	"strings"
-	"sync"
+	"services/webhooks/token"
+	"syscall"
 	"time"
 
-	"database/sql"
-
 	_ "github.com/joho/godotenv/autoload"
-	_ "github.com/lib/pq"
-	"golang.org/x/sync/semaphore"
 )
 
-var PG_USER_DB string = "eventsub_users"
-
 func main() {
-	commons.Log("Starting up EventSub Webhooks service")
-	database.Init()
-	commons.Log("EventSub Webhooks service started")
-
-	handler.Start()
-	go handler.Loop()
-	handleUsers(false)
-}
-
-var sem = semaphore.NewWeighted(int64(10))
-var ctx = context.Background()
+	logging.Setup("webhooks")
 
-func handleUsers(updatedOnly bool) {
-	var rows *sql.Rows
-	var err error
-
-	subscriptions.List()
-	if !updatedOnly {
-		subscriptions.CleanDuplicatedSubscriptions()
-		subscriptions.List()
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		slog.Error("Error loading configuration", logging.Error(err))
+		os.Exit(1)
 	}
-
-	commons.Log("Currently subscribed to " + strconv.Itoa(len(subscriptions.SubscriptionList)) + " event(s)")
-
-	// remove all subscriptions for users
-	newSubscription = []NewSubscription{}
-
-	if updatedOnly {
-		rows, err = database.DB.Query(
-			fmt.Sprintf("SELECT * FROM %s WHERE updated=$1", PG_USER_DB), true,
-		)
-		if err != nil {
-			log.Fatal(err)
-		}
-		database.DB.Exec("UPDATE eventsub_users SET updated=$1", false)
+	if sinks.WritesStdout(cfg.Sinks) {
+		// standard output carries events of stdout sink
+		logging.SetupWithWriter("webhooks", os.Stderr, cfg.Log.Level, cfg.Log.Format)
 	} else {
-		rows, err = database.DB.Query(
-			fmt.Sprintf("SELECT * FROM %s", PG_USER_DB),
-		)
-		if err != nil {
-			log.Fatal(err)
-		}
+		logging.SetupWithConfig("webhooks", cfg.Log)
 	}
-	defer rows.Close()
-
-	var (
-		userId  string
-		scopes  string
-		updated bool
-	)
-	for rows.Next() {
-		rows.Scan(&userId, &scopes, &updated)
-
-		if debug.IsDEV() {
-			if userId != "96965261" {
-				continue
-			}
+	slog.Debug("Loaded configuration", slog.Any("config", sharedconfig.Redacted(cfg)))
+	debug.SetDevelopment(cfg.Development())
+	debug.SetUsers(cfg.Dev.UserIDs)
+	if cfg.Dev.MockTwitch {
+		if err := devtwitch.Start(cfg); err != nil {
+			slog.Error("Error starting Twitch mock", logging.Error(err))
+			os.Exit(1)
 		}
-		basic := map[string]interface{}{
-			"broadcaster_user_id": userId,
-		}
-
-		subscriptionsMap := map[string][]struct {
-			event     string
-			version   string
-			condition map[string]interface{}
-		}{
-			"": {{
-				event:   "channel.raid",
-				version: "1",
-				condition: map[string]interface{}{
-					"to_broadcaster_user_id": userId,
-				},
-			}, {
-				event:   "channel.raid",
-				version: "1",
-				condition: map[string]interface{}{
-					"from_broadcaster_user_id": userId,
-				},
-			}, {
-				event:     "channel.update",
-				version:   "2",
-				condition: basic,
-			}},
-			"user:read:email": {{
-				event:   "user.update",
-				version: "1",
-				condition: map[string]interface{}{
-					"user_id": userId,
-				},
-			}},
-			"moderator:read:followers": {{
-				event:   "channel.follow",
-				version: "2",
-				condition: map[string]interface{}{
-					"broadcaster_user_id": userId,
-					"moderator_user_id":   userId,
-				},
-			}},
-			"channel:read:redemptions": {{
-				event:     "channel.channel_points_custom_reward_redemption.add",
-				version:   "1",
-				condition: basic,
-			}, {
-				event:     "channel.channel_points_custom_reward_redemption.update",
-				version:   "1",
-				condition: basic,
-			}, {
-				event:     "channel.channel_points_custom_reward.add",
-				version:   "1",
-				condition: basic,
-			}, {
-				event:     "channel.channel_points_custom_reward.update",
-				version:   "1",
-				condition: basic,
-			}, {
-				event:     "channel.channel_points_custom_reward.remove",
-				version:   "1",
-				condition: basic,
-			}},
-			"bits:read": {{
-				event:     "channel.cheer",
-				version:   "1",
-				condition: basic,
-			}},
-			"channel:moderate": {{
-				event:     "channel.ban",
-				version:   "1",
-				condition: basic,
-			}, {
-				event:     "channel.unban",
-				version:   "1",
-				condition: basic,
-			}},
-			"channel:read:predictions": {{
-				event:     "channel.prediction.begin",
-				version:   "1",
-				condition: basic,
-			}, {
-				event:     "channel.prediction.progress",
-				version:   "1",
-				condition: basic,
-			}, {
-				event:     "channel.prediction.lock",
-				version:   "1",
-				condition: basic,
-			}, {
-				event:     "channel.prediction.end",
-				version:   "1",
-				condition: basic,
-			}},
-			"channel:read:polls": {{
-				event:     "channel.poll.begin",
-				version:   "1",
-				condition: basic,
-			}, {
-				event:     "channel.poll.progress",
-				version:   "1",
-				condition: basic,
-			}, {
-				event:     "channel.poll.end",
-				version:   "1",
-				condition: basic,
-			}},
-			"channel:read:hype_train": {{
-				event:     "channel.hype_train.begin",
-				version:   "1",
-				condition: basic,
-			}, {
-				event:     "channel.hype_train.progress",
-				version:   "1",
-				condition: basic,
-			}, {
-				event:     "channel.hype_train.end",
-				version:   "1",
-				condition: basic,
-			}},
-			"channel:read:charity": {{
-				event:     "channel.charity_campaign.donate",
-				version:   "1",
-				condition: basic,
-			}, {
-				event:     "channel.charity_campaign.start",
-				version:   "1",
-				condition: basic,
-			}, {
-				event:     "channel.charity_campaign.progress",
-				version:   "1",
-				condition: basic,
-			}, {
-				event:     "channel.charity_campaign.stop",
-				version:   "1",
-				condition: basic,
-			}},
-			"channel:read:goals": {{
-				event:     "channel.goal.begin",
-				version:   "1",
-				condition: basic,
-			}, {
-				event:     "channel.goal.progress",
-				version:   "1",
-				condition: basic,
-			}, {
-				event:     "channel.goal.end",
-				version:   "1",
-				condition: basic,
-			}},
-			"moderation:read": {{
-				event:     "channel.moderator.add",
-				version:   "1",
-				condition: basic,
-			}, {
-				event:     "channel.moderator.remove",
-				version:   "1",
-				condition: basic,
-			}},
-			"moderator:read:shield_mode": {{
-				event:   "channel.shield_mode.begin",
-				version: "1",
-				condition: map[string]interface{}{
-					"broadcaster_user_id": userId,
-					"moderator_user_id":   userId,
-				},
-			}, {
-				event:   "channel.shield_mode.end",
-				version: "1",
-				condition: map[string]interface{}{
-					"broadcaster_user_id": userId,
-					"moderator_user_id":   userId,
-				},
-			}},
-			"channel:read:ads": {{
-				event:     "channel.ad_break.begin",
-				version:   "1",
-				condition: basic,
-			}},
-			"moderator:read:shoutouts": {{
-				event:   "channel.shoutout.create",
-				version: "1",
-				condition: map[string]interface{}{
-					"broadcaster_user_id": userId,
-					"moderator_user_id":   userId,
-				},
-			}, {
-				event:   "channel.shoutout.receive",
-				version: "1",
-				condition: map[string]interface{}{
-					"broadcaster_user_id": userId,
-					"moderator_user_id":   userId,
-				},
-			}},
+	}
+	token.Configure(cfg.Twitch)
+	if cfg.UsesWebSocket() {
+		if err := token.ConfigureUsers(cfg.Socket.TokenKey); err != nil {
+			slog.Error("Error loading token encryption key", logging.Error(err))
+			os.Exit(1)
 		}
-		for scope, data := range subscriptionsMap {
-			if strings.Contains(scopes, scope) {
-			OuterLoop:
-				for _, val := range data {
-					for _, item := range subscriptions.SubscriptionList {
-						condition1, err := json.Marshal(val.condition)
-						if err != nil {
-							fmt.Println("Error marshaling map to JSON:", err)
-							return
-						}
-						// we need to remarshal the condition to objects to compare
-						var conditionMarshalledDefined subscriptions.Condition
-						err = json.Unmarshal(condition1, &conditionMarshalledDefined)
-						if err != nil {
-							fmt.Println("Error unmarshaling:", err)
-							return
-						}
-
-						condition2, err := json.Marshal(item.Condition)
-						if err != nil {
-							fmt.Println("Error marshaling map to JSON:", err)
-							return
-						}
-						// we need to remarshal the condition to objects to compare
-						var conditionMarshalledReceived subscriptions.Condition
-						err = json.Unmarshal(condition2, &conditionMarshalledReceived)
-						if err != nil {
-							fmt.Println("Error unmarshaling:", err)
-							return
-						}
+	}
+	subscriptions.Configure(cfg.Twitch)
+	subscriptions.ConfigureConduit(cfg.Conduit)
+	subscriptions.ConfigureCallbacks(cfg.Callbacks)
+	reconciler.Configure(cfg.Callbacks)
+	socket.Configure(cfg.Socket, cfg.Twitch)
+	database.ConfigureQueue(cfg.Queue)
+	giftbomb.Configure(cfg.GiftBomb)
+	chat.Configure(cfg.Chat)
+	forward.Configure(cfg.Forward)
+	if err := eventsub.Configure(cfg.Custom.Subscriptions); err != nil {
+		slog.Error("Error loading custom subscriptions", logging.Error(err))
+		os.Exit(1)
+	}
 
-						// check if already subscribed
-						if item.Type == val.event && item.Version == val.version && conditionMarshalledDefined.Equal(&conditionMarshalledReceived) {
-							// skip
-							// commons.Log("User " + userId + " already subscribed for " + val.scope + ".v" + val.version)
-							// continue on outer loop to next subscription
-							continue OuterLoop
-						}
-					}
+	if len(args) > 0 && args[0] == "migrate" {
+		os.Exit(migrate(cfg, args[1:]))
+	}
 
-					// not found in loop, add to newSubscription
-					commons.Log("User " + userId + " added to new subscription list " + val.event + ".v" + val.version)
-					newSubscription = append(newSubscription, NewSubscription{
-						userId:    userId,
-						event:     val.event,
-						version:   val.version,
-						condition: val.condition,
-					})
-				}
-			}
-		}
+	slog.Info("Starting up EventSub Webhooks service")
+	if err := sinks.Configure(cfg.Sinks); err != nil {
+		slog.Error("Error configuring sinks", logging.Error(err))
+		os.Exit(1)
 	}
+	database.Init(cfg.Database)
+	slog.Info("EventSub Webhooks service started")
 
-	// subscribe all users in newSubscription
-	subscribe()
+	handler.Admin = admin.Handler(cfg.Admin)
+	handler.Start(cfg)
+	go handler.Loop()
+	if subscriptions.UsesConduit() {
+		// shards need our callback before subscriptions are created
+		subscriptions.SetupConduit()
+		go subscriptions.ConduitLoop()
+	}
+	if subscriptions.UsesWebSocket() {
+		go socket.Loop()
+	}
+	// with WebSocket transport reconciler only removes subscriptions of previous transports
+	go reconciler.Loop()
+	go giftbomb.Loop()
+	go chat.Loop()
+	go forward.Loop()
 
-	time.Sleep(time.Minute)
-	// run again after while
-	handleUsers(true)
-}
+	stop, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	<-stop.Done()
 
-type NewSubscription struct {
-	userId    string
-	event     string
-	version   string
-	condition interface{}
+	shutdown()
 }
 
-var newSubscription []NewSubscription
+// shutdownTimeout is how long we wait for in-flight requests before exiting
+var shutdownTimeout = 30 * time.Second
 
-func subscribe() {
-	var wg sync.WaitGroup
+func shutdown() {
+	slog.Info("Stopping EventSub Webhooks service")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 
-	commons.Log("Subscribing " + strconv.Itoa(len(newSubscription)) + " user(s) to new events")
-
-	for len(newSubscription) > 0 {
-		// commons.Log("Subscribing user " + val.userId + " for " + val.scope + ".v" + val.version)
-		val := newSubscription[len(newSubscription)-1]
-		// Update the slice to remove the last element
-		newSubscription = newSubscription[:len(newSubscription)-1]
-		sem.Acquire(ctx, 1)
-		go func() {
-			// commons.Log("Releasing semaphore for " + val.userId + " " + val.scope + ".v" + val.version)
-			time.Sleep(time.Second / 2)
-			sem.Release(1)
-		}()
-		wg.Add(1)
-		go subscriptions.Create(&wg, val.userId, val.event, val.version, val.condition)
+	if subscriptions.UsesConduit() || subscriptions.UsesWebSocket() {
+		if err := subscriptions.Leave(); err != nil {
+			slog.Error("Error leaving replicas", logging.Error(err))
+		}
+	}
+	if subscriptions.UsesWebSocket() {
+		socket.Shutdown()
+	}
+	if err := handler.Shutdown(ctx); err != nil {
+		slog.Error("Error shutting down webhooks endpoint", logging.Error(err))
+	}
+	sinks.Close(ctx)
+	if err := database.Close(); err != nil {
+		slog.Error("Error closing database", logging.Error(err))
 	}
+	slog.Info("EventSub Webhooks service stopped")
 }
@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	Callbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhooks_callbacks_total",
+		Help: "EventSub callbacks received, by message type and event type.",
+	}, []string{"message_type", "event_type"})
+
+	SignatureFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "webhooks_signature_failures_total",
+		Help: "EventSub callbacks rejected because of invalid signature.",
+	})
+
+	EventsInserted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhooks_events_inserted_total",
+		Help: "Events stored into eventsub_events, by event type.",
+	}, []string{"event_type"})
+
+	ChatPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhooks_chat_published_total",
+		Help: "Chat events published to bots without queueing, by event type.",
+	}, []string{"event_type"})
+
+	ChatPublishFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "webhooks_chat_publish_failures_total",
+		Help: "Chat events lost because they could not be published.",
+	})
+
+	EventsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhooks_events_dropped_total",
+		Help: "Events dropped because user queue was full, by overflow policy.",
+	}, []string{"policy"})
+
+	EventsDelivered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhooks_events_delivered_total",
+		Help: "Events delivered to bots, by event type.",
+	}, []string{"event_type"})
+
+	DeliveryLatency = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "webhooks_delivery_latency_seconds",
+		Help:    "Time between Twitch sending an event and bot receiving it.",
+		Buckets: []float64{0.5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600},
+	})
+
+	LongPollWaiters = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "webhooks_long_poll_waiters",
+		Help: "Bots currently waiting on GET /user.",
+	})
+
+	QueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "webhooks_queue_depth_users",
+		Help: "Number of users with queued events, by queue depth bucket.",
+	}, []string{"bucket"})
+
+	WebSocketSessions = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "webhooks_websocket_sessions",
+		Help: "EventSub WebSocket sessions opened with tokens of users.",
+	})
+
+	ForwardDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhooks_forward_deliveries_total",
+		Help: "Events posted to user endpoints, by result.",
+	}, []string{"result"})
+
+	SinkEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhooks_sink_events_total",
+		Help: "Events copied to sinks, by sink and result.",
+	}, []string{"sink", "result"})
+
+	HelixRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhooks_helix_requests_total",
+		Help: "Requests sent to Twitch API, by endpoint and response status.",
+	}, []string{"endpoint", "status"})
+
+	SyncDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "webhooks_subscription_sync_duration_seconds",
+		Help:    "Duration of one subscription synchronization pass.",
+		Buckets: prometheus.ExponentialBuckets(0.5, 2, 12),
+	})
+)
+
+// QueueDepthBuckets are upper bounds of queue depth buckets, last bucket is unbounded
+var QueueDepthBuckets = []int{1, 10, 100, 1000}
+
+// QueueDepthBucket returns label of bucket for given queue depth
+func QueueDepthBucket(depth int) string {
+	lower := 1
+	for _, upper := range QueueDepthBuckets {
+		if depth <= upper {
+			if lower == upper {
+				return strconv.Itoa(upper)
+			}
+			return strconv.Itoa(lower) + "-" + strconv.Itoa(upper)
+		}
+		lower = upper + 1
+	}
+	return strconv.Itoa(lower) + "+"
+}
+
+// SetQueueDepths replaces queue depth gauges with values computed from per user depths
+func SetQueueDepths(depths map[string]int) {
+	counts := map[string]int{}
+	for _, depth := range depths {
+		counts[QueueDepthBucket(depth)]++
+	}
+
+	// report all buckets, so empty ones go back to zero
+	for _, upper := range QueueDepthBuckets {
+		label := QueueDepthBucket(upper)
+		QueueDepth.WithLabelValues(label).Set(float64(counts[label]))
+	}
+	label := QueueDepthBucket(QueueDepthBuckets[len(QueueDepthBuckets)-1] + 1)
+	QueueDepth.WithLabelValues(label).Set(float64(counts[label]))
+}
+
+// Helix records response of request sent to Twitch API
+func Helix(endpoint string, resp *http.Response, err error) {
+	status := "error"
+	if err == nil && resp != nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	HelixRequests.WithLabelValues(endpoint, status).Inc()
+}
+
+// Delivered records event delivered to bot, sentAt is Twitch message timestamp
+func Delivered(eventType string, sentAt time.Time) {
+	EventsDelivered.WithLabelValues(eventType).Inc()
+	if !sentAt.IsZero() {
+		DeliveryLatency.Observe(time.Since(sentAt).Seconds())
+	}
+}
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// BasicAuth protects handler with username and password, if set
+func BasicAuth(username string, password string, handler http.Handler) http.Handler {
+	if username == "" && password == "" {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(u), []byte(username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(p), []byte(password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="webhooks"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"services/shared/logging"
+	"services/webhooks/config"
+	"services/webhooks/database"
+	"time"
+)
+
+// migrate handles `webhooks migrate [status|up]` command
+func migrate(cfg *config.Config, args []string) int {
+	command := "status"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	database.Open(cfg.Database)
+	defer database.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	switch command {
+	case "up":
+		if err := database.DB.Migrate(ctx); err != nil {
+			slog.Error("Error migrating database", logging.Error(err))
+			return 1
+		}
+		slog.Info("Database is up to date")
+		return 0
+	case "status":
+		migrations, err := database.DB.MigrationStatus(ctx)
+		if err != nil {
+			slog.Error("Error reading migration status", logging.Error(err))
+			return 1
+		}
+		for _, migration := range migrations {
+			status := "pending"
+			if migration.AppliedAt != nil {
+				status = "applied " + migration.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d  %-40s %s\n", migration.Version, migration.Name, status)
+		}
+		return 0
+	default:
+		slog.Error("Unknown migrate command, use status or up", slog.String("command", command))
+		return 2
+	}
+}
@@ -0,0 +1,57 @@
+package reconciler
+
+import (
+	"log/slog"
+	"services/shared/logging"
+	"services/webhooks/subscriptions"
+	"slices"
+)
+
+// migrateCallbacks replaces subscriptions of previous callback URLs with ones
+// using current transport, at most batch of them in one pass. Old subscription
+// is deleted once the new one is enabled, so users do not miss events.
+func migrateCallbacks() {
+	created := 0
+	deleted := []string{}
+	for _, old := range subscriptions.SubscriptionList {
+		if !subscriptions.Previous(old) {
+			continue
+		}
+		userId := old.Condition.Owner()
+
+		replacement := slices.IndexFunc(subscriptions.SubscriptionList, func(item subscriptions.Data) bool {
+			return item.Type == old.Type && item.Version == old.Version && item.Condition.Equal(&old.Condition) && subscriptions.Current(item)
+		})
+		if replacement == -1 {
+			if created >= callbacks.Batch {
+				continue
+			}
+			created++
+			slog.Info("Moving subscription to current transport", logging.UserID(userId), logging.SubscriptionID(old.ID), logging.EventType(old.Type), slog.String("callback", old.Transport.Callback))
+			newSubscription = append(newSubscription, NewSubscription{
+				userId:    userId,
+				event:     old.Type,
+				version:   old.Version,
+				condition: old.Condition,
+			})
+			continue
+		}
+		if subscriptions.SubscriptionList[replacement].Status != subscriptions.Enabled {
+			continue
+		}
+
+		if !unsubscribe(userId, old) {
+			continue
+		}
+		slog.Info("Subscription of previous callback deleted", logging.UserID(userId), logging.SubscriptionID(old.ID), logging.EventType(old.Type), slog.String("callback", old.Transport.Callback))
+		deleted = append(deleted, old.ID)
+	}
+
+	// list is refreshed only by full pass, deleted ones would be deleted again
+	subscriptions.SubscriptionList = slices.DeleteFunc(subscriptions.SubscriptionList, func(item subscriptions.Data) bool {
+		return slices.Contains(deleted, item.ID)
+	})
+	if created > 0 {
+		slog.Info("Subscriptions moved from previous callbacks", slog.Int("count", created), slog.Int("batch", callbacks.Batch))
+	}
+}
@@ -0,0 +1,115 @@
+package reconciler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"services/shared/logging"
+	"services/webhooks/database"
+	"services/webhooks/eventsub"
+	"services/webhooks/subscriptions"
+	"services/webhooks/token"
+)
+
+// syncCustom creates custom subscriptions requested for broadcaster and deletes
+// the ones deleted by bots, not allowlisted anymore or missing scopes
+func syncCustom(broadcaster database.User, bots []database.User) {
+	userId := broadcaster.ID
+	list, err := database.DB.ListCustomSubscriptions(ctx, userId)
+	if err != nil {
+		slog.Error("Error loading custom subscriptions", logging.UserID(userId), logging.Error(err))
+		return
+	}
+
+	for _, custom := range list {
+		if custom.BroadcasterID != userId {
+			continue
+		}
+		condition := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(custom.Condition), &condition); err != nil {
+			slog.Error("Invalid custom subscription condition", logging.UserID(userId), slog.Int64("id", custom.ID), logging.Error(err))
+			continue
+		}
+		item, found := find(custom.Type, custom.Version, condition)
+
+		if custom.Deleted {
+			if found && !unsubscribe(userId, item) {
+				continue
+			}
+			if err := database.DB.DeleteCustomSubscription(ctx, custom.ID); err != nil {
+				slog.Error("Error deleting custom subscription", logging.UserID(userId), slog.Int64("id", custom.ID), logging.Error(err))
+			}
+			continue
+		}
+
+		if !allowed(custom, broadcaster, bots) {
+			if found {
+				slog.Info("Custom subscription is not allowed anymore", logging.UserID(userId), logging.EventType(custom.Type), slog.String("requester_id", custom.RequesterID))
+				unsubscribe(userId, item)
+			}
+			continue
+		}
+		if found {
+			continue
+		}
+
+		slog.Info("User added to new subscription list", logging.UserID(userId), logging.EventType(custom.Type), slog.String("version", custom.Version), slog.String("requester_id", custom.RequesterID))
+		newSubscription = append(newSubscription, NewSubscription{
+			userId:    userId,
+			event:     custom.Type,
+			version:   custom.Version,
+			condition: condition,
+			customId:  custom.ID,
+		})
+	}
+}
+
+// cleanCustom deletes custom subscriptions of deleted broadcasters, reconciler
+// does not visit them in syncCustom anymore
+func cleanCustom() {
+	list, err := database.DB.ListOrphanedCustomSubscriptions(ctx)
+	if err != nil {
+		slog.Error("Error loading orphaned custom subscriptions", logging.Error(err))
+		return
+	}
+	for _, custom := range list {
+		condition := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(custom.Condition), &condition); err != nil {
+			slog.Error("Invalid custom subscription condition", logging.UserID(custom.BroadcasterID), slog.Int64("id", custom.ID), logging.Error(err))
+			continue
+		}
+		if item, found := find(custom.Type, custom.Version, condition); found && !unsubscribe(custom.BroadcasterID, item) {
+			continue
+		}
+		slog.Info("Deleting custom subscription of deleted broadcaster", logging.UserID(custom.BroadcasterID), logging.EventType(custom.Type), slog.Int64("id", custom.ID))
+		if err := database.DB.DeleteCustomSubscription(ctx, custom.ID); err != nil {
+			slog.Error("Error deleting custom subscription", logging.UserID(custom.BroadcasterID), slog.Int64("id", custom.ID), logging.Error(err))
+		}
+	}
+}
+
+// allowed checks allowlist and scopes of requester, which is broadcaster or linked bot
+func allowed(custom database.CustomSubscription, broadcaster database.User, bots []database.User) bool {
+	definition, ok := eventsub.LookupCustom(custom.Type, custom.Version)
+	if !ok {
+		return false
+	}
+	for _, user := range append([]database.User{broadcaster}, bots...) {
+		if user.ID == custom.RequesterID {
+			return definition.Authorized(user.Scopes)
+		}
+	}
+	return false
+}
+
+// unsubscribe deletes subscription at Twitch, returns false when it failed
+func unsubscribe(userId string, item subscriptions.Data) bool {
+	accessToken, err := token.Access()
+	if err == nil {
+		err = subscriptions.DeleteSubscription(item.ID, accessToken)
+	}
+	if err != nil {
+		slog.Error("Error deleting subscription", logging.UserID(userId), logging.SubscriptionID(item.ID), logging.Error(err))
+		return false
+	}
+	return true
+}
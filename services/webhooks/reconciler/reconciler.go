@@ -0,0 +1,217 @@
+// Package reconciler keeps Twitch EventSub subscriptions in sync with stored users
+package reconciler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"services/shared/logging"
+	"services/webhooks/config"
+	"services/webhooks/database"
+	"services/webhooks/debug"
+	"services/webhooks/eventsub"
+	"services/webhooks/metrics"
+	"services/webhooks/subscriptions"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+var callbacks config.Callbacks
+
+func Configure(cfg config.Callbacks) {
+	callbacks = cfg
+}
+
+var sem = semaphore.NewWeighted(int64(10))
+var ctx = context.Background()
+
+// running guards single reconciliation pass, passes share subscription lists
+var running sync.Mutex
+
+// Loop runs full reconciliation and then checks updated users every minute
+func Loop() {
+	Run(false)
+	for {
+		time.Sleep(time.Minute)
+		Run(true)
+	}
+}
+
+// Run synchronizes subscriptions of all users, or only of users marked as updated
+func Run(updatedOnly bool) {
+	running.Lock()
+	defer running.Unlock()
+
+	if subscriptions.UsesWebSocket() {
+		// WebSocket sessions subscribe on their own, they pick up updated users
+		retireTransports()
+		return
+	}
+
+	start := time.Now()
+
+	subscriptions.List()
+	if !updatedOnly {
+		subscriptions.CleanDuplicatedSubscriptions()
+		subscriptions.List()
+	}
+
+	slog.Info("Currently subscribed to events", slog.Int("count", len(subscriptions.SubscriptionList)))
+
+	// remove all subscriptions for users
+	newSubscription = []NewSubscription{}
+
+	users, err := database.DB.ListUsers(ctx, updatedOnly)
+	if err != nil {
+		slog.Error("Error loading users", slog.Bool("updated_only", updatedOnly), logging.Error(err))
+		os.Exit(1)
+	}
+	if updatedOnly {
+		if err := database.DB.ResetUpdated(ctx); err != nil {
+			slog.Error("Error resetting updated users", logging.Error(err))
+		}
+	}
+
+	for _, user := range users {
+		userId := user.ID
+
+		if !debug.Allowed(userId) {
+			continue
+		}
+		bots, err := database.DB.LinkedBots(ctx, userId)
+		if err != nil {
+			slog.Error("Error loading linked bots", logging.UserID(userId), logging.Error(err))
+		}
+		migrations, err := database.DB.ListVersionMigrations(ctx, userId)
+		if err != nil {
+			// starting migrations again is harmless, but nothing is deleted
+			slog.Error("Error loading subscription migrations", logging.UserID(userId), logging.Error(err))
+		}
+		for _, definition := range eventsub.Catalog {
+			if len(definition.Supersedes) > 0 {
+				migrateVersions(userId, definition, migrations)
+			}
+			moderatorIds := moderators(definition, user, bots)
+			if len(moderatorIds) == 0 {
+				continue
+			}
+			if definition.RewardFiltered {
+				syncRewards(userId, definition)
+				continue
+			}
+			// subscription created by any of accounts is enough, so events are not doubled
+			if slices.ContainsFunc(moderatorIds, func(moderatorId string) bool {
+				return subscribed(definition.Type, definition.Version, definition.Condition(userId, moderatorId))
+			}) {
+				continue
+			}
+			condition := definition.Condition(userId, moderatorIds[0])
+
+			// not found in list, add to newSubscription
+			slog.Info("User added to new subscription list", logging.UserID(userId), logging.EventType(definition.Type), slog.String("version", definition.Version), slog.String("moderator_id", moderatorIds[0]))
+			newSubscription = append(newSubscription, NewSubscription{
+				userId:    userId,
+				event:     definition.Type,
+				version:   definition.Version,
+				condition: condition,
+			})
+		}
+		syncCustom(user, bots)
+	}
+	if !updatedOnly {
+		cleanCustom()
+	}
+
+	migrateCallbacks()
+
+	// subscribe all users in newSubscription
+	subscribe()
+	metrics.SyncDuration.Observe(time.Since(start).Seconds())
+}
+
+// moderators returns accounts able to create subscription of definition for
+// broadcaster, broadcaster itself goes first, then linked bots
+func moderators(definition eventsub.Definition, broadcaster database.User, bots []database.User) []string {
+	moderatorIds := []string{}
+	if definition.Authorized(broadcaster.Scopes) {
+		moderatorIds = append(moderatorIds, broadcaster.ID)
+	}
+	if !definition.Moderated {
+		return moderatorIds
+	}
+	for _, bot := range bots {
+		if definition.Authorized(bot.Scopes) {
+			moderatorIds = append(moderatorIds, bot.ID)
+		}
+	}
+	return moderatorIds
+}
+
+// subscribed checks if subscription already exists at Twitch
+func subscribed(subscriptionType string, version string, condition map[string]interface{}) bool {
+	_, found := find(subscriptionType, version, condition)
+	return found
+}
+
+// find returns subscription with type, version and condition from subscription list
+func find(subscriptionType string, version string, condition map[string]interface{}) (subscriptions.Data, bool) {
+	// we need to remarshal the condition to objects to compare
+	data, err := json.Marshal(condition)
+	if err != nil {
+		slog.Error("Error marshaling map to JSON", logging.Error(err))
+		return subscriptions.Data{}, false
+	}
+	var defined subscriptions.Condition
+	if err := json.Unmarshal(data, &defined); err != nil {
+		slog.Error("Error unmarshaling", logging.Error(err))
+		return subscriptions.Data{}, false
+	}
+
+	// subscription with previous callback is used only until it is replaced
+	previous, found := subscriptions.Data{}, false
+	for _, item := range subscriptions.SubscriptionList {
+		if item.Type == subscriptionType && item.Version == version && defined.Equal(&item.Condition) {
+			if !subscriptions.Previous(item) {
+				return item, true
+			}
+			previous, found = item, true
+		}
+	}
+	return previous, found
+}
+
+type NewSubscription struct {
+	userId    string
+	event     string
+	version   string
+	condition interface{}
+	// customId is id of custom subscription, 0 for catalog
+	customId int64
+}
+
+var newSubscription []NewSubscription
+
+func subscribe() {
+	var wg sync.WaitGroup
+
+	slog.Info("Subscribing users to new events", slog.Int("count", len(newSubscription)))
+
+	for len(newSubscription) > 0 {
+		// slog.Debug("Subscribing user", logging.UserID(val.userId), logging.EventType(val.event))
+		val := newSubscription[len(newSubscription)-1]
+		// Update the slice to remove the last element
+		newSubscription = newSubscription[:len(newSubscription)-1]
+		sem.Acquire(ctx, 1)
+		go func() {
+			// slog.Debug("Releasing semaphore", logging.UserID(val.userId), logging.EventType(val.event))
+			time.Sleep(time.Second / 2)
+			sem.Release(1)
+		}()
+		wg.Add(1)
+		go subscriptions.Create(&wg, val.userId, val.event, val.version, val.condition, val.customId)
+	}
+}
@@ -0,0 +1,57 @@
+package reconciler
+
+import (
+	"log/slog"
+	"services/shared/logging"
+	"services/webhooks/database"
+	"services/webhooks/eventsub"
+	"services/webhooks/subscriptions"
+	"slices"
+)
+
+// syncRewards subscribes to redemptions of filtered rewards, subscriptions not
+// matching filters are deleted after all wanted ones are enabled, so switching
+// between broadcaster wide and filtered subscriptions does not lose events.
+// Filters of rewards Twitch refuses or reports removed are pruned, so deleted
+// reward does not hold the switch forever.
+func syncRewards(userId string, definition eventsub.Definition) {
+	rewardIds, err := database.DB.RewardFilters(ctx, userId)
+	if err != nil {
+		slog.Error("Error loading reward filters", logging.UserID(userId), logging.Error(err))
+		return
+	}
+
+	wanted := []string{}
+	ready := true
+	for _, condition := range definition.RewardConditions(userId, rewardIds) {
+		item, found := find(definition.Type, definition.Version, condition)
+		if found {
+			wanted = append(wanted, item.ID)
+			ready = ready && item.Status == subscriptions.Enabled
+			continue
+		}
+		ready = false
+		slog.Info("User added to new subscription list", logging.UserID(userId), logging.EventType(definition.Type), slog.String("version", definition.Version), slog.Any("reward_id", condition["reward_id"]))
+		newSubscription = append(newSubscription, NewSubscription{
+			userId:    userId,
+			event:     definition.Type,
+			version:   definition.Version,
+			condition: condition,
+		})
+	}
+	if !ready {
+		// check again in next pass, stale subscriptions are deleted then
+		if err := database.DB.MarkUpdated(ctx, userId); err != nil {
+			slog.Error("Error marking user updated", logging.UserID(userId), logging.Error(err))
+		}
+		return
+	}
+
+	for _, item := range subscriptions.SubscriptionList {
+		if item.Type != definition.Type || item.Version != definition.Version || item.Condition.Owner() != userId || slices.Contains(wanted, item.ID) {
+			continue
+		}
+		slog.Info("Deleting subscription not matching reward filters", logging.UserID(userId), logging.SubscriptionID(item.ID), logging.EventType(item.Type))
+		unsubscribe(userId, item)
+	}
+}
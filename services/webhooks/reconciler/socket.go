@@ -0,0 +1,41 @@
+package reconciler
+
+import (
+	"log/slog"
+	"services/shared/logging"
+	"services/webhooks/socket"
+	"services/webhooks/subscriptions"
+)
+
+// retireTransports deletes webhook and conduit subscriptions of this service
+// after switching to WebSocket transport. Each one is deleted once session of
+// leader has the same subscription, so events are neither lost nor delivered
+// twice. Subscriptions of users without session keep delivering.
+func retireTransports() {
+	if !socket.Leading() {
+		return
+	}
+	if err := subscriptions.LoadStoredConduit(); err != nil {
+		slog.Error("Error loading stored conduit", logging.Error(err))
+	}
+
+	subscriptions.SubscriptionList = nil
+	subscriptions.List()
+	deleted, waiting := 0, 0
+	for _, item := range subscriptions.SubscriptionList {
+		if !subscriptions.Previous(item) {
+			continue
+		}
+		userId := item.Condition.Owner()
+		if !socket.Covers(item.Type, item.Version, item.Condition) || !unsubscribe(userId, item) {
+			waiting++
+			continue
+		}
+		slog.Info("Subscription replaced by WebSocket session deleted", logging.UserID(userId), logging.SubscriptionID(item.ID), logging.EventType(item.Type), slog.String("method", string(item.Transport.Method)))
+		deleted++
+	}
+	subscriptions.SubscriptionList = nil
+	if deleted > 0 || waiting > 0 {
+		slog.Info("Moving subscriptions to WebSocket sessions", slog.Int("deleted", deleted), slog.Int("waiting", waiting))
+	}
+}
@@ -0,0 +1,74 @@
+package reconciler
+
+import (
+	"log/slog"
+	"services/shared/logging"
+	"services/webhooks/database"
+	"services/webhooks/eventsub"
+	"services/webhooks/subscriptions"
+	"slices"
+)
+
+// superseded returns subscriptions of user with version superseded by definition
+func superseded(userId string, definition eventsub.Definition) []subscriptions.Data {
+	list := []subscriptions.Data{}
+	for _, item := range subscriptions.SubscriptionList {
+		if item.Type == definition.Type && slices.Contains(definition.Supersedes, item.Version) && item.Condition.Owner() == userId {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// enabled checks if user has verified subscription of definition
+func enabled(userId string, definition eventsub.Definition) bool {
+	return slices.ContainsFunc(subscriptions.SubscriptionList, func(item subscriptions.Data) bool {
+		return item.Type == definition.Type && item.Version == definition.Version &&
+			item.Condition.Owner() == userId && item.Status == subscriptions.Enabled
+	})
+}
+
+// migrateVersions replaces subscriptions of superseded versions, new version is
+// created by regular sync and old subscription is deleted once it is verified,
+// so user does not miss events in between
+func migrateVersions(userId string, definition eventsub.Definition, migrations []database.VersionMigration) {
+	for _, old := range superseded(userId, definition) {
+		index := slices.IndexFunc(migrations, func(migration database.VersionMigration) bool {
+			return migration.OldSubscriptionID == old.ID
+		})
+		if index == -1 {
+			slog.Info("Migrating subscription to new version", logging.UserID(userId), logging.EventType(old.Type), slog.String("from", old.Version), slog.String("to", definition.Version))
+			err := database.DB.StartVersionMigration(ctx, database.VersionMigration{
+				BroadcasterID:     userId,
+				Type:              definition.Type,
+				FromVersion:       old.Version,
+				ToVersion:         definition.Version,
+				OldSubscriptionID: old.ID,
+			})
+			if err != nil {
+				slog.Error("Error starting subscription migration", logging.UserID(userId), logging.EventType(old.Type), logging.Error(err))
+			} else if enabled(userId, definition) {
+				// new version is verified already, finish in next pass
+				database.DB.MarkUpdated(ctx, userId)
+			}
+			continue
+		}
+
+		migration := migrations[index]
+		// verification could be handled by replica before migration was stored
+		if migration.Status == database.MigrationPending && enabled(userId, definition) {
+			migration.Status = database.MigrationVerified
+		}
+		if migration.Status != database.MigrationVerified {
+			continue
+		}
+
+		if !unsubscribe(userId, old) {
+			continue
+		}
+		if err := database.DB.SetVersionMigrationStatus(ctx, migration.ID, database.MigrationCompleted); err != nil {
+			slog.Error("Error completing subscription migration", logging.UserID(userId), logging.SubscriptionID(old.ID), logging.Error(err))
+		}
+		slog.Info("Superseded subscription deleted", logging.UserID(userId), logging.SubscriptionID(old.ID), logging.EventType(old.Type), slog.String("version", old.Version))
+	}
+}
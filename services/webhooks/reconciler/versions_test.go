@@ -0,0 +1,124 @@
+package reconciler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"services/webhooks/config"
+	"services/webhooks/database"
+	"services/webhooks/eventsub"
+	"services/webhooks/subscriptions"
+	"services/webhooks/token"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestMigrateVersions(t *testing.T) {
+	// mocked Twitch records deleted subscriptions
+	var mutex sync.Mutex
+	deleted := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/oauth2/token":
+			w.Write([]byte(`{"access_token":"token","expires_in":3600}`))
+		case r.Method == http.MethodDelete && r.URL.Path == "/helix/eventsub/subscriptions":
+			mutex.Lock()
+			deleted = append(deleted, r.URL.Query().Get("id"))
+			mutex.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	twitch := config.Twitch{ClientID: "client", ClientSecret: "secret", HelixURL: server.URL + "/helix", AuthURL: server.URL + "/oauth2"}
+	token.Configure(twitch)
+	subscriptions.Configure(twitch)
+	defer func() { subscriptions.SubscriptionList = nil }()
+
+	definition, _ := eventsub.Lookup("channel.follow", "2")
+	owner := "1"
+	old := subscriptions.Data{ID: "old", Status: subscriptions.Enabled, Type: "channel.follow", Version: "1", Condition: subscriptions.Condition{BroadcasterUserID: &owner}}
+	current := func(status subscriptions.Status) subscriptions.Data {
+		return subscriptions.Data{ID: "new", Status: status, Type: "channel.follow", Version: "2", Condition: subscriptions.Condition{BroadcasterUserID: &owner, ModeratorUserID: &owner}}
+	}
+
+	tests := []struct {
+		name string
+		list []subscriptions.Data
+		// stored is status of migration before the pass, empty when it was not started
+		stored      database.MigrationStatus
+		wantStatus  database.MigrationStatus
+		wantDeleted bool
+	}{
+		{
+			name:       "migration is started",
+			list:       []subscriptions.Data{old},
+			wantStatus: database.MigrationPending,
+		},
+		{
+			name:       "new version waits for verification",
+			list:       []subscriptions.Data{old, current(subscriptions.VerificationPending)},
+			stored:     database.MigrationPending,
+			wantStatus: database.MigrationPending,
+		},
+		{
+			name:        "new version verified before migration was stored",
+			list:        []subscriptions.Data{old, current(subscriptions.Enabled)},
+			stored:      database.MigrationPending,
+			wantStatus:  database.MigrationCompleted,
+			wantDeleted: true,
+		},
+		{
+			name:        "verified migration deletes old version",
+			list:        []subscriptions.Data{old},
+			stored:      database.MigrationVerified,
+			wantStatus:  database.MigrationCompleted,
+			wantDeleted: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := database.NewSQLite(":memory:")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+			if err := store.Migrate(ctx); err != nil {
+				t.Fatal(err)
+			}
+			database.DB = store
+			subscriptions.SubscriptionList = tt.list
+			deleted = []string{}
+
+			if tt.stored != "" {
+				err := store.StartVersionMigration(ctx, database.VersionMigration{BroadcasterID: owner, Type: "channel.follow", FromVersion: "1", ToVersion: "2", OldSubscriptionID: old.ID})
+				if err != nil {
+					t.Fatal(err)
+				}
+				migrations, _ := store.ListVersionMigrations(ctx, owner)
+				if err := store.SetVersionMigrationStatus(ctx, migrations[0].ID, tt.stored); err != nil {
+					t.Fatal(err)
+				}
+			}
+			migrations, err := store.ListVersionMigrations(ctx, owner)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			migrateVersions(owner, definition, migrations)
+
+			migrations, err = store.ListVersionMigrations(ctx, owner)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(migrations) != 1 || migrations[0].Status != tt.wantStatus {
+				t.Errorf("migrations = %+v, want one with status %q", migrations, tt.wantStatus)
+			}
+			if got := slices.Contains(deleted, old.ID); got != tt.wantDeleted {
+				t.Errorf("old subscription deleted = %v, want %v", got, tt.wantDeleted)
+			}
+		})
+	}
+}
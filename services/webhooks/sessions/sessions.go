@@ -0,0 +1,69 @@
+// Package sessions records stream sessions of broadcasters from stream.online,
+// stream.offline and channel.update events
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"services/webhooks/database"
+	"services/webhooks/eventsub"
+	"services/webhooks/state"
+)
+
+// Tracks tells if events of type change sessions
+func Tracks(eventType string) bool {
+	return eventType == "stream.online" || eventType == "stream.offline" || eventType == "channel.update"
+}
+
+// Record applies event to sessions of its broadcaster
+func Record(ctx context.Context, envelope *eventsub.Envelope) error {
+	switch event := envelope.Event.(type) {
+	case *eventsub.StreamOnline:
+		session := database.Session{
+			BroadcasterID: envelope.BroadcasterID,
+			StreamID:      event.ID,
+			StartedAt:     event.StartedAt,
+		}
+		if session.StartedAt.IsZero() {
+			session.StartedAt = envelope.OccurredAt
+		}
+		// stream.online does not contain title and category, take them from last channel.update
+		if channel, err := lastChannelUpdate(ctx, envelope.BroadcasterID); err != nil {
+			return err
+		} else if channel != nil {
+			session.Title = channel.Title
+			session.CategoryID = channel.CategoryID
+			session.CategoryName = channel.CategoryName
+		}
+		latest, err := database.DB.ListSessions(ctx, envelope.BroadcasterID, 1)
+		if err != nil {
+			return err
+		}
+		if len(latest) > 0 && latest[0].StreamID == event.ID {
+			// already recorded
+			return nil
+		}
+		// previous stream may be missing offline event
+		if err := database.DB.EndSession(ctx, envelope.BroadcasterID, session.StartedAt); err != nil {
+			return err
+		}
+		return database.DB.StartSession(ctx, session)
+	case *eventsub.StreamOffline:
+		return database.DB.EndSession(ctx, envelope.BroadcasterID, envelope.OccurredAt)
+	case *eventsub.ChannelUpdate:
+		return database.DB.UpdateSessionInfo(ctx, envelope.BroadcasterID, event.Title, event.CategoryID, event.CategoryName)
+	}
+	return nil
+}
+
+func lastChannelUpdate(ctx context.Context, broadcasterId string) (*eventsub.ChannelUpdate, error) {
+	snapshot, err := state.Get(ctx, broadcasterId)
+	if err != nil || snapshot.Channel == nil {
+		return nil, err
+	}
+	channel := &eventsub.ChannelUpdate{}
+	if err := json.Unmarshal(snapshot.Channel.Event, channel); err != nil {
+		return nil, err
+	}
+	return channel, nil
+}
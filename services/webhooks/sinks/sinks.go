@@ -0,0 +1,211 @@
+// Package sinks copies received events to configured outputs, e.g. for
+// archiving or analytics. Sinks are fed in background, a slow or failing sink
+// drops its events and never delays callback responses or the user queue.
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"services/shared/logging"
+	"services/webhooks/config"
+	"services/webhooks/eventsub"
+	"services/webhooks/metrics"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink receives normalized events, writes of one sink are never concurrent
+type Sink interface {
+	Write(event Event) error
+	Close() error
+}
+
+// Event is notification in form shared by all sinks
+type Event struct {
+	ID            string    `json:"id"`
+	Type          string    `json:"type"`
+	Version       string    `json:"version"`
+	BroadcasterID string    `json:"broadcaster_id"`
+	OccurredAt    time.Time `json:"occurred_at"`
+	Event         any       `json:"event"`
+}
+
+// filter limits events sent to sink, empty lists match everything
+type filter struct {
+	types        []string
+	broadcasters []string
+}
+
+// matches tells if event passes filter, types ending with * match by prefix
+func (f filter) matches(event Event) bool {
+	if len(f.broadcasters) > 0 && !slices.Contains(f.broadcasters, event.BroadcasterID) {
+		return false
+	}
+	if len(f.types) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(f.types, func(pattern string) bool {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			return strings.HasPrefix(event.Type, prefix)
+		}
+		return pattern == event.Type
+	})
+}
+
+// worker feeds one sink from its buffer
+type worker struct {
+	// name identifies sink in logs and metrics without its target
+	name   string
+	sink   Sink
+	filter filter
+	events chan Event
+	// abort stops worker before buffer is drained
+	abort chan struct{}
+	done  chan struct{}
+}
+
+func (w *worker) run() {
+	defer close(w.done)
+	for event := range w.events {
+		select {
+		case <-w.abort:
+			return
+		default:
+		}
+		if err := w.sink.Write(event); err != nil {
+			slog.Warn("Error writing event to sink", slog.String("sink", w.name), logging.EventType(event.Type), logging.Error(err))
+			metrics.SinkEvents.WithLabelValues(w.name, "failed").Inc()
+			continue
+		}
+		metrics.SinkEvents.WithLabelValues(w.name, "written").Inc()
+	}
+}
+
+var mutex sync.RWMutex
+var workers []*worker
+
+// Configure creates sinks of cfg, entries are kind[:target][;types=a|b][;broadcasters=1|2]
+func Configure(cfg config.Sinks) error {
+	configured := []*worker{}
+	for i, entry := range cfg.Sinks {
+		spec, options, _ := strings.Cut(entry, ";")
+		kind, target, _ := strings.Cut(spec, ":")
+
+		var f filter
+		for _, option := range strings.Split(options, ";") {
+			if option == "" {
+				continue
+			}
+			key, values, _ := strings.Cut(option, "=")
+			switch key {
+			case "types":
+				f.types = strings.Split(values, "|")
+			case "broadcasters":
+				f.broadcasters = strings.Split(values, "|")
+			default:
+				return fmt.Errorf("invalid sink %q, unknown option %s", entry, key)
+			}
+		}
+
+		var sink Sink
+		var err error
+		switch kind {
+		case "stdout":
+			sink = newStdout()
+		case "file":
+			sink, err = newFile(target, cfg.FileMaxSize, cfg.FileKeep)
+		case "http":
+			sink, err = newHTTP(target, cfg.HTTPTimeout)
+		default:
+			err = fmt.Errorf("unknown kind %q, expected stdout, file or http", kind)
+		}
+		if err != nil {
+			for _, w := range configured {
+				w.sink.Close()
+			}
+			return fmt.Errorf("invalid sink %q: %w", entry, err)
+		}
+		configured = append(configured, &worker{
+			name:   fmt.Sprintf("%s#%d", kind, i),
+			sink:   sink,
+			filter: f,
+			events: make(chan Event, cfg.Buffer),
+			abort:  make(chan struct{}),
+			done:   make(chan struct{}),
+		})
+	}
+
+	for _, w := range configured {
+		go w.run()
+	}
+	mutex.Lock()
+	workers = configured
+	mutex.Unlock()
+	return nil
+}
+
+// Publish hands event to sinks matching it, never blocks
+func Publish(envelope *eventsub.Envelope) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	if len(workers) == 0 {
+		return
+	}
+
+	event := Event{
+		ID:            envelope.ID,
+		Type:          envelope.Type,
+		Version:       envelope.Version,
+		BroadcasterID: envelope.BroadcasterID,
+		OccurredAt:    envelope.OccurredAt,
+		Event:         envelope.Event,
+	}
+	for _, w := range workers {
+		if !w.filter.matches(event) {
+			continue
+		}
+		select {
+		case w.events <- event:
+		default:
+			metrics.SinkEvents.WithLabelValues(w.name, "dropped").Inc()
+		}
+	}
+}
+
+// WritesStdout tells if one of sinks of cfg writes events to standard output,
+// logs have to go elsewhere then
+func WritesStdout(cfg config.Sinks) bool {
+	return slices.ContainsFunc(cfg.Sinks, func(entry string) bool {
+		spec, _, _ := strings.Cut(entry, ";")
+		kind, _, _ := strings.Cut(spec, ":")
+		return kind == "stdout"
+	})
+}
+
+// Close writes buffered events and closes sinks, sinks still writing when ctx
+// is done are abandoned and their buffered events dropped
+func Close(ctx context.Context) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, w := range workers {
+		close(w.events)
+	}
+	for _, w := range workers {
+		select {
+		case <-w.done:
+		case <-ctx.Done():
+			close(w.abort)
+			dropped := len(w.events)
+			slog.Warn("Sink did not finish before shutdown, buffered events are dropped", slog.String("sink", w.name), slog.Int("dropped", dropped))
+			metrics.SinkEvents.WithLabelValues(w.name, "dropped").Add(float64(dropped))
+			continue
+		}
+		if err := w.sink.Close(); err != nil {
+			slog.Error("Error closing sink", slog.String("sink", w.name), logging.Error(err))
+		}
+	}
+	workers = nil
+}
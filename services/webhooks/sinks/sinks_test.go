@@ -0,0 +1,139 @@
+package sinks
+
+import (
+	"context"
+	"services/webhooks/config"
+	"testing"
+	"time"
+)
+
+func TestFilterMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter filter
+		event  Event
+		want   bool
+	}{
+		{
+			name:  "empty filter",
+			event: Event{Type: "channel.follow", BroadcasterID: "1"},
+			want:  true,
+		},
+		{
+			name:   "exact type",
+			filter: filter{types: []string{"channel.follow"}},
+			event:  Event{Type: "channel.follow"},
+			want:   true,
+		},
+		{
+			name:   "other type",
+			filter: filter{types: []string{"channel.follow"}},
+			event:  Event{Type: "channel.subscribe"},
+			want:   false,
+		},
+		{
+			name:   "type is not prefix without wildcard",
+			filter: filter{types: []string{"channel.subscribe"}},
+			event:  Event{Type: "channel.subscription.gift"},
+			want:   false,
+		},
+		{
+			name:   "wildcard type",
+			filter: filter{types: []string{"stream.online", "channel.subscription.*"}},
+			event:  Event{Type: "channel.subscription.gift"},
+			want:   true,
+		},
+		{
+			name:   "wildcard does not match parent",
+			filter: filter{types: []string{"channel.subscription.*"}},
+			event:  Event{Type: "channel.subscribe"},
+			want:   false,
+		},
+		{
+			name:   "listed broadcaster",
+			filter: filter{broadcasters: []string{"1", "2"}},
+			event:  Event{Type: "channel.follow", BroadcasterID: "2"},
+			want:   true,
+		},
+		{
+			name:   "other broadcaster",
+			filter: filter{broadcasters: []string{"1", "2"}},
+			event:  Event{Type: "channel.follow", BroadcasterID: "3"},
+			want:   false,
+		},
+		{
+			name:   "both must match",
+			filter: filter{types: []string{"channel.follow"}, broadcasters: []string{"1"}},
+			event:  Event{Type: "channel.raid", BroadcasterID: "1"},
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.matches(tt.event); got != tt.want {
+				t.Errorf("matches(%+v) = %v, want %v", tt.event, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWritesStdout(t *testing.T) {
+	tests := []struct {
+		sinks []string
+		want  bool
+	}{
+		{sinks: nil, want: false},
+		{sinks: []string{"file:/var/log/events.ndjson"}, want: false},
+		{sinks: []string{"stdout"}, want: true},
+		{sinks: []string{"http:https://example.com/events", "stdout;types=stream.*"}, want: true},
+	}
+	for _, tt := range tests {
+		if got := WritesStdout(config.Sinks{Sinks: tt.sinks}); got != tt.want {
+			t.Errorf("WritesStdout(%v) = %v, want %v", tt.sinks, got, tt.want)
+		}
+	}
+}
+
+// blockingSink never finishes writing until released
+type blockingSink struct {
+	release chan struct{}
+}
+
+func (s blockingSink) Write(event Event) error {
+	<-s.release
+	return nil
+}
+
+func (s blockingSink) Close() error {
+	return nil
+}
+
+func TestCloseBoundedByContext(t *testing.T) {
+	sink := blockingSink{release: make(chan struct{})}
+	defer close(sink.release)
+	w := &worker{
+		name:   "blocking",
+		sink:   sink,
+		events: make(chan Event, 10),
+		abort:  make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	for i := 0; i < 3; i++ {
+		w.events <- Event{Type: "channel.follow"}
+	}
+	workers = []*worker{w}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	closed := make(chan struct{})
+	go func() {
+		Close(ctx)
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close waited for sink after shutdown context was done")
+	}
+}
@@ -0,0 +1,24 @@
+package sinks
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// stdout writes events as NDJSON to standard output, logs are written to
+// standard error when it is used, see WritesStdout
+type stdout struct {
+	encoder *json.Encoder
+}
+
+func newStdout() *stdout {
+	return &stdout{json.NewEncoder(os.Stdout)}
+}
+
+func (s *stdout) Write(event Event) error {
+	return s.encoder.Encode(event)
+}
+
+func (s *stdout) Close() error {
+	return nil
+}
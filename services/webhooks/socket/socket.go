@@ -0,0 +1,490 @@
+// Package socket receives EventSub notifications over WebSocket sessions opened
+// with access tokens of users, so relay works without public URL or tunnel.
+// See https://dev.twitch.tv/docs/eventsub/handling-websocket-events/
+package socket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"services/shared/logging"
+	"services/webhooks/config"
+	"services/webhooks/database"
+	"services/webhooks/debug"
+	"services/webhooks/eventsub"
+	"services/webhooks/handler"
+	"services/webhooks/metrics"
+	"services/webhooks/subscriptions"
+	"services/webhooks/token"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxRetry is longest wait between connection attempts
+const maxRetry = 2 * time.Minute
+
+// validateInterval is how often tokens of open sessions are validated, Twitch
+// requires it hourly
+const validateInterval = time.Hour
+
+var server string
+var twitch config.Twitch
+var ctx = context.Background()
+
+var mutex sync.Mutex
+var sessions = map[string]*session{}
+
+func Configure(cfg config.Socket, tw config.Twitch) {
+	server = cfg.URL
+	twitch = tw
+}
+
+type message struct {
+	Metadata metadata        `json:"metadata"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+type metadata struct {
+	MessageID           string `json:"message_id"`
+	MessageType         string `json:"message_type"`
+	MessageTimestamp    string `json:"message_timestamp"`
+	SubscriptionType    string `json:"subscription_type"`
+	SubscriptionVersion string `json:"subscription_version"`
+}
+
+// header returns metadata as headers of webhook callback
+func (m metadata) header() http.Header {
+	header := http.Header{}
+	header.Set("Twitch-Eventsub-Message-Id", m.MessageID)
+	header.Set("Twitch-Eventsub-Message-Timestamp", m.MessageTimestamp)
+	header.Set("Twitch-Eventsub-Message-Type", m.MessageType)
+	header.Set("Twitch-Eventsub-Subscription-Type", m.SubscriptionType)
+	header.Set("Twitch-Eventsub-Subscription-Version", m.SubscriptionVersion)
+	return header
+}
+
+// welcome is payload of session_welcome and session_reconnect messages
+type welcome struct {
+	Session struct {
+		ID                      string `json:"id"`
+		KeepaliveTimeoutSeconds int    `json:"keepalive_timeout_seconds"`
+		ReconnectURL            string `json:"reconnect_url"`
+	} `json:"session"`
+}
+
+// timeout is how long we wait for any message before connection is considered lost
+func (w welcome) timeout() time.Duration {
+	seconds := w.Session.KeepaliveTimeoutSeconds
+	if seconds == 0 {
+		seconds = 10
+	}
+	return time.Duration(seconds)*time.Second + 5*time.Second
+}
+
+// leading tells if this replica is leader which opens sessions
+var leading atomic.Bool
+
+// Loop opens session for every user with stored token, closes sessions of
+// users without token and resubscribes users marked as updated. Only leader
+// replica opens sessions, so events are not received once per replica.
+func Loop() {
+	for {
+		lead()
+		if Leading() {
+			refresh()
+		}
+		time.Sleep(30 * time.Second)
+	}
+}
+
+// Leading tells if sessions are opened by this replica
+func Leading() bool {
+	return leading.Load()
+}
+
+// lead keeps replica registered, sessions are closed when another replica
+// becomes leader
+func lead() {
+	isLeader, err := subscriptions.Leader()
+	if err != nil {
+		// replica keeps its role until database is back
+		slog.Error("Error checking WebSocket leader", logging.Error(err))
+		return
+	}
+	if leading.Swap(isLeader) != isLeader {
+		slog.Info("WebSocket sessions leadership changed", slog.Bool("leader", isLeader))
+	}
+	if !isLeader {
+		Shutdown()
+	}
+}
+
+// Shutdown closes all sessions
+func Shutdown() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	for userId, s := range sessions {
+		s.close()
+		delete(sessions, userId)
+	}
+}
+
+func refresh() {
+	tokens, err := token.Users(ctx)
+	if err != nil {
+		slog.Error("Error loading user tokens", logging.Error(err))
+		return
+	}
+	updated, err := database.DB.ListUsers(ctx, true)
+	if err != nil {
+		slog.Error("Error loading users", slog.Bool("updated_only", true), logging.Error(err))
+		return
+	}
+	if err := database.DB.ResetUpdated(ctx); err != nil {
+		slog.Error("Error resetting updated users", logging.Error(err))
+	}
+
+	// bots subscribe to moderator events of broadcasters linked to them
+	resubscribe := map[string]bool{}
+	for _, user := range updated {
+		resubscribe[user.ID] = true
+		bots, err := database.DB.LinkedBots(ctx, user.ID)
+		if err != nil {
+			slog.Error("Error loading linked bots", logging.UserID(user.ID), logging.Error(err))
+		}
+		for _, bot := range bots {
+			resubscribe[bot.ID] = true
+		}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	for userId, s := range sessions {
+		if _, found := tokens[userId]; !found || !debug.Allowed(userId) {
+			slog.Info("Closing WebSocket session, user has no token", logging.UserID(userId))
+			s.close()
+			delete(sessions, userId)
+		}
+	}
+	for userId, user := range tokens {
+		if !debug.Allowed(userId) {
+			continue
+		}
+		s, found := sessions[userId]
+		if !found || s.isClosed() {
+			s = &session{userId: userId, token: user}
+			sessions[userId] = s
+			go s.run()
+			continue
+		}
+		// new token may have new scopes
+		if s.setToken(user) || resubscribe[userId] {
+			go s.subscribe()
+		}
+		if s.validationDue() {
+			go s.validate()
+		}
+	}
+}
+
+// Covers tells if open session receives events of subscription with type,
+// version and condition, old transports are deleted only once they are covered
+func Covers(subscriptionType string, version string, condition eventsub.Condition) bool {
+	key := coverage(subscriptionType, version, condition)
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, s := range sessions {
+		if s.covers(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// coverage is key of subscription created in session, missing and empty
+// condition fields are the same like in Condition.Equal
+func coverage(subscriptionType string, version string, condition eventsub.Condition) string {
+	key := subscriptionType + "@" + version
+	for _, field := range []*string{condition.BroadcasterUserID, condition.RewardID, condition.FromBroadcasterUserID,
+		condition.ToBroadcasterUserID, condition.ModeratorUserID, condition.UserId} {
+		key += ":"
+		if field != nil {
+			key += *field
+		}
+	}
+	return key
+}
+
+// running tells if user has open session
+func running(userId string) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	s, found := sessions[userId]
+	return found && !s.isClosed()
+}
+
+// session is EventSub WebSocket connection of one user, subscriptions created
+// in it are removed by Twitch when connection is lost
+type session struct {
+	userId string
+
+	mutex sync.Mutex
+	token token.User
+	// validatedAt is when token was known to be valid
+	validatedAt time.Time
+	id          string
+	conn        *websocket.Conn
+	closed      bool
+	// custom are Twitch ids of created custom subscriptions by their stored id
+	custom map[int64]string
+	// covered are coverage keys of subscriptions created in session
+	covered map[string]bool
+
+	subscribing sync.Mutex
+}
+
+// setToken replaces token of session, new token was validated by Twitch when
+// it was stored
+func (s *session) setToken(user token.User) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	changed := s.token.Access != user.Access
+	if changed {
+		s.validatedAt = time.Now()
+	}
+	s.token = user
+	return changed
+}
+
+// current returns token and id of session, id is empty until session is welcomed
+func (s *session) current() (token.User, string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.token, s.id
+}
+
+func (s *session) validationDue() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return time.Since(s.validatedAt) >= validateInterval
+}
+
+// validate checks token of session, invalid one is renewed
+func (s *session) validate() {
+	user, _ := s.current()
+	err := token.Validate(ctx, user.Access)
+	if errors.Is(err, token.ErrInvalid) {
+		s.renew(user)
+		return
+	}
+	if err != nil {
+		slog.Warn("Error validating user token", logging.UserID(s.userId), logging.Error(err))
+		return
+	}
+	s.mutex.Lock()
+	s.validatedAt = time.Now()
+	s.mutex.Unlock()
+}
+
+// renew replaces rejected token with refreshed one, session is closed and
+// token deleted when it can not be refreshed, bot sends new token when it
+// registers again
+func (s *session) renew(user token.User) {
+	renewed, err := token.Refresh(ctx, s.userId, user)
+	if err == nil {
+		slog.Info("User token refreshed", logging.UserID(s.userId))
+		s.setToken(renewed)
+		go s.subscribe()
+		return
+	}
+	if !errors.Is(err, token.ErrInvalid) {
+		// Twitch may be unavailable, token is checked again later
+		slog.Error("Error refreshing user token", logging.UserID(s.userId), logging.Error(err))
+		return
+	}
+	slog.Warn("User token is not valid, closing WebSocket session", logging.UserID(s.userId))
+	if err := token.DeleteUser(ctx, s.userId, user); err != nil {
+		slog.Error("Error deleting user token", logging.UserID(s.userId), logging.Error(err))
+	}
+	s.close()
+}
+
+func (s *session) isClosed() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.closed
+}
+
+func (s *session) close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+// attach makes conn current connection of session, fresh connection has no
+// subscriptions, reconnected one keeps them
+func (s *session) attach(conn *websocket.Conn, sessionId string, fresh bool) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conn = conn
+	s.id = sessionId
+	if fresh {
+		s.custom = map[int64]string{}
+		s.covered = map[string]bool{}
+	}
+	return true
+}
+
+// cover records subscription created in current connection of session
+func (s *session) cover(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.covered[key] = true
+}
+
+func (s *session) covers(key string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return !s.closed && s.conn != nil && s.covered[key]
+}
+
+// dial connects to url and waits for session_welcome
+func dial(url string) (*websocket.Conn, welcome, error) {
+	var payload welcome
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, payload, err
+	}
+
+	// Twitch sends welcome right after connecting
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	var msg message
+	if err := conn.ReadJSON(&msg); err != nil {
+		conn.Close()
+		return nil, payload, err
+	}
+	if msg.Metadata.MessageType != "session_welcome" {
+		conn.Close()
+		return nil, payload, fmt.Errorf("expected session_welcome, got %s", msg.Metadata.MessageType)
+	}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		conn.Close()
+		return nil, payload, err
+	}
+	return conn, payload, nil
+}
+
+func (s *session) run() {
+	metrics.WebSocketSessions.Inc()
+	defer metrics.WebSocketSessions.Dec()
+
+	retry := time.Second
+	for !s.isClosed() {
+		conn, payload, err := dial(server)
+		if err != nil {
+			slog.Warn("Error connecting WebSocket session", logging.UserID(s.userId), slog.Duration("retry", retry), logging.Error(err))
+			time.Sleep(retry)
+			retry = min(retry*2, maxRetry)
+			continue
+		}
+		retry = time.Second
+		if !s.attach(conn, payload.Session.ID, true) {
+			conn.Close()
+			return
+		}
+		slog.Info("WebSocket session opened", logging.UserID(s.userId), slog.String("session_id", payload.Session.ID))
+		go s.subscribe()
+
+		// reconnected connection keeps session and its subscriptions
+		for conn != nil {
+			conn, payload = s.read(conn, payload)
+		}
+		if !s.isClosed() {
+			slog.Warn("WebSocket session lost, reconnecting", logging.UserID(s.userId))
+		}
+	}
+}
+
+// reconnected is connection replacing the current one after session_reconnect
+type reconnected struct {
+	conn    *websocket.Conn
+	welcome welcome
+}
+
+// read handles messages until connection is closed, returns connection
+// replacing it after session_reconnect
+func (s *session) read(conn *websocket.Conn, current welcome) (*websocket.Conn, welcome) {
+	defer conn.Close()
+
+	replaced := make(chan reconnected, 1)
+	reconnecting := false
+	for {
+		conn.SetReadDeadline(time.Now().Add(current.timeout()))
+		var msg message
+		if err := conn.ReadJSON(&msg); err != nil {
+			if reconnecting {
+				// new connection closes this one when it is welcomed or failed
+				next := <-replaced
+				if next.conn != nil {
+					return next.conn, next.welcome
+				}
+			}
+			if !s.isClosed() {
+				slog.Warn("Error reading WebSocket session", logging.UserID(s.userId), logging.Error(err))
+			}
+			return nil, welcome{}
+		}
+
+		switch msg.Metadata.MessageType {
+		case "session_keepalive":
+		case "notification":
+			metrics.Callbacks.WithLabelValues(msg.Metadata.MessageType, eventsub.Label(msg.Metadata.SubscriptionType)).Inc()
+			if err := handler.Notification(ctx, msg.Metadata.header(), msg.Payload); err != nil {
+				slog.Error("Error handling WebSocket notification", logging.UserID(s.userId), logging.EventType(msg.Metadata.SubscriptionType), logging.Error(err))
+			}
+		case "revocation":
+			metrics.Callbacks.WithLabelValues(msg.Metadata.MessageType, eventsub.Label(msg.Metadata.SubscriptionType)).Inc()
+			var payload eventsub.Message
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				slog.Error("Error unmarshaling revocation", logging.UserID(s.userId), logging.Error(err))
+				continue
+			}
+			slog.Info("Subscription revoked", logging.UserID(s.userId), logging.EventType(payload.Subscription.Type), logging.SubscriptionID(payload.Subscription.ID))
+			handler.Revocation(ctx, payload)
+		case "session_reconnect":
+			var payload welcome
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil || reconnecting {
+				continue
+			}
+			reconnecting = true
+			slog.Info("WebSocket session is moving", logging.UserID(s.userId), slog.String("session_id", payload.Session.ID))
+			// this connection keeps delivering until the new one is welcomed
+			go func() {
+				next, nextWelcome, err := dial(payload.Session.ReconnectURL)
+				if err == nil && !s.attach(next, nextWelcome.Session.ID, false) {
+					next.Close()
+					next = nil
+				}
+				if err != nil {
+					slog.Warn("Error reconnecting WebSocket session", logging.UserID(s.userId), logging.Error(err))
+				}
+				replaced <- reconnected{next, nextWelcome}
+				conn.Close()
+			}()
+		default:
+			slog.Debug("Unknown WebSocket message", logging.UserID(s.userId), slog.String("message_type", msg.Metadata.MessageType))
+		}
+	}
+}
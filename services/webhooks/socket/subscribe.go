@@ -0,0 +1,264 @@
+package socket
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"services/shared/logging"
+	"services/webhooks/database"
+	"services/webhooks/eventsub"
+	"services/webhooks/metrics"
+	"services/webhooks/subscriptions"
+)
+
+var errUnauthorized = errors.New("user token is not valid")
+
+// request is subscription created in session
+type request struct {
+	Type      string
+	Version   string
+	Condition map[string]interface{}
+	// customId is stored id of custom subscription
+	customId int64
+}
+
+// coverage returns key of request, subscriptions of old transports with the
+// same key are deleted once it is created
+func (r request) coverage() string {
+	var condition eventsub.Condition
+	if data, err := json.Marshal(r.Condition); err == nil {
+		json.Unmarshal(data, &condition)
+	}
+	return coverage(r.Type, r.Version, condition)
+}
+
+// subscribe creates subscriptions of user in current session, Twitch answers
+// already existing ones with conflict
+func (s *session) subscribe() {
+	s.subscribing.Lock()
+	defer s.subscribing.Unlock()
+
+	user, sessionId := s.current()
+	accessToken := user.Access
+	if sessionId == "" || s.isClosed() {
+		return
+	}
+	stored, found, err := database.DB.GetUser(ctx, s.userId)
+	if err != nil {
+		slog.Error("Error loading user", logging.UserID(s.userId), logging.Error(err))
+		return
+	}
+	if !found {
+		return
+	}
+
+	for _, item := range wanted(stored) {
+		id, err := create(accessToken, sessionId, item)
+		if errors.Is(err, errUnauthorized) {
+			s.renew(user)
+			return
+		}
+		if err != nil {
+			slog.Error("Error creating subscription", logging.UserID(s.userId), logging.EventType(item.Type), slog.String("version", item.Version), logging.Error(err))
+			continue
+		}
+		s.cover(item.coverage())
+		if item.customId != 0 && id != "" {
+			s.mutex.Lock()
+			s.custom[item.customId] = id
+			s.mutex.Unlock()
+		}
+	}
+	s.deleteCustom(accessToken)
+}
+
+// wanted returns subscriptions of catalog user is authorized for, moderator
+// subscriptions of linked broadcasters and custom subscriptions requested by user
+func wanted(user database.User) []request {
+	requests := []request{}
+
+	rewardIds, err := database.DB.RewardFilters(ctx, user.ID)
+	if err != nil {
+		slog.Error("Error loading reward filters", logging.UserID(user.ID), logging.Error(err))
+	}
+	for _, definition := range eventsub.Catalog {
+		if !definition.Authorized(user.Scopes) {
+			continue
+		}
+		if definition.RewardFiltered {
+			for _, condition := range definition.RewardConditions(user.ID, rewardIds) {
+				requests = append(requests, request{Type: definition.Type, Version: definition.Version, Condition: condition})
+			}
+			continue
+		}
+		requests = append(requests, request{Type: definition.Type, Version: definition.Version, Condition: definition.Condition(user.ID, user.ID)})
+	}
+
+	broadcasterIds, err := database.DB.BotLinks(ctx, user.ID)
+	if err != nil {
+		slog.Error("Error loading bot links", logging.UserID(user.ID), logging.Error(err))
+	}
+	for _, broadcasterId := range broadcasterIds {
+		broadcaster, found, err := database.DB.GetUser(ctx, broadcasterId)
+		if err != nil {
+			slog.Error("Error loading user", logging.UserID(broadcasterId), logging.Error(err))
+			continue
+		}
+		bots, err := database.DB.LinkedBots(ctx, broadcasterId)
+		if err != nil {
+			slog.Error("Error loading linked bots", logging.UserID(broadcasterId), logging.Error(err))
+			continue
+		}
+		for _, definition := range eventsub.Catalog {
+			if !definition.Moderated {
+				continue
+			}
+			// one session is enough, so events are not doubled
+			if found && definition.Authorized(broadcaster.Scopes) && running(broadcasterId) {
+				continue
+			}
+			if moderator(definition, bots) != user.ID {
+				continue
+			}
+			requests = append(requests, request{Type: definition.Type, Version: definition.Version, Condition: definition.Condition(broadcasterId, user.ID)})
+		}
+	}
+
+	list, err := database.DB.ListCustomSubscriptions(ctx, user.ID)
+	if err != nil {
+		slog.Error("Error loading custom subscriptions", logging.UserID(user.ID), logging.Error(err))
+	}
+	for _, custom := range list {
+		if custom.RequesterID != user.ID || custom.Deleted {
+			continue
+		}
+		definition, ok := eventsub.LookupCustom(custom.Type, custom.Version)
+		if !ok || !definition.Authorized(user.Scopes) {
+			continue
+		}
+		condition := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(custom.Condition), &condition); err != nil {
+			slog.Error("Invalid custom subscription condition", logging.UserID(user.ID), slog.Int64("id", custom.ID), logging.Error(err))
+			continue
+		}
+		requests = append(requests, request{Type: custom.Type, Version: custom.Version, Condition: condition, customId: custom.ID})
+	}
+	return requests
+}
+
+// moderator returns first linked bot with open session authorized for definition
+func moderator(definition eventsub.Definition, bots []database.User) string {
+	for _, bot := range bots {
+		if definition.Authorized(bot.Scopes) && running(bot.ID) {
+			return bot.ID
+		}
+	}
+	return ""
+}
+
+// deleteCustom deletes custom subscriptions created in session which user
+// does not own anymore, because they were deleted or taken over by another
+// requester. Subscriptions not created in this session are gone already.
+func (s *session) deleteCustom(accessToken string) {
+	list, err := database.DB.ListCustomSubscriptions(ctx, s.userId)
+	if err != nil {
+		slog.Error("Error loading custom subscriptions", logging.UserID(s.userId), logging.Error(err))
+		return
+	}
+	owned := map[int64]bool{}
+	for _, custom := range list {
+		if custom.RequesterID == s.userId && !custom.Deleted {
+			owned[custom.ID] = true
+		}
+	}
+
+	s.mutex.Lock()
+	stale := map[int64]string{}
+	for customId, id := range s.custom {
+		if !owned[customId] {
+			stale[customId] = id
+		}
+	}
+	s.mutex.Unlock()
+	for customId, id := range stale {
+		if err := subscriptions.DeleteSubscription(id, accessToken); err != nil {
+			slog.Error("Error deleting subscription", logging.UserID(s.userId), logging.SubscriptionID(id), logging.Error(err))
+			continue
+		}
+		s.mutex.Lock()
+		delete(s.custom, customId)
+		s.mutex.Unlock()
+	}
+
+	for _, custom := range list {
+		if custom.RequesterID != s.userId || !custom.Deleted {
+			continue
+		}
+		s.mutex.Lock()
+		_, pending := s.custom[custom.ID]
+		s.mutex.Unlock()
+		if pending {
+			// deleting at Twitch failed, it is tried again on next refresh
+			continue
+		}
+		if err := database.DB.DeleteCustomSubscription(ctx, custom.ID); err != nil {
+			slog.Error("Error deleting custom subscription", logging.UserID(s.userId), slog.Int64("id", custom.ID), logging.Error(err))
+		}
+	}
+}
+
+// create creates subscription with websocket transport using user token,
+// returns id of created subscription, empty when it already exists
+func create(accessToken string, sessionId string, item request) (string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"type":      item.Type,
+		"version":   item.Version,
+		"condition": item.Condition,
+		"transport": subscriptions.SubscriptionAddTransport{Method: string(subscriptions.WebSocket), SessionID: sessionId},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("POST", twitch.HelixURL+"/eventsub/subscriptions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Client-Id", twitch.ClientID)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	metrics.Helix("eventsub.subscriptions.create", resp, err)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	response, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+		var created subscriptions.Response
+		if err := json.Unmarshal(response, &created); err != nil {
+			return "", err
+		}
+		if len(created.Data) == 0 {
+			return "", nil
+		}
+		return created.Data[0].ID, nil
+	case http.StatusConflict:
+		// already subscribed in this session
+		return "", nil
+	case http.StatusUnauthorized:
+		return "", errUnauthorized
+	}
+	return "", fmt.Errorf("unexpected status %d: %s", resp.StatusCode, response)
+}
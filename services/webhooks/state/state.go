@@ -0,0 +1,172 @@
+// Package state folds EventSub events into snapshot of what is currently
+// going on in the channel, so reconnecting bot does not need to wait for events
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"services/webhooks/database"
+	"services/webhooks/eventsub"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Section is state of one channel feature, Event is the last event applied
+type Section struct {
+	Active    bool            `json:"active"`
+	Type      string          `json:"type"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Event     json.RawMessage `json:"event"`
+}
+
+type State struct {
+	BroadcasterID string   `json:"broadcaster_id"`
+	Channel       *Section `json:"channel"`
+	Poll          *Section `json:"poll"`
+	Prediction    *Section `json:"prediction"`
+	HypeTrain     *Section `json:"hype_train"`
+	// Goals are keyed by goal id, more goals can run at the same time
+	Goals           map[string]*Section `json:"goals"`
+	CharityCampaign *Section            `json:"charity_campaign"`
+	ShieldMode      *Section            `json:"shield_mode"`
+}
+
+// active tells if event type starts or continues feature, types not listed end it
+var active = map[string]bool{
+	"channel.update":                    true,
+	"channel.poll.begin":                true,
+	"channel.poll.progress":             true,
+	"channel.prediction.begin":          true,
+	"channel.prediction.progress":       true,
+	"channel.prediction.lock":           true,
+	"channel.hype_train.begin":          true,
+	"channel.hype_train.progress":       true,
+	"channel.goal.begin":                true,
+	"channel.goal.progress":             true,
+	"channel.charity_campaign.start":    true,
+	"channel.charity_campaign.progress": true,
+	"channel.shield_mode.begin":         true,
+}
+
+// feature returns name of state section event type belongs to, empty if event is not part of state
+func feature(eventType string) string {
+	if eventType == "channel.update" {
+		return "channel"
+	}
+	if eventType == "channel.charity_campaign.donate" {
+		// donations do not change campaign totals
+		return ""
+	}
+	for _, prefix := range []string{"poll", "prediction", "hype_train", "charity_campaign", "shield_mode", "goal"} {
+		if strings.HasPrefix(eventType, "channel."+prefix+".") {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// Tracks tells if events of type are folded into state
+func Tracks(eventType string) bool {
+	return feature(eventType) != ""
+}
+
+// section returns pointer to section event belongs to, nil if event is not part of state
+func (s *State) section(envelope *eventsub.Envelope) **Section {
+	switch feature(envelope.Type) {
+	case "channel":
+		return &s.Channel
+	case "poll":
+		return &s.Poll
+	case "prediction":
+		return &s.Prediction
+	case "hype_train":
+		return &s.HypeTrain
+	case "charity_campaign":
+		return &s.CharityCampaign
+	case "shield_mode":
+		return &s.ShieldMode
+	case "goal":
+		goal, ok := envelope.Event.(*eventsub.ChannelGoal)
+		if !ok {
+			return nil
+		}
+		if s.Goals == nil {
+			s.Goals = map[string]*Section{}
+		}
+		section := s.Goals[goal.ID]
+		return &section
+	}
+	return nil
+}
+
+// endedGoals is number of ended goals kept in state
+const endedGoals = 5
+
+// Apply folds event into state, returns false if event does not change state
+func (s *State) Apply(envelope *eventsub.Envelope) (bool, error) {
+	section := s.section(envelope)
+	if section == nil {
+		return false, nil
+	}
+	// Twitch does not guarantee order, older event must not overwrite newer one
+	if *section != nil && (*section).UpdatedAt.After(envelope.OccurredAt) {
+		return false, nil
+	}
+
+	event, err := json.Marshal(envelope.Event)
+	if err != nil {
+		return false, err
+	}
+	*section = &Section{
+		Active:    active[envelope.Type],
+		Type:      envelope.Type,
+		UpdatedAt: envelope.OccurredAt,
+		Event:     event,
+	}
+
+	if goal, ok := envelope.Event.(*eventsub.ChannelGoal); ok {
+		s.Goals[goal.ID] = *section
+		// keep only running goals and the last ended ones
+		ended := []string{}
+		for id, section := range s.Goals {
+			if !section.Active {
+				ended = append(ended, id)
+			}
+		}
+		slices.SortFunc(ended, func(a, b string) int {
+			return s.Goals[b].UpdatedAt.Compare(s.Goals[a].UpdatedAt)
+		})
+		for _, id := range ended[min(len(ended), endedGoals):] {
+			delete(s.Goals, id)
+		}
+	}
+	return true, nil
+}
+
+// Update applies event to stored state of its broadcaster
+func Update(ctx context.Context, envelope *eventsub.Envelope) error {
+	return database.DB.UpdateState(ctx, envelope.BroadcasterID, func(data []byte) ([]byte, error) {
+		s := State{BroadcasterID: envelope.BroadcasterID}
+		if data != nil {
+			if err := json.Unmarshal(data, &s); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := s.Apply(envelope); err != nil {
+			return nil, err
+		}
+		return json.Marshal(s)
+	})
+}
+
+// Get returns stored state of broadcaster, empty state if nothing is known yet
+func Get(ctx context.Context, broadcasterId string) (State, error) {
+	s := State{BroadcasterID: broadcasterId}
+	data, err := database.DB.GetState(ctx, broadcasterId)
+	if err != nil || data == nil {
+		return s, err
+	}
+	err = json.Unmarshal(data, &s)
+	return s, err
+}
@@ -0,0 +1,123 @@
+package state
+
+import (
+	"fmt"
+	"services/webhooks/eventsub"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func envelope(eventType string, minute int, event any) *eventsub.Envelope {
+	return &eventsub.Envelope{Type: eventType, BroadcasterID: "1", OccurredAt: start.Add(time.Duration(minute) * time.Minute), Event: event}
+}
+
+func TestApply(t *testing.T) {
+	poll := map[string]any{"id": "poll"}
+	tests := []struct {
+		name   string
+		events []*eventsub.Envelope
+		// changed is result of applying the last event
+		changed bool
+		// want is type of poll section after all events, empty if there is none
+		want   string
+		active bool
+	}{
+		{
+			name:    "poll begins",
+			events:  []*eventsub.Envelope{envelope("channel.poll.begin", 0, poll)},
+			changed: true,
+			want:    "channel.poll.begin",
+			active:  true,
+		},
+		{
+			name:    "poll ends",
+			events:  []*eventsub.Envelope{envelope("channel.poll.begin", 0, poll), envelope("channel.poll.end", 1, poll)},
+			changed: true,
+			want:    "channel.poll.end",
+		},
+		{
+			name:    "older event does not overwrite newer one",
+			events:  []*eventsub.Envelope{envelope("channel.poll.end", 2, poll), envelope("channel.poll.progress", 1, poll)},
+			changed: false,
+			want:    "channel.poll.end",
+		},
+		{
+			name:    "event of same time is applied",
+			events:  []*eventsub.Envelope{envelope("channel.poll.progress", 1, poll), envelope("channel.poll.end", 1, poll)},
+			changed: true,
+			want:    "channel.poll.end",
+		},
+		{
+			name:    "untracked event",
+			events:  []*eventsub.Envelope{envelope("channel.cheer", 0, map[string]any{})},
+			changed: false,
+		},
+		{
+			name:    "donation does not change campaign",
+			events:  []*eventsub.Envelope{envelope("channel.charity_campaign.donate", 0, map[string]any{})},
+			changed: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := State{BroadcasterID: "1"}
+			var changed bool
+			for _, event := range tt.events {
+				var err error
+				if changed, err = s.Apply(event); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if changed != tt.changed {
+				t.Errorf("Apply() = %v, want %v", changed, tt.changed)
+			}
+			if tt.want == "" {
+				if s.Poll != nil {
+					t.Errorf("poll = %+v, want none", s.Poll)
+				}
+				return
+			}
+			if s.Poll == nil || s.Poll.Type != tt.want || s.Poll.Active != tt.active {
+				t.Errorf("poll = %+v, want %s active %v", s.Poll, tt.want, tt.active)
+			}
+		})
+	}
+}
+
+func TestApplyGoals(t *testing.T) {
+	s := State{BroadcasterID: "1"}
+	apply := func(eventType string, minute int, id string) bool {
+		changed, err := s.Apply(envelope(eventType, minute, &eventsub.ChannelGoal{ID: id}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return changed
+	}
+
+	apply("channel.goal.begin", 0, "running")
+	for i := 1; i <= endedGoals+2; i++ {
+		apply("channel.goal.end", i, fmt.Sprint("ended-", i))
+	}
+	if len(s.Goals) != endedGoals+1 {
+		t.Errorf("goals = %d, want running goal and last %d ended", len(s.Goals), endedGoals)
+	}
+	if s.Goals["running"] == nil || !s.Goals["running"].Active {
+		t.Error("running goal was pruned")
+	}
+	for _, id := range []string{"ended-1", "ended-2"} {
+		if s.Goals[id] != nil {
+			t.Errorf("oldest ended goal %s was kept", id)
+		}
+	}
+
+	// goals are ordered separately, older event of one goal does not block another
+	if apply("channel.goal.progress", -1, "running") {
+		t.Error("older progress overwrote running goal")
+	}
+	if !apply("channel.goal.begin", 0, "other") {
+		t.Error("older event of another goal was dropped")
+	}
+}
@@ -0,0 +1,50 @@
+package subscriptions
+
+import (
+	"services/webhooks/config"
+	"services/webhooks/handler"
+	"strings"
+)
+
+var callbacks config.Callbacks
+
+func ConfigureCallbacks(cfg config.Callbacks) {
+	callbacks = cfg
+}
+
+// Previous tells if subscription uses transport of this service which is not
+// current anymore, reconciler replaces it with subscription using current
+// transport. Webhook callbacks are replaced by conduit or by previous callback
+// URLs, both webhooks and conduit are replaced by WebSocket sessions.
+func Previous(value Data) bool {
+	if Current(value) {
+		return false
+	}
+	switch value.Transport.Method {
+	case Conduit:
+		return UsesWebSocket() && value.Transport.ConduitID != "" && value.Transport.ConduitID == ConduitID()
+	case Webhook:
+		if (UsesConduit() || UsesWebSocket()) && strings.Contains(value.Transport.Callback, handler.EVENTSUB_URL_PROD) {
+			return true
+		}
+		for _, url := range callbacks.Previous {
+			if strings.HasPrefix(value.Transport.Callback, strings.TrimSuffix(url, "/")+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// otherTransport tells if subscription not known as ours is delivered by
+// transport this service does not use, e.g. conduit when webhooks are used or
+// conduit of another deployment. Those are never cleaned up.
+func otherTransport(value Data) bool {
+	return UsesConduit() || value.Transport.Method != Webhook
+}
+
+// sameTransport tells if subscriptions are delivered the same way, the other
+// ones are not duplicates
+func sameTransport(a Data, b Data) bool {
+	return a.Transport.Method == b.Transport.Method && a.Transport.Callback == b.Transport.Callback && a.Transport.ConduitID == b.Transport.ConduitID
+}
@@ -0,0 +1,383 @@
+package subscriptions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"services/shared/logging"
+	"services/webhooks/config"
+	"services/webhooks/database"
+	"services/webhooks/handler"
+	"services/webhooks/metrics"
+	"services/webhooks/token"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Conduit transport, see https://dev.twitch.tv/docs/eventsub/handling-conduit-events/
+// Every replica registers itself in database, the one with the lowest id is
+// leader which creates the conduit and spreads shards evenly across replicas.
+// With WebSocket transport the leader opens sessions of all users.
+
+// instanceTTL is how long replica without heartbeat keeps its shards
+const instanceTTL = 90 * time.Second
+
+var conduitConfig config.Conduit
+
+var conduitMutex sync.RWMutex
+var conduitId string
+
+type conduit struct {
+	ID         string `json:"id"`
+	ShardCount int    `json:"shard_count"`
+}
+
+type shard struct {
+	ID        string         `json:"id"`
+	Status    string         `json:"status,omitempty"`
+	Transport shardTransport `json:"transport"`
+}
+
+type shardTransport struct {
+	Method   string `json:"method"`
+	Callback string `json:"callback,omitempty"`
+	Secret   string `json:"secret,omitempty"`
+}
+
+func ConfigureConduit(cfg config.Conduit) {
+	conduitConfig = cfg
+	if conduitConfig.InstanceID == "" {
+		conduitConfig.InstanceID, _ = os.Hostname()
+	}
+	conduitId = cfg.ID
+}
+
+// UsesConduit tells if subscriptions are created with conduit transport
+func UsesConduit() bool {
+	return conduitConfig.Transport == "conduit"
+}
+
+// UsesWebSocket tells if subscriptions are created by WebSocket sessions of
+// users instead of reconciler
+func UsesWebSocket() bool {
+	return conduitConfig.Transport == "websocket"
+}
+
+// ConduitID returns id of used conduit, empty until it is known
+func ConduitID() string {
+	conduitMutex.RLock()
+	defer conduitMutex.RUnlock()
+	return conduitId
+}
+
+func setConduitID(id string) {
+	conduitMutex.Lock()
+	conduitId = id
+	conduitMutex.Unlock()
+}
+
+// shardCallback is callback of shards assigned to this replica
+func shardCallback() string {
+	if conduitConfig.ShardURL != "" {
+		return conduitConfig.ShardURL + "/callback"
+	}
+	return handler.EVENTSUB_URL + "/callback"
+}
+
+// helix sends request to EventSub API with app access token, out is filled with response
+func helix(method string, path string, endpoint string, body any, out any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, twitch.HelixURL+"/eventsub/"+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	accessToken, err := token.Access()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Client-Id", twitch.ClientID)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	metrics.Helix(endpoint, resp, err)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	response, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: unexpected status %d: %s", method, path, resp.StatusCode, response)
+	}
+	if out == nil || len(response) == 0 {
+		return nil
+	}
+	return json.Unmarshal(response, out)
+}
+
+func heartbeat() error {
+	return database.DB.Heartbeat(context.Background(), database.Instance{
+		ID:          conduitConfig.InstanceID,
+		Callback:    shardCallback(),
+		HeartbeatAt: time.Now(),
+	})
+}
+
+// leader returns live instances and tells if this replica manages the conduit
+func leader() ([]database.Instance, bool, error) {
+	instances, err := database.DB.ListInstances(context.Background(), time.Now().Add(-instanceTTL))
+	if err != nil {
+		return nil, false, err
+	}
+	return instances, len(instances) > 0 && instances[0].ID == conduitConfig.InstanceID, nil
+}
+
+// ensureConduit finds configured conduit or the one created by this service,
+// leader creates it only when client has no conduit at all, so instance with
+// its own database never takes over conduit of another deployment. Leader also
+// keeps shard count in sync with configuration.
+func ensureConduit(isLeader bool) error {
+	var response struct {
+		Data []conduit `json:"data"`
+	}
+	if err := helix(http.MethodGet, "conduits", "eventsub.conduits.list", nil, &response); err != nil {
+		return err
+	}
+
+	id := conduitConfig.ID
+	stored := id == ""
+	if stored {
+		var err error
+		if id, err = database.DB.StoredConduit(context.Background()); err != nil {
+			return err
+		}
+	}
+	var current *conduit
+	for i := range response.Data {
+		if id != "" && response.Data[i].ID == id {
+			current = &response.Data[i]
+			break
+		}
+	}
+
+	if current == nil {
+		if !stored {
+			return fmt.Errorf("conduit %s not found", id)
+		}
+		if !isLeader {
+			// leader creates it
+			return nil
+		}
+		if id != "" {
+			// Twitch deletes conduits without enabled shards
+			slog.Warn("Stored conduit does not exist anymore", slog.String("conduit_id", id))
+			if err := database.DB.SetStoredConduit(context.Background(), ""); err != nil {
+				return err
+			}
+			setConduitID("")
+		}
+		if len(response.Data) > 0 {
+			ids := []string{}
+			for _, existing := range response.Data {
+				ids = append(ids, existing.ID)
+			}
+			return fmt.Errorf("client already has conduits %s, set CONDUIT_ID to the one this deployment should use", strings.Join(ids, ", "))
+		}
+		if err := helix(http.MethodPost, "conduits", "eventsub.conduits.create", conduit{ShardCount: conduitConfig.Shards}, &response); err != nil {
+			return err
+		}
+		if len(response.Data) == 0 {
+			return fmt.Errorf("conduit was not created")
+		}
+		slog.Info("Conduit created", slog.String("conduit_id", response.Data[0].ID), slog.Int("shards", conduitConfig.Shards))
+		if err := database.DB.SetStoredConduit(context.Background(), response.Data[0].ID); err != nil {
+			return err
+		}
+		setConduitID(response.Data[0].ID)
+		return nil
+	}
+
+	setConduitID(current.ID)
+	if isLeader && current.ShardCount != conduitConfig.Shards {
+		slog.Info("Updating conduit shard count", slog.String("conduit_id", current.ID), slog.Int("from", current.ShardCount), slog.Int("to", conduitConfig.Shards))
+		return helix(http.MethodPatch, "conduits", "eventsub.conduits.update", conduit{ID: current.ID, ShardCount: conduitConfig.Shards}, nil)
+	}
+	return nil
+}
+
+// rebalance assigns shard i to instance i mod number of instances, only shards
+// with different or failed callback are updated
+func rebalance(instances []database.Instance) error {
+	id := ConduitID()
+	shards := map[string]shard{}
+	cursor := ""
+	for {
+		var response struct {
+			Data       []shard    `json:"data"`
+			Pagination Pagination `json:"pagination"`
+		}
+		path := "conduits/shards?conduit_id=" + id
+		if cursor != "" {
+			path += "&after=" + cursor
+		}
+		if err := helix(http.MethodGet, path, "eventsub.conduits.shards.list", nil, &response); err != nil {
+			return err
+		}
+		for _, item := range response.Data {
+			shards[item.ID] = item
+		}
+		if response.Pagination.Cursor == nil || *response.Pagination.Cursor == "" {
+			break
+		}
+		cursor = *response.Pagination.Cursor
+	}
+
+	changed := []shard{}
+	for i := 0; i < conduitConfig.Shards; i++ {
+		callback := instances[i%len(instances)].Callback
+		current, found := shards[strconv.Itoa(i)]
+		healthy := current.Status == "enabled" || current.Status == "webhook_callback_verification_pending"
+		if found && healthy && current.Transport.Callback == callback {
+			continue
+		}
+		changed = append(changed, shard{
+			ID:        strconv.Itoa(i),
+			Transport: shardTransport{Method: "webhook", Callback: callback, Secret: twitch.Secret},
+		})
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	slog.Info("Rebalancing conduit shards", slog.String("conduit_id", id), slog.Int("shards", len(changed)), slog.Int("instances", len(instances)))
+	var response struct {
+		Errors []struct {
+			ID      string `json:"id"`
+			Message string `json:"message"`
+			Code    string `json:"code"`
+		} `json:"errors"`
+	}
+	err := helix(http.MethodPatch, "conduits/shards", "eventsub.conduits.shards.update", map[string]any{
+		"conduit_id": id,
+		"shards":     changed,
+	}, &response)
+	for _, shardError := range response.Errors {
+		slog.Error("Error updating conduit shard", slog.String("shard_id", shardError.ID), slog.String("code", shardError.Code), slog.String("message", shardError.Message))
+	}
+	return err
+}
+
+// syncConduit registers replica and lets leader manage conduit and its shards
+func syncConduit() error {
+	if err := heartbeat(); err != nil {
+		return err
+	}
+	instances, isLeader, err := leader()
+	if err != nil {
+		return err
+	}
+	if err := ensureConduit(isLeader); err != nil {
+		return err
+	}
+	if isLeader && ConduitID() != "" {
+		return rebalance(instances)
+	}
+	return nil
+}
+
+// SetupConduit waits until conduit is known, so subscriptions are not created
+// or cleaned up without it
+func SetupConduit() {
+	for {
+		err := syncConduit()
+		if err == nil && ConduitID() != "" {
+			slog.Info("Using conduit", slog.String("conduit_id", ConduitID()), slog.String("instance_id", conduitConfig.InstanceID))
+			return
+		}
+		if err != nil {
+			slog.Error("Error setting up conduit", logging.Error(err))
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// transport returns transport of created subscriptions
+func transport() SubscriptionAddTransport {
+	if UsesConduit() {
+		return SubscriptionAddTransport{Method: "conduit", ConduitID: ConduitID()}
+	}
+	return SubscriptionAddTransport{
+		Method:   "webhook",
+		Callback: handler.EVENTSUB_URL + "/callback",
+		Secret:   twitch.Secret,
+	}
+}
+
+// Current tells if subscription listed at Twitch uses our current transport,
+// subscriptions of WebSocket sessions are not listed with app token
+func Current(value Data) bool {
+	if UsesWebSocket() {
+		return false
+	}
+	if UsesConduit() {
+		return value.Transport.Method == Conduit && value.Transport.ConduitID == ConduitID()
+	}
+	return value.Transport.Method == Webhook && strings.Contains(value.Transport.Callback, handler.EVENTSUB_URL_PROD)
+}
+
+// ConduitLoop keeps replica registered and shards balanced
+func ConduitLoop() {
+	for {
+		time.Sleep(30 * time.Second)
+		if err := syncConduit(); err != nil {
+			slog.Error("Error syncing conduit", logging.Error(err))
+		}
+	}
+}
+
+// Leader registers replica and tells if it leads, WebSocket sessions are
+// opened by leader only, so users do not get session on every replica
+func Leader() (bool, error) {
+	if err := heartbeat(); err != nil {
+		return false, err
+	}
+	_, isLeader, err := leader()
+	return isLeader, err
+}
+
+// LoadStoredConduit uses conduit created by this service when none is
+// configured, so its subscriptions are known after switching transport
+func LoadStoredConduit() error {
+	if ConduitID() != "" {
+		return nil
+	}
+	id, err := database.DB.StoredConduit(context.Background())
+	if err == nil {
+		setConduitID(id)
+	}
+	return err
+}
+
+// Leave unregisters replica, so another one takes over conduit shards or
+// WebSocket sessions instead of waiting for heartbeat to expire
+func Leave() error {
+	return database.DB.RemoveInstance(context.Background(), conduitConfig.InstanceID)
+}
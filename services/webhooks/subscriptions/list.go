@@ -4,13 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
-	"os"
-	"services/webhooks/commons"
-	"services/webhooks/handler"
+	"net/url"
+	"services/shared/logging"
+	"services/webhooks/config"
+	"services/webhooks/eventsub"
+	"services/webhooks/metrics"
 	"services/webhooks/token"
 	"slices"
-	"strings"
 	"time"
 )
 
@@ -35,35 +37,13 @@ type Data struct {
 	Cost      int64     `json:"cost"`
 }
 
-type Condition struct {
-	BroadcasterUserID     *string `json:"broadcaster_user_id,omitempty"`
-	RewardID              *string `json:"reward_id,omitempty"`
-	FromBroadcasterUserID *string `json:"from_broadcaster_user_id,omitempty"`
-	ToBroadcasterUserID   *string `json:"to_broadcaster_user_id,omitempty"`
-	ModeratorUserID       *string `json:"moderator_user_id,omitempty"`
-	UserId                *string `json:"user_id,omitempty"`
-}
-
-func (c *Condition) Equal(other *Condition) bool {
-	return normalize(c.BroadcasterUserID) == normalize(other.BroadcasterUserID) &&
-		normalize(c.RewardID) == normalize(other.RewardID) &&
-		normalize(c.FromBroadcasterUserID) == normalize(other.FromBroadcasterUserID) &&
-		normalize(c.ToBroadcasterUserID) == normalize(other.ToBroadcasterUserID) &&
-		normalize(c.ModeratorUserID) == normalize(other.ModeratorUserID) &&
-		normalize(c.UserId) == normalize(other.UserId)
-}
-
-// normalize handles nil values by converting them to an empty string
-func normalize(s *string) string {
-	if s == nil {
-		return ""
-	}
-	return *s
-}
+type Condition = eventsub.Condition
 
 type Transport struct {
-	Method   Method `json:"method"`
-	Callback string `json:"callback"`
+	Method    Method `json:"method"`
+	Callback  string `json:"callback,omitempty"`
+	ConduitID string `json:"conduit_id,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
 }
 
 type Pagination struct {
@@ -74,31 +54,49 @@ type Status string
 
 const (
 	Enabled Status = "enabled"
+	// VerificationPending is webhook subscription waiting for its callback to be verified
+	VerificationPending Status = "webhook_callback_verification_pending"
 )
 
 type Method string
 
 const (
 	Webhook Method = "webhook"
+	Conduit Method = "conduit"
+	// WebSocket subscriptions belong to session of one user and are not listed with app token
+	WebSocket Method = "websocket"
 )
 
+var twitch config.Twitch
+
+func Configure(cfg config.Twitch) {
+	twitch = cfg
+}
+
 var SubscriptionList []Data
 
 func List() {
 	// Create an HTTP client
 	client := &http.Client{}
 
-	var TWITCH_EVENTSUB_CLIENTID string = os.Getenv("TWITCH_EVENTSUB_CLIENTID")
+	var TWITCH_EVENTSUB_CLIENTID string = twitch.ClientID
+
+	foreign := 0
+	defer func() {
+		if foreign > 0 {
+			slog.Warn("Keeping subscriptions of another transport, they are not migrated", slog.Int("count", foreign), slog.String("transport", transport().Method))
+		}
+	}()
 
 	var cursor *string
 	for {
 		if cursor != nil {
-			commons.Debug("Getting list with cursor " + *cursor)
+			slog.Debug("Getting list with cursor", slog.String("cursor", *cursor))
 		} else {
-			commons.Debug("Getting list without cursor")
+			slog.Debug("Getting list without cursor")
 		}
 
-		url := "https://api.twitch.tv/helix/eventsub/subscriptions"
+		url := twitch.HelixURL + "/eventsub/subscriptions"
 		if cursor != nil {
 			url = url + "?after=" + *cursor
 		}
@@ -106,14 +104,14 @@ func List() {
 		// Create a GET request
 		req, err := http.NewRequest("GET", url, nil)
 		if err != nil {
-			fmt.Println("Error creating request:", err)
+			slog.Error("Error creating request", logging.Error(err))
 			return
 		}
 
 		// Set request headers
 		token, err := token.Access()
 		if err != nil {
-			fmt.Println("Error creating request:", err)
+			slog.Error("Error creating request", logging.Error(err))
 			return
 		}
 
@@ -122,8 +120,9 @@ func List() {
 
 		// Send the request
 		resp, err := client.Do(req)
+		metrics.Helix("eventsub.subscriptions.list", resp, err)
 		if err != nil {
-			fmt.Println("Error sending request:", err)
+			slog.Error("Error sending request", logging.Error(err))
 			return
 		}
 		defer resp.Body.Close()
@@ -131,7 +130,7 @@ func List() {
 		// Read the response body
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			fmt.Println("Error reading response:", err)
+			slog.Error("Error reading response", logging.Error(err))
 			return
 		}
 
@@ -139,14 +138,23 @@ func List() {
 		var response Response
 		err = json.Unmarshal(body, &response)
 		if err != nil {
-			fmt.Println("Error unmarshaling response:", err)
+			slog.Error("Error unmarshaling response", logging.Error(err))
 			return
 		}
 
 	OuterLoop:
 		for _, value := range response.Data {
-			if !strings.Contains(value.Transport.Callback, handler.EVENTSUB_URL_PROD) || value.Status != "enabled" {
-				commons.Log(fmt.Sprintf("Cleaning up invalid subscription %s, type: %s with callback: %s\n", value.ID, value.Type, value.Transport.Callback))
+			known := Current(value) || Previous(value)
+			if !known && otherTransport(value) {
+				// switching transport must not delete subscriptions of all users
+				slog.Debug("Keeping subscription of another transport", logging.SubscriptionID(value.ID), logging.EventType(value.Type), slog.String("method", string(value.Transport.Method)), slog.String("conduit_id", value.Transport.ConduitID))
+				foreign++
+			} else if !known && !callbacks.CleanupUnknown {
+				// misconfigured URL would otherwise delete subscriptions of all users
+				slog.Warn("Keeping subscription with unknown callback, set CLEANUP_UNKNOWN_CALLBACKS to delete it", logging.SubscriptionID(value.ID), logging.EventType(value.Type), slog.String("callback", value.Transport.Callback), slog.String("conduit_id", value.Transport.ConduitID))
+			} else if !known || (value.Status != Enabled && value.Status != VerificationPending) {
+				// pending subscriptions are kept, so they are not created again before verification
+				slog.Info("Cleaning up invalid subscription", logging.SubscriptionID(value.ID), logging.EventType(value.Type), slog.String("callback", value.Transport.Callback), slog.String("conduit_id", value.Transport.ConduitID))
 				DeleteSubscription(value.ID, token)
 			} else {
 				// check if subscription is new
@@ -154,30 +162,30 @@ func List() {
 					// condition to strings so we can check
 					condition1, err := json.Marshal(value.Condition)
 					if err != nil {
-						fmt.Println("Error marshaling map to JSON:", err)
+						slog.Error("Error marshaling map to JSON", logging.Error(err))
 						return
 					}
 					// we need to remarshal the condition to objects to compare
 					var conditionMarshalledDefined Condition
 					err = json.Unmarshal(condition1, &conditionMarshalledDefined)
 					if err != nil {
-						fmt.Println("Error unmarshaling:", err)
+						slog.Error("Error unmarshaling", logging.Error(err))
 						return
 					}
 
 					condition2, err := json.Marshal(item.Condition)
 					if err != nil {
-						fmt.Println("Error marshaling map to JSON:", err)
+						slog.Error("Error marshaling map to JSON", logging.Error(err))
 						return
 					}
 					// we need to remarshal the condition to objects to compare
 					var conditionMarshalledReceived Condition
 					err = json.Unmarshal(condition2, &conditionMarshalledReceived)
 					if err != nil {
-						fmt.Println("Error unmarshaling:", err)
+						slog.Error("Error unmarshaling", logging.Error(err))
 						return
 					}
-					if item.Type == value.Type && item.Version == value.Version && conditionMarshalledDefined.Equal(&conditionMarshalledReceived) {
+					if item.Type == value.Type && item.Version == value.Version && conditionMarshalledDefined.Equal(&conditionMarshalledReceived) && sameTransport(item, value) {
 						continue OuterLoop
 					}
 				}
@@ -194,14 +202,14 @@ func List() {
 	}
 }
 
-func DeleteSubscription(subscriptionId string, token string) {
-	var TWITCH_EVENTSUB_CLIENTID string = os.Getenv("TWITCH_EVENTSUB_CLIENTID")
+func DeleteSubscription(subscriptionId string, token string) error {
+	var TWITCH_EVENTSUB_CLIENTID string = twitch.ClientID
 
-	url := "https://api.twitch.tv/helix/eventsub/subscriptions?id=" + subscriptionId
+	url := twitch.HelixURL + "/eventsub/subscriptions?id=" + subscriptionId
 	req, err := http.NewRequest("DELETE", url, nil)
 	if err != nil {
-		fmt.Println("Error creating request:", err)
-		return
+		slog.Error("Error creating request", logging.Error(err))
+		return err
 	}
 
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -210,19 +218,26 @@ func DeleteSubscription(subscriptionId string, token string) {
 	// Send the request
 	client := &http.Client{}
 	resp, err := client.Do(req)
+	metrics.Helix("eventsub.subscriptions.delete", resp, err)
 	if err != nil {
-		fmt.Println("Error sending request:", err)
-		return
+		slog.Error("Error sending request", logging.Error(err))
+		return err
 	}
 	defer resp.Body.Close()
+
+	// subscription may be already gone, that is fine
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("deleting subscription %s: unexpected status %d", subscriptionId, resp.StatusCode)
+	}
+	return nil
 }
 
 func CleanDuplicatedSubscriptions() {
-	commons.Log("Cleaning up duplicated subscriptions")
+	slog.Info("Cleaning up duplicated subscriptions")
 	// Set request headers
 	token, err := token.Access()
 	if err != nil {
-		fmt.Println("Error creating request:", err)
+		slog.Error("Error creating request", logging.Error(err))
 		return
 	}
 	idsAlreadyChecked := []string{}
@@ -236,12 +251,64 @@ func CleanDuplicatedSubscriptions() {
 			if slices.Contains(idsAlreadyChecked, value2.ID) {
 				continue
 			}
-			if value.Type == value2.Type && value.Condition.Equal(&value2.Condition) && value.ID != value2.ID {
+			// other versions and transports are not duplicates, they are replaced by migrations
+			if value.Type == value2.Type && value.Version == value2.Version && value.Condition.Equal(&value2.Condition) && sameTransport(value, value2) && value.ID != value2.ID {
 				idsAlreadyChecked = append(idsAlreadyChecked, value2.ID)
-				commons.Log(fmt.Sprintf("Cleaning up duplicated subscription %s, type: %s with callback: %s\n", value2.ID, value2.Type, value2.Transport.Callback))
+				slog.Info("Cleaning up duplicated subscription", logging.SubscriptionID(value2.ID), logging.EventType(value2.Type), slog.String("callback", value2.Transport.Callback))
 				DeleteSubscription(value.ID, token)
 			}
 		}
 		SubscriptionList = nil
 	}
 }
+
+// ListForUser returns subscriptions of user directly from Twitch, including
+// the ones not created by this relay
+func ListForUser(userId string) ([]Data, error) {
+	token, err := token.Access()
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{}
+	list := []Data{}
+	var cursor *string
+	for {
+		query := url.Values{"user_id": {userId}}
+		if cursor != nil {
+			query.Set("after", *cursor)
+		}
+
+		req, err := http.NewRequest("GET", twitch.HelixURL+"/eventsub/subscriptions?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Client-Id", twitch.ClientID)
+
+		resp, err := client.Do(req)
+		metrics.Helix("eventsub.subscriptions.list", resp, err)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("listing subscriptions failed with status %d: %s", resp.StatusCode, body)
+		}
+
+		var response Response
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, err
+		}
+		list = append(list, response.Data...)
+
+		if response.Pagination.Cursor == nil || *response.Pagination.Cursor == "" {
+			return list, nil
+		}
+		cursor = response.Pagination.Cursor
+	}
+}
@@ -2,14 +2,15 @@ package subscriptions
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
-	"os"
-	"services/webhooks/commons"
+	"services/shared/logging"
 	"services/webhooks/database"
-	"services/webhooks/handler"
+	"services/webhooks/eventsub"
+	"services/webhooks/metrics"
 	"services/webhooks/token"
 	"sync"
 )
@@ -26,9 +27,11 @@ type Subscription struct {
 }
 
 type SubscriptionAddTransport struct {
-	Method   string `json:"method"`
-	Callback string `json:"callback"`
-	Secret   string `json:"secret"`
+	Method    string `json:"method"`
+	Callback  string `json:"callback,omitempty"`
+	Secret    string `json:"secret,omitempty"`
+	ConduitID string `json:"conduit_id,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
 }
 
 type FollowCondition struct {
@@ -36,11 +39,12 @@ type FollowCondition struct {
 	ModeratorUserID   string `json:"moderator_user_id"`
 }
 
-func Create(wg *sync.WaitGroup, userId string, subscriptionType string, subscriptionVersion string, subscriptionCondition interface{}) {
+// Create subscribes user to event, customId is id of custom subscription it
+// creates, 0 for subscriptions of catalog
+func Create(wg *sync.WaitGroup, userId string, subscriptionType string, subscriptionVersion string, subscriptionCondition interface{}, customId int64) {
 	defer wg.Done()
 
-	var clientID string = os.Getenv("TWITCH_EVENTSUB_CLIENTID")
-	var secret string = os.Getenv("TWITCH_EVENTSUB_SECRET")
+	var clientID string = twitch.ClientID
 
 	// Define the request body as a struct
 	requestBody := struct {
@@ -52,17 +56,13 @@ func Create(wg *sync.WaitGroup, userId string, subscriptionType string, subscrip
 		Type:      subscriptionType,
 		Version:   subscriptionVersion,
 		Condition: subscriptionCondition,
-		Transport: SubscriptionAddTransport{
-			Method:   "webhook",
-			Callback: handler.EVENTSUB_URL + "/callback",
-			Secret:   secret,
-		},
+		Transport: transport(),
 	}
 
 	// Convert the request body struct to JSON
 	jsonBody, err := json.Marshal(requestBody)
 	if err != nil {
-		fmt.Println("Error marshaling request body:", err)
+		slog.Error("Error marshaling request body", logging.Error(err))
 		return
 	}
 
@@ -70,15 +70,15 @@ func Create(wg *sync.WaitGroup, userId string, subscriptionType string, subscrip
 	client := &http.Client{}
 
 	// Create a POST request
-	req, err := http.NewRequest("POST", "https://api.twitch.tv/helix/eventsub/subscriptions", bytes.NewBuffer(jsonBody))
+	req, err := http.NewRequest("POST", twitch.HelixURL+"/eventsub/subscriptions", bytes.NewBuffer(jsonBody))
 	if err != nil {
-		fmt.Println("Error creating request:", err)
+		slog.Error("Error creating request", logging.Error(err))
 		return
 	}
 
 	token, err := token.Access()
 	if err != nil {
-		fmt.Println("Error getting token:", err)
+		slog.Error("Error getting token", logging.Error(err))
 		return
 	}
 
@@ -89,8 +89,9 @@ func Create(wg *sync.WaitGroup, userId string, subscriptionType string, subscrip
 
 	// Send the request
 	resp, err := client.Do(req)
+	metrics.Helix("eventsub.subscriptions.create", resp, err)
 	if err != nil {
-		fmt.Println("Error sending request:", err)
+		slog.Error("Error sending request", logging.Error(err))
 		return
 	}
 	defer resp.Body.Close()
@@ -98,7 +99,7 @@ func Create(wg *sync.WaitGroup, userId string, subscriptionType string, subscrip
 	// Read the response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Println("Error reading response:", err)
+		slog.Error("Error reading response", logging.Error(err))
 		return
 	}
 
@@ -115,16 +116,63 @@ func Create(wg *sync.WaitGroup, userId string, subscriptionType string, subscrip
 
 		err = json.Unmarshal(body, &response)
 		if err != nil {
-			commons.Log("User " + userId + " error for " + subscriptionType + ".v" + subscriptionVersion + ": " + string(body))
-			commons.Log("Error unmarshaling response: " + err.Error())
+			slog.Error("Error unmarshaling response", logging.UserID(userId), logging.EventType(subscriptionType), slog.String("version", subscriptionVersion), slog.String("body", string(body)), logging.Error(err))
 			return
 		}
 
 		if response.Status == 403 {
-			database.DB.Exec("DELETE FROM eventsub_users WHERE \"userId\"=$1", userId)
+			refused(userId, subscriptionType, subscriptionCondition, customId, response.Message)
 			return
 		}
-		commons.Log("User " + userId + " error for " + subscriptionType + ".v" + subscriptionVersion + ": " + string(body))
+		if rewardId := rewardID(subscriptionCondition); response.Status == 400 && rewardId != "" {
+			// reward was deleted or belongs to another channel, it would block switch of filters
+			slog.Warn("Twitch refused reward subscription", logging.UserID(userId), logging.EventType(subscriptionType), slog.String("reward_id", rewardId), slog.String("message", response.Message))
+			if err := database.PruneRewardFilter(context.Background(), userId, rewardId); err != nil {
+				slog.Error("Error removing reward filter", logging.UserID(userId), slog.String("reward_id", rewardId), logging.Error(err))
+			}
+			return
+		}
+		slog.Error("Error creating subscription", logging.UserID(userId), logging.EventType(subscriptionType), slog.String("version", subscriptionVersion), slog.String("body", string(body)))
+		return
+	}
+}
+
+// refused handles subscription Twitch does not authorize like revocation, only
+// linked bot loses its link when it is the moderator in condition. Custom
+// subscription is deleted, its requester lost scope or moderator role.
+func refused(userId string, subscriptionType string, subscriptionCondition interface{}, customId int64, message string) {
+	ctx := context.Background()
+	if customId != 0 {
+		slog.Warn("Twitch refused custom subscription, deleting it", logging.UserID(userId), logging.EventType(subscriptionType), slog.Int64("id", customId), slog.String("message", message))
+		if err := database.DB.MarkCustomSubscriptionDeleted(ctx, customId); err != nil {
+			slog.Error("Error deleting custom subscription", logging.UserID(userId), slog.Int64("id", customId), logging.Error(err))
+			return
+		}
+		if err := database.DB.MarkUpdated(ctx, userId); err != nil {
+			slog.Warn("Error marking user updated", logging.UserID(userId), logging.Error(err))
+		}
+		return
+	}
+	var condition eventsub.Condition
+	if data, err := json.Marshal(subscriptionCondition); err == nil {
+		json.Unmarshal(data, &condition)
+	}
+	if botId := condition.Moderator(); botId != "" {
+		slog.Warn("Twitch refused subscription of linked bot", logging.UserID(userId), logging.EventType(subscriptionType), slog.String("bot_id", botId), slog.String("message", message))
+		if err := database.UnlinkBot(ctx, botId, userId); err != nil {
+			slog.Error("Error unlinking bot", logging.UserID(userId), slog.String("bot_id", botId), logging.Error(err))
+		}
 		return
 	}
+	slog.Warn("Twitch refused subscription of broadcaster, deleting user", logging.UserID(userId), logging.EventType(subscriptionType), slog.String("message", message))
+	database.DB.DeleteUser(ctx, userId)
+}
+
+// rewardID returns reward_id of reward filtered condition
+func rewardID(condition interface{}) string {
+	if condition, ok := condition.(map[string]interface{}); ok {
+		rewardId, _ := condition["reward_id"].(string)
+		return rewardId
+	}
+	return ""
 }
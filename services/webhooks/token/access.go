@@ -3,12 +3,14 @@ package token
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
-	"services/webhooks/commons"
+	"services/shared/logging"
+	"services/webhooks/config"
+	"services/webhooks/metrics"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,19 +19,31 @@ type TokenResponse struct {
 	ExpiresIn   int    `json:"expires_in"`
 }
 
+var twitch config.Twitch
+
+func Configure(cfg config.Twitch) {
+	twitch = cfg
+}
+
+// mutex guards cached token, it is held while new token is generated so
+// concurrent callers wait for it instead of generating their own
+var mutex sync.Mutex
 var accessTokenCache string
 var expirationTime time.Time
 
 func Access() (string, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
 	if len(accessTokenCache) > 0 && expirationTime.After((time.Now())) {
-		commons.Debug("Reusing old access token")
+		slog.Debug("Reusing old access token")
 		return accessTokenCache, nil
 	}
-	commons.Debug("Generating new access token")
+	slog.Debug("Generating new access token")
 
 	// Set your Twitch app's client ID and secret
-	var clientID string = os.Getenv("TWITCH_EVENTSUB_CLIENTID")
-	var clientSecret string = os.Getenv("TWITCH_EVENTSUB_CLIENTSECRET")
+	var clientID string = twitch.ClientID
+	var clientSecret string = twitch.ClientSecret
 
 	// Create an HTTP client
 	client := &http.Client{}
@@ -40,17 +54,18 @@ func Access() (string, error) {
 	data.Set("client_secret", clientSecret)
 	data.Set("grant_type", "client_credentials")
 	data.Set("scope", "") // Set the desired scope if needed
-	req, err := http.NewRequest("POST", "https://id.twitch.tv/oauth2/token", strings.NewReader(data.Encode()))
+	req, err := http.NewRequest("POST", twitch.AuthURL+"/token", strings.NewReader(data.Encode()))
 	if err != nil {
-		fmt.Println("Error creating request:", err)
+		slog.Error("Error creating request", logging.Error(err))
 		return "", errors.New(err.Error())
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	// Send the request
 	resp, err := client.Do(req)
+	metrics.Helix("oauth2.token", resp, err)
 	if err != nil {
-		fmt.Println("Error sending request:", err)
+		slog.Error("Error sending request", logging.Error(err))
 		return "", errors.New(err.Error())
 	}
 	defer resp.Body.Close()
@@ -59,7 +74,7 @@ func Access() (string, error) {
 	var tokenResponse TokenResponse
 	err = json.NewDecoder(resp.Body).Decode(&tokenResponse)
 	if err != nil {
-		fmt.Println("Error decoding response:", err)
+		slog.Error("Error decoding response", logging.Error(err))
 		return "", errors.New(err.Error())
 	}
 
@@ -0,0 +1,188 @@
+package token
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"services/shared/logging"
+	"services/webhooks/database"
+	"services/webhooks/metrics"
+	"strings"
+)
+
+// ErrInvalid is returned when Twitch rejects user token
+var ErrInvalid = errors.New("user token is not valid")
+
+// User is access token of user with refresh token it is renewed with, tokens
+// are encrypted in database
+type User struct {
+	Access  string
+	Refresh string
+	// sealed is encrypted access token as stored
+	sealed string
+}
+
+var aead cipher.AEAD
+
+// ConfigureUsers sets base64 encoded 32 byte key user tokens are encrypted with
+func ConfigureUsers(key string) error {
+	data, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return fmt.Errorf("decoding token encryption key: %w", err)
+	}
+	if len(data) != 32 {
+		return errors.New("token encryption key must have 32 bytes")
+	}
+	block, err := aes.NewCipher(data)
+	if err != nil {
+		return err
+	}
+	aead, err = cipher.NewGCM(block)
+	return err
+}
+
+// seal encrypts value with AES-GCM, nonce is prepended to the result
+func seal(value string) (string, error) {
+	if aead == nil {
+		return "", errors.New("token encryption key is not configured")
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(value), nil)), nil
+}
+
+func unseal(value string) (string, error) {
+	if aead == nil {
+		return "", errors.New("token encryption key is not configured")
+	}
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < aead.NonceSize() {
+		return "", errors.New("encrypted token is too short")
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	return string(plain), err
+}
+
+// StoreUser encrypts and stores tokens of user, empty refresh token keeps the stored one
+func StoreUser(ctx context.Context, userId string, user User) (User, error) {
+	access, err := seal(user.Access)
+	if err != nil {
+		return user, err
+	}
+	refresh := ""
+	if user.Refresh != "" {
+		if refresh, err = seal(user.Refresh); err != nil {
+			return user, err
+		}
+	}
+	user.sealed = access
+	return user, database.DB.SetUserToken(ctx, userId, database.UserToken{AccessToken: access, RefreshToken: refresh})
+}
+
+// Users returns stored tokens by user id, tokens which can not be decrypted
+// are skipped
+func Users(ctx context.Context) (map[string]User, error) {
+	stored, err := database.DB.UserTokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+	users := map[string]User{}
+	for userId, token := range stored {
+		user := User{sealed: token.AccessToken}
+		if user.Access, err = unseal(token.AccessToken); err == nil && token.RefreshToken != "" {
+			user.Refresh, err = unseal(token.RefreshToken)
+		}
+		if err != nil {
+			slog.Warn("Error decrypting user token", logging.UserID(userId), logging.Error(err))
+			continue
+		}
+		users[userId] = user
+	}
+	return users, nil
+}
+
+// DeleteUser deletes tokens of user, only if they were not replaced meanwhile
+func DeleteUser(ctx context.Context, userId string, user User) error {
+	return database.DB.DeleteUserToken(ctx, userId, user.sealed)
+}
+
+// Validate checks user access token, Twitch requires it every hour,
+// see https://dev.twitch.tv/docs/authentication/validate-tokens/
+func Validate(ctx context.Context, accessToken string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, twitch.AuthURL+"/validate", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "OAuth "+accessToken)
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	metrics.Helix("oauth2.validate", resp, err)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized {
+		return ErrInvalid
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("validating token failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Refresh renews access token of user with its refresh token and stores both
+// new tokens, see https://dev.twitch.tv/docs/authentication/refresh-tokens/
+func Refresh(ctx context.Context, userId string, user User) (User, error) {
+	if user.Refresh == "" {
+		return user, ErrInvalid
+	}
+	data := url.Values{}
+	data.Set("client_id", twitch.ClientID)
+	data.Set("client_secret", twitch.ClientSecret)
+	data.Set("grant_type", "refresh_token")
+	data.Set("refresh_token", user.Refresh)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, twitch.AuthURL+"/token", strings.NewReader(data.Encode()))
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	metrics.Helix("oauth2.token", resp, err)
+	if err != nil {
+		return user, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized {
+		// refresh token was revoked, e.g. by password change
+		return user, ErrInvalid
+	}
+	if resp.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("refreshing token failed with status %d", resp.StatusCode)
+	}
+
+	var response struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return user, err
+	}
+	return StoreUser(ctx, userId, User{Access: response.AccessToken, Refresh: response.RefreshToken})
+}
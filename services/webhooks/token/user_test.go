@@ -0,0 +1,60 @@
+package token
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestConfigureUsers(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{name: "32 bytes", key: base64.StdEncoding.EncodeToString(make([]byte, 32))},
+		{name: "16 bytes", key: base64.StdEncoding.EncodeToString(make([]byte, 16)), wantErr: true},
+		{name: "not base64", key: "not base64!", wantErr: true},
+		{name: "empty", key: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ConfigureUsers(tt.key); (err != nil) != tt.wantErr {
+				t.Errorf("ConfigureUsers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSeal(t *testing.T) {
+	if err := ConfigureUsers(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))); err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := seal("access-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "access-token") {
+		t.Fatal("sealed token contains plaintext")
+	}
+	if again, _ := seal("access-token"); again == sealed {
+		t.Error("sealing twice gave the same value, nonce is not random")
+	}
+	if plain, err := unseal(sealed); err != nil || plain != "access-token" {
+		t.Errorf("unseal = %q, %v", plain, err)
+	}
+
+	tampered := []byte(sealed)
+	tampered[len(tampered)-2] ^= 1
+	if _, err := unseal(string(tampered)); err == nil {
+		t.Error("tampered token was decrypted")
+	}
+
+	if err := ConfigureUsers(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("x", 32)))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unseal(sealed); err == nil {
+		t.Error("token was decrypted with another key")
+	}
+}